@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/anonymize"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+// deanonymizeCmd represents the deanonymize command
+var (
+	deanonymizeCmd = &cobra.Command{
+		Use:   "deanonymize <file>",
+		Short: "Replaces the pseudonyms in results produced with --anonymize with the original names",
+		Long: `Replaces the pseudonyms in results produced with --anonymize with the original names, per the locally kept mapping file.
+Works on any file holding pseudonyms, e.g. collect, expand or eval output, or a report based on them.`,
+		Run: runDeanonymize,
+	}
+
+	anonymizeResults     bool
+	anonymizeMappingFile string
+)
+
+func runDeanonymize(cmd *cobra.Command, args []string) {
+	if len(args) < 1 {
+		fmt.Println("[!] No file specified")
+		cmd.Help()
+		return
+	}
+	data, err := utils.ReadFile(args[0])
+	if err != nil {
+		return
+	}
+	output, err := anonymize.Deanonymize(anonymizeMappingFile, data)
+	if err != nil {
+		return // error printed by Deanonymize()
+	}
+	outputResults(bytes.TrimSuffix(output, []byte("\n")))
+}
+
+func init() {
+	deanonymizeCmd.Flags().StringVar(&anonymizeMappingFile, "mapping", "rbac-police-mapping.json", "mapping file written by --anonymize")
+	rootCmd.AddCommand(deanonymizeCmd)
+}
+
+// Adds the anonymization flags to @cmd
+func addAnonymizeFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&anonymizeResults, "anonymize", false, "replace namespaces, identities, nodes, roles and IAM entities with consistent pseudonyms, preserving well-known system names")
+	cmd.Flags().StringVar(&anonymizeMappingFile, "anonymize-mapping", "rbac-police-mapping.json", "mapping file holding the anonymization key and the names behind pseudonyms, keep it local to reverse the anonymization via 'deanonymize'")
+}
+
+// Returns an anonymizer if anonymization is requested, nil otherwise.
+// Returns false if anonymization is requested and the anonymizer couldn't be created.
+func newAnonymizer() (*anonymize.Anonymizer, bool) {
+	if !anonymizeResults {
+		return nil, true
+	}
+	anonymizer, err := anonymize.NewAnonymizer(anonymizeMappingFile)
+	if err != nil {
+		return nil, false // error printed by NewAnonymizer()
+	}
+	return anonymizer, true
+}
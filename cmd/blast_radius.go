@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/blastradius"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/report"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// blastRadiusCmd represents the blast-radius command
+var (
+	blastRadiusCmd = &cobra.Command{
+		Use:   "blast-radius <identity> [rbac-json]",
+		Short: "Computes what an attacker controlling an identity, a node or a pod can reach",
+		Long: `Computes what an attacker controlling an identity, a node or a pod can reach, for incident response.
+Walks the identities an attacker can transitively take over: tokens of serviceAccounts whose pods run on a compromised node,
+impersonation targets, serviceAccounts that can be assigned to pods, and tokens obtainable via secrets or TokenRequests.
+Outputs the reachable identities, the secrets and cloud provider IAM entities they expose, the union of their permissions,
+and the violations they produce. The identity's format is 'type=identity', e.g. 'sa=kube-system:default', 'node=worker-1',
+'user=example@email.com', 'group=devs' or 'pod=default:web-1'.`,
+		Run: runBlastRadius,
+	}
+
+	blastRadiusConfig blastradius.BlastRadiusConfig
+	blastRadiusFormat string
+)
+
+func runBlastRadius(cmd *cobra.Command, args []string) {
+	var collectResult collect.CollectResult
+
+	if len(args) < 1 {
+		fmt.Println("[!] No identity specified")
+		cmd.Help()
+		return
+	}
+	compromised, err := blastradius.ParseIdentity(args[0])
+	if err != nil {
+		fmt.Printf("[!] Invalid identity, %v\n", err)
+		cmd.Help()
+		return
+	}
+	if blastRadiusFormat != "json" && blastRadiusFormat != "table" {
+		fmt.Printf("[!] Unsupported output format '%s', supported formats are 'json' or 'table'\n", blastRadiusFormat)
+		cmd.Help()
+		return
+	}
+
+	// Get RBAC input
+	if len(args) > 1 {
+		if collectionOptionsSet() {
+			fmt.Println("[!] Can only set collection options when collecting")
+			cmd.Help()
+			return
+		}
+		collectResultBytes, err := utils.ReadFile(args[1])
+		if err != nil {
+			return
+		}
+		err = json.Unmarshal(collectResultBytes, &collectResult)
+		if err != nil {
+			log.Errorf("runBlastRadius: failed to unmarshel %v into a CollectResult object with %v\n", args[1], err)
+			return
+		}
+	} else {
+		collectResultPtr := collect.Collect(collectConfig)
+		if collectResultPtr == nil {
+			return // error printed by Collect()
+		}
+		collectResult = *collectResultPtr
+	}
+
+	blastRadiusResult := blastradius.BlastRadius(compromised, collectResult, blastRadiusConfig)
+	if blastRadiusResult == nil {
+		return // error printed by BlastRadius()
+	}
+	if blastRadiusFormat == "table" {
+		outputResults([]byte(report.BlastRadiusTable(blastRadiusResult, colorOutput())))
+		return
+	}
+	output, err := marshalResults(blastRadiusResult)
+	if err != nil {
+		log.Errorln("runBlastRadius: failed to marshal results with", err)
+		return
+	}
+	outputResults(output)
+}
+
+func init() {
+	blastRadiusCmd.Flags().StringVarP(&blastRadiusFormat, "format", "f", "json", "output format, 'json' or 'table'")
+	blastRadiusCmd.Flags().StringVar(&blastRadiusConfig.PolicyPath, "policies", "lib", "policies to evaluate the reachable identities with")
+	blastRadiusCmd.Flags().StringVarP(&blastRadiusConfig.EvalConfig.SeverityThreshold, "severity-threshold", "s", "Low", "only evaluate policies with severity >= threshold")
+	blastRadiusCmd.Flags().StringSliceVar(&blastRadiusConfig.EvalConfig.PrivilegedNamespaces, "privileged-namespaces", []string{"kube-system"}, "namespaces policies should treat as privileged")
+	blastRadiusCmd.Flags().StringVar(&blastRadiusConfig.EvalConfig.PrivilegedNamespaceSelector, "privileged-namespaces-selector", "", "also treat namespaces matching this label selector as privileged")
+	blastRadiusCmd.Flags().StringArrayVar(&blastRadiusConfig.EvalConfig.PolicyDataFiles, "policy-data", []string{}, "json or yaml file with data for policies")
+
+	rootCmd.AddCommand(blastRadiusCmd)
+}
@@ -21,6 +21,16 @@ func runCollect(cmd *cobra.Command, args []string) {
 	if collectResult == nil {
 		return // error printed by Collect()
 	}
+	anonymizer, ok := newAnonymizer()
+	if !ok {
+		return // error printed by newAnonymizer()
+	}
+	if anonymizer != nil {
+		anonymizer.CollectResult(collectResult)
+		if anonymizer.Save() != nil {
+			return // error printed by Save()
+		}
+	}
 
 	// Output collect results
 	output, err := marshalResults(collectResult)
@@ -32,5 +42,6 @@ func runCollect(cmd *cobra.Command, args []string) {
 }
 
 func init() {
+	addAnonymizeFlags(collectCmd)
 	rootCmd.AddCommand(collectCmd)
 }
@@ -3,9 +3,15 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"time"
 
+	"github.com/PaloAltoNetworks/rbac-police/pkg/attribute"
 	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
 	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/expand"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/report"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/usage"
 	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
 	"github.com/spf13/cobra"
 
@@ -20,17 +26,20 @@ var (
 		Run:   runEval,
 	}
 
-	evalConfig eval.EvalConfig
-	shortMode  bool
-	violations []string
+	evalConfig   eval.EvalConfig
+	shortMode    bool
+	outputFormat string
+	embedExpand  bool
+	violations   []string
+
+	baseAuditPolicy string
+	evalAuditLogs   []string
+	byIdentity      bool
+	topIdentities   int
 )
 
 func runEval(cmd *cobra.Command, args []string) {
-	var (
-		collectResult collect.CollectResult
-		output        []byte
-		err           error
-	)
+	var collectResult collect.CollectResult
 
 	if len(args) < 1 {
 		fmt.Println("[!] No policies specified")
@@ -39,10 +48,90 @@ func runEval(cmd *cobra.Command, args []string) {
 	}
 	policyPath := args[0]
 
+	if !validOutputOptions(cmd) || !setViolationTypes(cmd) {
+		return
+	}
+
+	// Get RBAC input
+	if len(args) > 1 {
+		// Read RBAC from file
+		if collectionOptionsSet() {
+			fmt.Println("[!] Can only set collection options when collecting")
+			cmd.Help()
+			return
+		}
+		collectResultBytes, err := utils.ReadFile(args[1])
+		if err != nil {
+			return
+		}
+		err = json.Unmarshal(collectResultBytes, &collectResult)
+		if err != nil {
+			log.Errorf("runEval: failed to unmarshel %v into a CollectResult object with %v\n", args[0], err)
+			return
+		}
+	} else {
+		// Collect RBAC from remote cluster
+		collectResultPtr := collect.Collect(collectConfig)
+		if collectResultPtr == nil {
+			return // error printed by Collect()
+		}
+		collectResult = *collectResultPtr
+	}
+
+	policyResults := eval.Eval(policyPath, collectResult, evalConfig)
+	if policyResults == nil {
+		return // error printed by Collect()
+	}
+	if len(evalAuditLogs) > 0 {
+		if usage.Correlate(policyResults, collectResult, evalAuditLogs, evalConfig) != nil {
+			return // error printed by Correlate()
+		}
+	}
+
+	outputPolicyResults(policyResults, collectResult, len(args) > 1 || collectConfig.OfflineDir != "")
+}
+
+// Validates the output format and its options, prints an error and returns false if they're invalid
+func validOutputOptions(cmd *cobra.Command) bool {
+	if outputFormat != "json" && outputFormat != "table" && outputFormat != "markdown" && outputFormat != "html" && outputFormat != "junit" && outputFormat != "policyreport" && outputFormat != "auditpolicy" {
+		fmt.Printf("[!] Unsupported output format '%s', supported formats are 'json', 'table', 'markdown', 'html', 'junit', 'policyreport' or 'auditpolicy'\n", outputFormat)
+		cmd.Help()
+		return false
+	}
+	if embedExpand && outputFormat != "html" {
+		fmt.Println("[!] Can only embed expand data in html output")
+		cmd.Help()
+		return false
+	}
+	if byIdentity && outputFormat != "json" && outputFormat != "table" && outputFormat != "markdown" {
+		fmt.Println("[!] Can only output results by identity as json, table or markdown")
+		cmd.Help()
+		return false
+	}
+	if topIdentities != 0 && !byIdentity {
+		fmt.Println("[!] Can only limit the number of identities when outputting results by identity")
+		cmd.Help()
+		return false
+	}
+	if baseAuditPolicy != "" && outputFormat != "auditpolicy" {
+		fmt.Println("[!] Can only merge into an audit policy when outputting an audit policy")
+		cmd.Help()
+		return false
+	}
+	if anonymizeResults && outputFormat == "auditpolicy" {
+		fmt.Println("[!] Cannot anonymize audit policies, as they're meant to be applied to the cluster")
+		cmd.Help()
+		return false
+	}
+	return true
+}
+
+// Sets the violation types to search for per the violations flag, prints an error and returns false if they're invalid
+func setViolationTypes(cmd *cobra.Command) bool {
 	if len(violations) == 0 {
 		fmt.Println("[!] Cannot disable all violation types")
 		cmd.Help()
-		return
+		return false
 	}
 
 	// Set the violations user asked to search for
@@ -68,65 +157,168 @@ func runEval(cmd *cobra.Command, args []string) {
 		} else {
 			fmt.Printf("[!] Unrecognized violation type '%s', supported types are 'sa', 'node', 'combined', 'user', 'group' or 'all'\n", violationType)
 			cmd.Help()
-			return
+			return false
 		}
 	}
+	return true
+}
 
-	// Get RBAC input
-	if len(args) > 1 {
-		// Read RBAC from file
-		if collectionOptionsSet() {
-			fmt.Println("[!] Can only set collection options when collecting")
-			cmd.Help()
-			return
-		}
-		collectResultBytes, err := utils.ReadFile(args[1])
-		if err != nil {
-			return
+// Outputs @policyResults, evaluated against @collectResult, in the output format.
+// When @offline is set, policy reports are rendered rather than applied to the cluster.
+func outputPolicyResults(policyResults *eval.PolicyResults, collectResult collect.CollectResult, offline bool) {
+	var (
+		output []byte
+		err    error
+	)
+
+	if anonymizeResults && outputFormat == "policyreport" && !offline {
+		fmt.Println("[!] Cannot apply anonymized policy reports to the cluster, evaluate offline to render them")
+		return
+	}
+	if attribute.AttributeUserGrants(policyResults, collectResult, evalConfig) != nil {
+		return // error printed by AttributeUserGrants()
+	}
+	anonymizer, ok := newAnonymizer()
+	if !ok {
+		return // error printed by newAnonymizer()
+	}
+	if anonymizer != nil {
+		anonymizer.PolicyResults(policyResults)
+		anonymizer.CollectResult(&collectResult)
+		if anonymizer.Save() != nil {
+			return // error printed by Save()
 		}
-		err = json.Unmarshal(collectResultBytes, &collectResult)
+	}
+
+	if byIdentity {
+		grantGroups, err := attribute.AttributeGrantGroups(*policyResults, collectResult, evalConfig, eval.ExposureGroups())
 		if err != nil {
-			log.Errorf("runEval: failed to unmarshel %v into a CollectResult object with %v\n", args[0], err)
-			return
+			return // error printed by AttributeGrantGroups()
 		}
-	} else {
-		// Collect RBAC from remote cluster
-		collectResultPtr := collect.Collect(collectConfig)
-		if collectResultPtr == nil {
-			return // error printed by Collect()
+		identityResults := eval.ByIdentity(policyResults, grantGroups, topIdentities)
+		if outputFormat == "table" {
+			outputResults([]byte(report.IdentityTable(identityResults, colorOutput())))
+		} else if outputFormat == "markdown" {
+			outputResults([]byte(report.IdentityMarkdown(identityResults)))
+		} else {
+			output, err = marshalResults(identityResults)
+			if err != nil {
+				log.Errorln("outputPolicyResults: failed to marshal results by identity with", err)
+				return
+			}
+			outputResults(output)
 		}
-		collectResult = *collectResultPtr
+		return
 	}
 
-	policyResults := eval.Eval(policyPath, collectResult, evalConfig)
-	if policyResults == nil {
-		return // error printed by Collect()
+	if outputFormat == "table" {
+		outputResults([]byte(report.Table(policyResults, shortMode, colorOutput())))
+		return
+	} else if outputFormat == "markdown" {
+		outputResults([]byte(report.Markdown(policyResults, shortMode)))
+		return
+	} else if outputFormat == "html" {
+		var expandResult *expand.ExpandResult
+		if embedExpand {
+			expandResult = expand.Expand(collectResult)
+			if expandResult == nil {
+				return // error printed by Expand()
+			}
+		}
+		htmlReport, err := report.HTML(policyResults, collectResult.Metadata, expandResult, shortMode)
+		if err != nil {
+			return // error printed by HTML()
+		}
+		outputResults([]byte(htmlReport))
+		return
+	} else if outputFormat == "junit" {
+		junitReport, err := report.JUnit(policyResults, collectResult.Metadata, shortMode)
+		if err != nil {
+			return // error printed by JUnit()
+		}
+		outputResults([]byte(junitReport))
+		return
+	} else if outputFormat == "policyreport" {
+		policyReports := report.PolicyReports(policyResults, time.Now())
+		if offline {
+			// Offline, render reports
+			policyReportsYaml, err := report.PolicyReportsYaml(policyReports)
+			if err != nil {
+				return // error printed by PolicyReportsYaml()
+			}
+			outputResults([]byte(policyReportsYaml))
+		} else {
+			// Online, apply reports to the evaluated cluster
+			if err := report.ApplyPolicyReports(policyReports, collectConfig.Namespace); err != nil {
+				os.Exit(1) // error printed by ApplyPolicyReports()
+			}
+		}
+		return
+	} else if outputFormat == "auditpolicy" {
+		auditPolicy, err := report.AuditPolicy(policyResults, collectResult, report.AuditPolicyConfig{
+			EvalConfig:     evalConfig,
+			BasePolicyFile: baseAuditPolicy,
+			NodeUser:       collectConfig.NodeUser,
+		})
+		if err != nil {
+			return // error printed by AuditPolicy()
+		}
+		outputResults([]byte(auditPolicy))
+		return
 	}
 
 	if !shortMode {
 		output, err = marshalResults(policyResults)
 		if err != nil {
-			log.Errorln("runEval: failed to marshal results with", err)
+			log.Errorln("outputPolicyResults: failed to marshal results with", err)
 			return
 		}
 	} else {
 		abbreviatedResults := eval.AbbreviateResults(policyResults)
 		output, err = marshalResults(abbreviatedResults)
 		if err != nil {
-			log.Errorln("runEval: failed to marshal abbreviated results with", err)
+			log.Errorln("outputPolicyResults: failed to marshal abbreviated results with", err)
 			return
 		}
 	}
 	outputResults(output)
 }
 
-func init() {
-	evalCmd.Flags().BoolVar(&shortMode, "short", false, "abbreviate results")
-	evalCmd.Flags().BoolVarP(&evalConfig.DebugMode, "debug", "d", false, "debug mode, prints debug info and stdout of policies")
-	evalCmd.Flags().BoolVar(&evalConfig.OnlySasOnAllNodes, "only-sas-on-all-nodes", false, "only evaluate serviceAccounts that exist on all nodes")
-	evalCmd.Flags().StringVarP(&evalConfig.SeverityThreshold, "severity-threshold", "s", "Low", "only evaluate policies with severity >= threshold")
-	evalCmd.Flags().StringSliceVar(&evalConfig.IgnoredNamespaces, "ignored-namespaces", []string{}, "ignore serviceAccounts from certain namespaces during eval") // TODO: consider moving to collect and implement via field selectors
-	evalCmd.Flags().StringSliceVar(&violations, "violations", []string{"sa", "node", "combined"}, "violations to search for, beside default supports 'user', 'group' and 'all'")
+// Whether to colorize output, only when printing to a terminal and NO_COLOR isn't set
+func colorOutput() bool {
+	if outFile != "" || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	stat, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0
+}
 
+func init() {
+	evalCmd.Flags().StringArrayVar(&evalAuditLogs, "audit-log", []string{}, "audit log of JSON lines, optionally gzipped, annotate violations with how identities exercised the permissions that caused them, can be repeated")
+	addEvalFlags(evalCmd)
 	rootCmd.AddCommand(evalCmd)
 }
+
+// Adds the flags that configure evaluation and its output to @cmd
+func addEvalFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&shortMode, "short", false, "abbreviate results")
+	cmd.Flags().StringVarP(&outputFormat, "format", "f", "json", "output format, 'json', 'table', 'markdown', 'html', 'junit', 'policyreport' or 'auditpolicy'")
+	cmd.Flags().StringVar(&baseAuditPolicy, "merge-audit-policy", "", "existing audit policy file to merge the generated audit policy into, for the auditpolicy format")
+	cmd.Flags().BoolVar(&byIdentity, "by-identity", false, "output results per identity, ranked by a composite risk score, as json, table or markdown")
+	cmd.Flags().IntVar(&topIdentities, "top", 0, "only output the top identities by risk score, with --by-identity")
+	cmd.Flags().BoolVar(&embedExpand, "embed-expand", false, "embed the permissions of identities in html output, for drilling down from violations")
+	cmd.Flags().BoolVarP(&evalConfig.DebugMode, "debug", "d", false, "debug mode, prints debug info and stdout of policies")
+	cmd.Flags().BoolVar(&evalConfig.OnlySasOnAllNodes, "only-sas-on-all-nodes", false, "only evaluate serviceAccounts that exist on all nodes")
+	cmd.Flags().StringVarP(&evalConfig.SeverityThreshold, "severity-threshold", "s", "Low", "only evaluate policies with severity >= threshold")
+	cmd.Flags().StringSliceVar(&evalConfig.IncludeFilters, "include", []string{}, "only evaluate policies matching a policy ID, tag, category or file glob, e.g. 'RP-010', 'category:Acquire Tokens', 'file:*_secrets.rego'")
+	cmd.Flags().StringSliceVar(&evalConfig.ExcludeFilters, "exclude", []string{}, "don't evaluate policies matching a policy ID, tag, category or file glob")
+	cmd.Flags().StringSliceVar(&evalConfig.IgnoredNamespaces, "ignored-namespaces", []string{}, "ignore serviceAccounts from certain namespaces during eval") // TODO: consider moving to collect and implement via field selectors
+	cmd.Flags().StringSliceVar(&evalConfig.PrivilegedNamespaces, "privileged-namespaces", []string{"kube-system"}, "namespaces policies should treat as privileged")
+	cmd.Flags().StringVar(&evalConfig.PrivilegedNamespaceSelector, "privileged-namespaces-selector", "", "also treat namespaces matching this label selector as privileged, e.g. 'tier=0'")
+	cmd.Flags().StringArrayVar(&evalConfig.PolicyDataFiles, "policy-data", []string{}, "json or yaml file with data for policies, exposed under data.user, can be repeated")
+	cmd.Flags().StringSliceVar(&violations, "violations", []string{"sa", "node", "combined"}, "violations to search for, beside default supports 'user', 'group' and 'all'")
+	addAnonymizeFlags(cmd)
+}
@@ -68,6 +68,19 @@ func runExpand(cmd *cobra.Command, args []string) {
 		collectResult = *collectResultPtr
 	}
 
+	// Anonymize before expanding, so the expanded roles share the pseudonyms of the collected ones
+	anonymizer, ok := newAnonymizer()
+	if !ok {
+		return // error printed by newAnonymizer()
+	}
+	if anonymizer != nil {
+		anonymizer.CollectResult(&collectResult)
+		zoomedName, zoomedNamespace = anonymizer.Identity(zoomedType, zoomedName, zoomedNamespace)
+		if anonymizer.Save() != nil {
+			return // error printed by Save()
+		}
+	}
+
 	// Expand collection results
 	expandResult := expand.Expand(collectResult)
 	if expandResult == nil {
@@ -124,6 +137,7 @@ func runExpand(cmd *cobra.Command, args []string) {
 
 func init() {
 	expandCmd.Flags().StringVarP(&zoomedIdentity, "zoom", "z", "", "only show the permissions of the specified identity, format is 'type=identity', e.g. 'sa=kube-system:default', 'user=example@email.com'")
+	addAnonymizeFlags(expandCmd)
 	rootCmd.AddCommand(expandCmd)
 }
 
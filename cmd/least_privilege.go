@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/usage"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// leastPrivilegeCmd represents the least-privilege command
+var (
+	leastPrivilegeCmd = &cobra.Command{
+		Use:   "least-privilege [rbac-json]",
+		Short: "Proposes least-privilege roles based on the permissions identities exercised in audit logs",
+		Long: `Aggregates the verbs, resources and namespaces each serviceAccount and user exercised in Kubernetes audit logs (JSON lines, optionally gzipped),
+and compares them against the identity's permissions. Outputs the unused grants and a minimal set of roles per identity,
+and flags which violations of the policies would disappear under the minimal roles.`,
+		Run: runLeastPrivilege,
+	}
+
+	usageConfig usage.UsageConfig
+)
+
+func runLeastPrivilege(cmd *cobra.Command, args []string) {
+	var collectResult collect.CollectResult
+
+	if len(usageConfig.AuditLogs) == 0 {
+		fmt.Println("[!] No audit logs specified")
+		cmd.Help()
+		return
+	}
+
+	// Get RBAC input
+	if len(args) > 0 {
+		if collectionOptionsSet() {
+			fmt.Println("[!] Can only set collection options when collecting")
+			cmd.Help()
+			return
+		}
+		collectResultBytes, err := utils.ReadFile(args[0])
+		if err != nil {
+			return
+		}
+		err = json.Unmarshal(collectResultBytes, &collectResult)
+		if err != nil {
+			log.Errorf("runLeastPrivilege: failed to unmarshel %v into a CollectResult object with %v\n", args[0], err)
+			return
+		}
+	} else {
+		collectResultPtr := collect.Collect(collectConfig)
+		if collectResultPtr == nil {
+			return // error printed by Collect()
+		}
+		collectResult = *collectResultPtr
+	}
+
+	usageResult := usage.Analyze(collectResult, usageConfig)
+	if usageResult == nil {
+		return // error printed by Analyze()
+	}
+	output, err := marshalResults(usageResult)
+	if err != nil {
+		log.Errorln("runLeastPrivilege: failed to marshal results with", err)
+		return
+	}
+	outputResults(output)
+}
+
+func init() {
+	leastPrivilegeCmd.Flags().StringArrayVar(&usageConfig.AuditLogs, "audit-log", []string{}, "audit log file in JSON lines format, optionally gzipped, can be repeated")
+	leastPrivilegeCmd.Flags().StringVar(&usageConfig.PolicyPath, "policies", "lib", "policies to re-evaluate against the proposed roles, empty to skip")
+	leastPrivilegeCmd.Flags().StringVar(&usageConfig.OutDir, "out-dir", "", "directory to write the proposed roles and bindings to as manifests")
+	leastPrivilegeCmd.Flags().StringSliceVar(&usageConfig.EvalConfig.PrivilegedNamespaces, "privileged-namespaces", []string{"kube-system"}, "namespaces policies should treat as privileged")
+	leastPrivilegeCmd.Flags().StringVar(&usageConfig.EvalConfig.PrivilegedNamespaceSelector, "privileged-namespaces-selector", "", "also treat namespaces matching this label selector as privileged")
+	leastPrivilegeCmd.Flags().StringArrayVar(&usageConfig.EvalConfig.PolicyDataFiles, "policy-data", []string{}, "json or yaml file with data for policies")
+
+	rootCmd.AddCommand(leastPrivilegeCmd)
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/preflight"
+	"github.com/spf13/cobra"
+)
+
+// preflightCmd represents the preflight command
+var (
+	preflightCmd = &cobra.Command{
+		Use:   "preflight <policies> <chart-or-kustomization>",
+		Short: "Evaluates the RBAC violations a Helm chart or a kustomization would introduce, before it reaches a cluster",
+		Long: `Renders a Helm chart or a kustomization locally, merges the rendered RBAC objects, serviceAccounts and workloads into an offline cluster (--local-dir) or into an empty one,
+and evaluates the merged cluster using Rego policies. Only reports the violations attributable to the rendered manifests.
+Pods are synthesized from the pod templates of workloads, on each node they may be scheduled on.`,
+		Run: runPreflight,
+	}
+
+	preflightConfig preflight.PreflightConfig
+)
+
+func runPreflight(cmd *cobra.Command, args []string) {
+	if len(args) < 2 {
+		fmt.Println("[!] Both policies and a chart or kustomization are required")
+		cmd.Help()
+		return
+	}
+	if collectConfig.DiscoverProtections {
+		fmt.Println("[!] Cannot discover protections when evaluating rendered manifests")
+		cmd.Help()
+		return
+	}
+	if !validOutputOptions(cmd) || !setViolationTypes(cmd) {
+		return
+	}
+
+	preflightConfig.Source = args[1]
+	preflightConfig.CollectConfig = collectConfig
+	preflightConfig.EvalConfig = evalConfig
+	preflightResult := preflight.Preflight(args[0], preflightConfig)
+	if preflightResult == nil {
+		return // error printed by Preflight()
+	}
+	outputPolicyResults(preflightResult.PolicyResults, preflightResult.CollectResult, true)
+}
+
+func init() {
+	preflightCmd.Flags().StringArrayVar(&preflightConfig.ValuesFiles, "values", []string{}, "Helm values file, can be repeated")
+	preflightCmd.Flags().StringArrayVar(&preflightConfig.SetValues, "set", []string{}, "set Helm values, e.g. 'key1=val1,key2=val2', can be repeated")
+	preflightCmd.Flags().StringVar(&preflightConfig.ReleaseName, "release-name", "release", "Helm release name")
+	preflightCmd.Flags().StringVar(&preflightConfig.Namespace, "release-namespace", "default", "Helm release namespace, and namespace of rendered objects that don't specify one")
+	addEvalFlags(preflightCmd)
+
+	rootCmd.AddCommand(preflightCmd)
+}
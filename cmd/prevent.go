@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/prevent"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/remediate"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// preventCmd represents the prevent command
+var (
+	preventCmd = &cobra.Command{
+		Use:   "prevent <eval-result> <collect-result>",
+		Short: "Generates admission policies tailored to the violations found by eval",
+		Long: `Generates Gatekeeper ConstraintTemplates and Constraints, and Kyverno ClusterPolicies, tailored to the violations found by eval,
+e.g. denying the assignment of the admin-equivalent serviceAccounts eval flagged to new pods.
+Policies are validated offline against the schemas of their CRDs, and written as YAML files to the output directory. They're never applied to the cluster.`,
+		Run: runPrevent,
+	}
+
+	preventConfig prevent.PreventConfig
+)
+
+func runPrevent(cmd *cobra.Command, args []string) {
+	var collectResult collect.CollectResult
+
+	if len(args) < 2 {
+		fmt.Println("[!] Both eval and collect results are required")
+		cmd.Help()
+		return
+	}
+	if collectionOptionsSet() || collectConfig.OfflineDir != "" {
+		fmt.Println("[!] Can only set collection options when collecting")
+		cmd.Help()
+		return
+	}
+	for _, engine := range preventConfig.Engines {
+		if engine != prevent.EngineGatekeeper && engine != prevent.EngineKyverno {
+			fmt.Printf("[!] Unsupported engine '%s', supported engines are '%s' and '%s'\n", engine, prevent.EngineGatekeeper, prevent.EngineKyverno)
+			cmd.Help()
+			return
+		}
+	}
+
+	// Read eval results
+	policyResultsBytes, err := utils.ReadFile(args[0])
+	if err != nil {
+		return
+	}
+	policyResults, err := remediate.ParsePolicyResults(policyResultsBytes)
+	if err != nil {
+		return // error printed in ParsePolicyResults
+	}
+
+	// Read collect results
+	collectResultBytes, err := utils.ReadFile(args[1])
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(collectResultBytes, &collectResult)
+	if err != nil {
+		log.Errorf("runPrevent: failed to unmarshel %v into a CollectResult object with %v\n", args[1], err)
+		return
+	}
+
+	preventResult := prevent.Prevent(*policyResults, collectResult, preventConfig)
+	if preventResult == nil {
+		return // error printed by Prevent()
+	}
+	outputResults([]byte(prevent.FormatResult(preventResult)))
+}
+
+func init() {
+	preventCmd.Flags().StringVar(&preventConfig.OutDir, "out-dir", "prevent", "directory to write admission policies to")
+	preventCmd.Flags().StringSliceVar(&preventConfig.Engines, "engines", []string{prevent.EngineGatekeeper, prevent.EngineKyverno}, "admission controllers to generate policies for, 'gatekeeper' and / or 'kyverno'")
+
+	rootCmd.AddCommand(preventCmd)
+}
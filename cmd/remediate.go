@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/remediate"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// remediateCmd represents the remediate command
+var (
+	remediateCmd = &cobra.Command{
+		Use:   "remediate <eval-result> <collect-result>",
+		Short: "Proposes least-privilege patches for the violations found by eval",
+		Long: `Proposes least-privilege patches for the violations found by eval, and proves them by re-evaluating the violated policies against a patched copy of the cluster's RBAC data.
+Patches are written as YAML manifests to the output directory, and are never applied to the cluster.`,
+		Run: runRemediate,
+	}
+
+	remediateConfig remediate.RemediateConfig
+)
+
+func runRemediate(cmd *cobra.Command, args []string) {
+	var collectResult collect.CollectResult
+
+	if len(args) < 2 {
+		fmt.Println("[!] Both eval and collect results are required")
+		cmd.Help()
+		return
+	}
+	if collectionOptionsSet() || collectConfig.OfflineDir != "" {
+		fmt.Println("[!] Can only set collection options when collecting")
+		cmd.Help()
+		return
+	}
+
+	// Read eval results
+	policyResultsBytes, err := utils.ReadFile(args[0])
+	if err != nil {
+		return
+	}
+	policyResults, err := remediate.ParsePolicyResults(policyResultsBytes)
+	if err != nil {
+		return // error printed in ParsePolicyResults
+	}
+
+	// Read collect results
+	collectResultBytes, err := utils.ReadFile(args[1])
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(collectResultBytes, &collectResult)
+	if err != nil {
+		log.Errorf("runRemediate: failed to unmarshel %v into a CollectResult object with %v\n", args[1], err)
+		return
+	}
+
+	remediateResult := remediate.Remediate(*policyResults, collectResult, remediateConfig)
+	if remediateResult == nil {
+		return // error printed by Remediate()
+	}
+	outputResults([]byte(remediate.FormatResult(remediateResult)))
+}
+
+func init() {
+	remediateCmd.Flags().StringVar(&remediateConfig.OutDir, "out-dir", "remediation", "directory to write patch manifests to")
+	remediateCmd.Flags().StringSliceVar(&remediateConfig.EvalConfig.PrivilegedNamespaces, "privileged-namespaces", []string{"kube-system"}, "namespaces policies should treat as privileged, should match the eval run")
+	remediateCmd.Flags().StringVar(&remediateConfig.EvalConfig.PrivilegedNamespaceSelector, "privileged-namespaces-selector", "", "also treat namespaces matching this label selector as privileged, should match the eval run")
+	remediateCmd.Flags().StringArrayVar(&remediateConfig.EvalConfig.PolicyDataFiles, "policy-data", []string{}, "json or yaml file with data for policies, should match the eval run")
+
+	rootCmd.AddCommand(remediateCmd)
+}
@@ -44,8 +44,11 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&collectConfig.IgnoreControlPlane, "ignore-controlplane", false, "don't collect data on control plane nodes and pods. Identified by either the 'node-role.kubernetes.io/control-plane' or 'node-role.kubernetes.io/master' labels. ServiceAccounts will not be linked to control plane components")
 	rootCmd.PersistentFlags().StringSliceVar(&collectConfig.NodeGroups, "node-groups", []string{"system:nodes"}, "treat nodes as part of these groups")
 	rootCmd.PersistentFlags().StringVar(&collectConfig.NodeUser, "node-user", "", "user assigned to all nodes, default behaviour assumes nodes users are compatible with the NodeAuthorizer")
+	rootCmd.PersistentFlags().StringVar(&collectConfig.GroupMembership, "group-membership", "", "file mapping users to the groups they're members of, as static YAML, CSV with user and group columns, or the EKS aws-auth configmap")
+	rootCmd.PersistentFlags().StringSliceVar(&collectConfig.AssumeFeatures, "assume-features", []string{}, "features to consider enabled regardless of discovery, e.g. NodeRestriction when evaluating an offline snapshot")
+	rootCmd.PersistentFlags().StringSliceVar(&collectConfig.DenyFeatures, "deny-features", []string{}, "features to consider disabled, even if discovered or read from a snapshot")
 	rootCmd.PersistentFlags().StringVarP(&collectConfig.Namespace, "namespace", "n", "", "scope collection on serviceAccounts to a namespace")
-	rootCmd.PersistentFlags().StringVar(&collectConfig.OfflineDir, "local-dir", "", "offline mode, get cluster data from local files, see <rbac-police>/utils/get_cluster_data.sh")
+	rootCmd.PersistentFlags().StringVar(&collectConfig.OfflineDir, "local-dir", "", "offline mode, get cluster data from local manifests in a directory, a file, a .tar.gz archive or '-' for stdin, see the snapshot command")
 }
 
 // Prints and / or saves output to file
@@ -66,9 +69,9 @@ func outputResults(output []byte) {
 // Is an option related to collection is set
 func collectionOptionsSet() bool {
 	return collectConfig.IgnoreControlPlane || collectConfig.AllServiceAccounts ||
-		collectConfig.Namespace != "" || collectConfig.NodeUser != "" ||
+		collectConfig.Namespace != "" || collectConfig.NodeUser != "" || collectConfig.GroupMembership != "" ||
 		(len(collectConfig.NodeGroups) != 1 && collectConfig.NodeGroups[0] != "system:nodes") ||
-		collectConfig.DiscoverProtections
+		collectConfig.DiscoverProtections || len(collectConfig.AssumeFeatures) > 0 || len(collectConfig.DenyFeatures) > 0
 }
 
 // Marshal results into a json byte slice, indented based on the global jsonIndentLen variable
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/spf13/cobra"
+)
+
+// snapshotCmd represents the snapshot command
+var (
+	snapshotCmd = &cobra.Command{
+		Use:   "snapshot",
+		Short: "Saves the cluster data rbac-police needs for offline mode to a directory or a .tar.gz archive",
+		Long: `Saves the cluster data rbac-police needs for offline mode to a directory or a .tar.gz archive (-o), including the cluster's name, version and discovered features.
+The snapshot can be evaluated later or elsewhere via --local-dir.`,
+		Run: runSnapshot,
+	}
+
+	snapshotConfig collect.SnapshotConfig
+)
+
+func runSnapshot(cmd *cobra.Command, args []string) {
+	if outFile == "" {
+		fmt.Println("[!] A directory or a .tar.gz archive to save the snapshot to is required (-o)")
+		cmd.Help()
+		return
+	}
+	snapshotConfig.Output = outFile
+	if err := collect.Snapshot(collectConfig, snapshotConfig); err != nil {
+		return // error printed by Snapshot()
+	}
+	fmt.Printf("[+] Cluster data at %s\n", outFile)
+}
+
+func init() {
+	snapshotCmd.Flags().BoolVar(&snapshotConfig.Redact, "redact", false, "strip pods down to the fields rbac-police needs, i.e. their name, namespace, serviceAccount, node and the secrets, configMaps and volume claims they refer to")
+
+	rootCmd.AddCommand(snapshotCmd)
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/test"
+	"github.com/spf13/cobra"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// testCmd represents the test command
+var (
+	testCmd = &cobra.Command{
+		Use:   "test <policies>",
+		Short: "Tests Rego policies using Rego unit tests and fixture clusters",
+		Long: `Tests Rego policies using Rego unit tests and fixture clusters.
+Rego tests are read from '<policy>_test.rego' files, and fixtures from 'expected.yaml' files that list the violations
+policies should produce for an offline cluster directory. Exits with a non-zero code if a test fails.`,
+		Run: runTest,
+	}
+
+	testDebugMode bool
+)
+
+func runTest(cmd *cobra.Command, args []string) {
+	if len(args) < 1 {
+		fmt.Println("[!] No policies specified")
+		cmd.Help()
+		return
+	}
+	if testDebugMode {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	testResults := test.Test(args[0])
+	if testResults == nil {
+		os.Exit(1) // error printed by Test()
+	}
+
+	output, err := marshalResults(testResults)
+	if err != nil {
+		log.Errorln("runTest: failed to marshal results with", err)
+		os.Exit(1)
+	}
+	outputResults(output)
+
+	if testResults.Summary.Failed > 0 || testResults.Summary.Errors > 0 {
+		os.Exit(1)
+	}
+}
+
+func init() {
+	testCmd.Flags().BoolVarP(&testDebugMode, "debug", "d", false, "debug mode, prints debug info and the output of Rego tests")
+	rootCmd.AddCommand(testCmd)
+}
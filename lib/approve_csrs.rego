@@ -2,9 +2,16 @@ package policy
 import data.police_builtins as pb
 import future.keywords.in
 
-describe[{"desc": desc, "severity": severity}] {
+describe[{"id": id, "title": title, "desc": desc, "severity": severity, "category": category, "mitre": mitre, "remediation": remediation, "references": references, "version": version}] {
+  id := "RP-001"
+  title := "Approve certificate signing requests"
   desc := "Identities that can create and approve certificatesigningrequests can issue arbitrary certificates with cluster admin privileges"
-  severity := "Critical" 
+  severity := "Critical"
+  category := "Manipulate AuthN/AuthZ"
+  mitre := ["T1649"]
+  remediation := "Remove the permissions to update certificatesigningrequests/approval and to approve signers from the identity, or scope the approve permission to signers that can't issue client certificates via resourceNames."
+  references := ["https://kubernetes.io/docs/reference/access-authn-authz/certificate-signing-requests/", "https://www.paloaltonetworks.com/resources/whitepapers/kubernetes-privilege-escalation-excessive-permissions-in-popular-platforms"]
+  version := "1.0"
 }
 targets := {"serviceAccounts", "nodes", "combined", "users", "groups"}
 
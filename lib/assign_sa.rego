@@ -2,9 +2,16 @@ package policy
 import data.police_builtins as pb
 import future.keywords.in
 
-describe[{"desc": desc, "severity": severity}] {
+describe[{"id": id, "title": title, "desc": desc, "severity": severity, "category": category, "mitre": mitre, "remediation": remediation, "references": references, "version": version}] {
+  id := "RP-002"
+  title := "Assign serviceAccounts to pods in privileged namespaces"
   desc := sprintf("Identities that can create pods or create, update or patch pod controllers (e.g. DaemonSets, Deployments, Jobs) in privileged namespaces (%v), may assign an admin-equivalent SA to a pod in their control", [concat(", ", pb.privileged_namespaces)])
   severity := "Critical"
+  category := "Acquire Tokens"
+  mitre := ["T1610", "T1528"]
+  remediation := "Remove the permissions to create pods and to create, update or patch pod controllers in privileged namespaces, or move the workloads managed by the identity to a dedicated namespace."
+  references := ["https://kubernetes.io/docs/concepts/security/rbac-good-practices/", "https://www.paloaltonetworks.com/resources/whitepapers/kubernetes-privilege-escalation-excessive-permissions-in-popular-platforms"]
+  version := "1.0"
 }
 targets := {"serviceAccounts", "nodes", "users", "groups"}
 
@@ -2,9 +2,16 @@ package policy
 import data.police_builtins as pb
 import future.keywords.in
 
-describe[{"desc": desc, "severity": severity}] {
+describe[{"id": id, "title": title, "desc": desc, "severity": severity, "category": category, "mitre": mitre, "remediation": remediation, "references": references, "version": version}] {
+  id := "RP-003"
+  title := "Bind roles in privileged namespaces"
   desc := sprintf("Identities that can bind clusterrolebindings or bind rolebindings in privileged namespaces (%v) can grant admin-equivalent permissions to themselves", [concat(", ", pb.privileged_namespaces)])
   severity := "Critical"
+  category := "Manipulate AuthN/AuthZ"
+  mitre := ["T1098.006"]
+  remediation := "Remove the bind verb from the identity, or restrict it via resourceNames to the specific roles the identity needs to bind."
+  references := ["https://kubernetes.io/docs/reference/access-authn-authz/rbac/#privilege-escalation-prevention-and-bootstrapping", "https://www.paloaltonetworks.com/resources/whitepapers/kubernetes-privilege-escalation-excessive-permissions-in-popular-platforms"]
+  version := "1.0"
 }
 targets := {"serviceAccounts", "nodes", "users", "groups"}
 
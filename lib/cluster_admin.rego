@@ -2,9 +2,16 @@ package policy
 import data.police_builtins as pb
 import future.keywords.in
 
-describe[{"desc": desc, "severity": severity}] {
+describe[{"id": id, "title": title, "desc": desc, "severity": severity, "category": category, "mitre": mitre, "remediation": remediation, "references": references, "version": version}] {
+  id := "RP-004"
+  title := "Cluster admin privileges"
   desc := "Identities with cluster admin privileges pose a significant threat to the cluster if compromised"
   severity := "Critical"
+  category := "Cluster Admin"
+  mitre := ["T1078"]
+  remediation := "Replace the wildcard permissions with the specific verbs and resources the identity needs."
+  references := ["https://kubernetes.io/docs/concepts/security/rbac-good-practices/", "https://www.paloaltonetworks.com/resources/whitepapers/kubernetes-privilege-escalation-excessive-permissions-in-popular-platforms"]
+  version := "1.0"
 }
 targets := {"serviceAccounts", "nodes", "users", "groups"}
 
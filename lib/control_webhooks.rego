@@ -2,9 +2,16 @@ package policy
 import data.police_builtins as pb
 import future.keywords.in
 
-describe[{"desc": desc, "severity": severity}] {
+describe[{"id": id, "title": title, "desc": desc, "severity": severity, "category": category, "mitre": mitre, "remediation": remediation, "references": references, "version": version}] {
+  id := "RP-005"
+  title := "Control admission webhooks"
   desc := "Identities that can create, update or patch ValidatingWebhookConfigurations or MutatingWebhookConfigurations can read, and in the case of the latter also mutate, any object admitted to the cluster"
   severity := "High"
+  category := "Meddler-in-the-Middle"
+  mitre := ["T1557"]
+  remediation := "Remove the permissions to create, update or patch validatingwebhookconfigurations and mutatingwebhookconfigurations, or restrict them via resourceNames to the identity's own webhooks."
+  references := ["https://kubernetes.io/docs/reference/access-authn-authz/extensible-admission-controllers/", "https://www.paloaltonetworks.com/resources/whitepapers/kubernetes-privilege-escalation-excessive-permissions-in-popular-platforms"]
+  version := "1.0"
 }
 targets := {"serviceAccounts", "nodes", "users", "groups"}
 
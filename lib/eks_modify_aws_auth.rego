@@ -2,9 +2,16 @@ package policy
 import data.police_builtins as pb
 import future.keywords.in
 
-describe[{"desc": desc, "severity": severity}] {
+describe[{"id": id, "title": title, "desc": desc, "severity": severity, "category": category, "mitre": mitre, "remediation": remediation, "references": references, "version": version}] {
+  id := "RP-006"
+  title := "Modify the EKS aws-auth configmap"
   desc := "Identities that can modify configmaps in the kube-system namespace on EKS clusters can obtain cluster admin privileges by overwriting the aws-auth configmap"
   severity := "Critical"
+  category := "Manipulate AuthN/AuthZ"
+  mitre := ["T1098"]
+  remediation := "Remove the permissions to update or patch configmaps in kube-system, or restrict them via resourceNames to configmaps other than aws-auth. Consider EKS access entries instead of aws-auth."
+  references := ["https://docs.aws.amazon.com/eks/latest/userguide/add-user-role.html", "https://www.paloaltonetworks.com/resources/whitepapers/kubernetes-privilege-escalation-excessive-permissions-in-popular-platforms"]
+  version := "1.0"
 }
 targets := {"serviceAccounts", "nodes", "users", "groups"}
 
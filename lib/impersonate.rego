@@ -2,9 +2,16 @@ package policy
 import data.police_builtins as pb
 import future.keywords.in
 
-describe[{"desc": desc, "severity": severity}] {
+describe[{"id": id, "title": title, "desc": desc, "severity": severity, "category": category, "mitre": mitre, "remediation": remediation, "references": references, "version": version}] {
+  id := "RP-008"
+  title := "Impersonate identities"
   desc := "Identities that can impersonate users, groups or other serviceaccounts can escalate privileges by abusing the permissions of the impersonated identity"
   severity := "Critical"
+  category := "Manipulate AuthN/AuthZ"
+  mitre := ["T1078"]
+  remediation := "Remove the impersonate verb from the identity, or restrict it via resourceNames to the specific identities it needs to impersonate."
+  references := ["https://kubernetes.io/docs/reference/access-authn-authz/authentication/#user-impersonation", "https://www.paloaltonetworks.com/resources/whitepapers/kubernetes-privilege-escalation-excessive-permissions-in-popular-platforms"]
+  version := "1.0"
 }
 targets := {"serviceAccounts", "nodes", "users", "groups"}
 
@@ -2,9 +2,16 @@ package policy
 import data.police_builtins as pb
 import future.keywords.in
 
-describe[{"desc": desc, "severity": severity}] {
+describe[{"id": id, "title": title, "desc": desc, "severity": severity, "category": category, "mitre": mitre, "remediation": remediation, "references": references, "version": version}] {
+  id := "RP-009"
+  title := "Issue token secrets in privileged namespaces"
   desc := sprintf("Identities that can create or modify secrets in privileged namespaces (%v) can issue tokens for admin-equivalent SAs", [concat(", ", pb.privileged_namespaces)])
   severity := "Critical"
+  category := "Acquire Tokens"
+  mitre := ["T1528"]
+  remediation := "Remove the permissions to create, update or patch secrets in privileged namespaces, or restrict update and patch via resourceNames."
+  references := ["https://kubernetes.io/docs/concepts/configuration/secret/#service-account-token-secrets", "https://www.paloaltonetworks.com/resources/whitepapers/kubernetes-privilege-escalation-excessive-permissions-in-popular-platforms"]
+  version := "1.0"
 }
 targets := {"serviceAccounts", "nodes", "users", "groups"}
 
@@ -13,8 +20,8 @@ evaluateRoles(roles, owner) {
   pb.affectsPrivNS(role)
   some rule in role.rules
   pb.valueOrWildcard(rule.resources, "secrets")
-  pb.createUpdatePatchOrWildcard(rule.verbs)
   pb.valueOrWildcard(rule.apiGroups, "")
+  canIssueToken(role, rule.verbs)
   # TODO: Improve accuracy, only alert when rules grant 
   # the following perm bundles over privileged namespaces (port any improvments to obtain_token_weak_ns)
   #  [*] create && get && no resource names 
@@ -25,4 +32,18 @@ evaluateRoles(roles, owner) {
   #  [*] update || patch && no resource names
   #     - with resource names the secret most likey already exists 
   #       and isn't of type SA token
-} 
+}
+
+# Create - manually create a token secret for a serviceAccount
+canIssueToken(role, verbs) {
+  pb.valueOrWildcard(verbs, "create")
+}
+# Update & Patch - modify existing token secrets, only if serviceAccounts
+# in scope have long-lived tokens, or if it's unknown whether they do
+canIssueToken(role, verbs) {
+  pb.updateOrPatchOrWildcard(verbs)
+  not pb.legacyTokensKnown
+} {
+  pb.updateOrPatchOrWildcard(verbs)
+  pb.legacyTokenInScope(role, pb.privileged_namespaces)
+}
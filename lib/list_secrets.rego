@@ -2,9 +2,16 @@ package policy
 import data.police_builtins as pb
 import future.keywords.in
 
-describe[{"desc": desc, "severity": severity}] {
+describe[{"id": id, "title": title, "desc": desc, "severity": severity, "category": category, "mitre": mitre, "remediation": remediation, "references": references, "version": version}] {
+  id := "RP-010"
+  title := "List secrets cluster-wide"
   desc := "Identities that can list secrets cluster-wide may access confidential information, and in some cases serviceAccount tokens"
   severity := "Medium"
+  category := "Acquire Tokens"
+  mitre := ["T1552.007", "T1528"]
+  remediation := "Replace the cluster-wide permission to list secrets with namespaced roles, and restrict access to specific secrets via resourceNames."
+  references := ["https://kubernetes.io/docs/concepts/security/rbac-good-practices/", "https://www.paloaltonetworks.com/resources/whitepapers/kubernetes-privilege-escalation-excessive-permissions-in-popular-platforms"]
+  version := "1.0"
 }
 targets := {"serviceAccounts", "nodes", "users", "groups"}
 
@@ -1,9 +1,16 @@
 package policy
 import data.police_builtins as pb
 
-describe[{"desc": desc, "severity": severity}] {
+describe[{"id": id, "title": title, "desc": desc, "severity": severity, "category": category, "mitre": mitre, "remediation": remediation, "references": references, "version": version}] {
+  id := "RP-011"
+  title := "Modify node status"
   desc := "Identities that can modify nodes' status can set or remove labels to affect scheduling constraints enforced via nodeAffinity or nodeSelectors"
   severity := "Low"
+  category := "Steal Pods"
+  mitre := []
+  remediation := "Remove the permissions to update or patch nodes/status. For nodes, enable the NodeRestriction admission controller."
+  references := ["https://kubernetes.io/docs/reference/access-authn-authz/admission-controllers/#noderestriction", "https://www.paloaltonetworks.com/resources/whitepapers/kubernetes-privilege-escalation-excessive-permissions-in-popular-platforms"]
+  version := "1.0"
 }
 targets := {"serviceAccounts", "nodes", "users", "groups"}
 
@@ -1,9 +1,16 @@
 package policy
 import data.police_builtins as pb
 
-describe[{"desc": desc, "severity": severity}] {
+describe[{"id": id, "title": title, "desc": desc, "severity": severity, "category": category, "mitre": mitre, "remediation": remediation, "references": references, "version": version}] {
+  id := "RP-012"
+  title := "Modify pod status"
   desc := "Identities that can modify pods' status may match a pod's labels to services' selectors in order to intercept connections to services in the pod's namespace"
   severity := "Low"
+  category := "Meddler-in-the-Middle"
+  mitre := ["T1557"]
+  remediation := "Remove the permissions to update or patch pods/status. For nodes, enable the NodeRestriction admission controller."
+  references := ["https://kubernetes.io/docs/reference/access-authn-authz/admission-controllers/#noderestriction", "https://www.paloaltonetworks.com/resources/whitepapers/kubernetes-privilege-escalation-excessive-permissions-in-popular-platforms"]
+  version := "1.0"
 }
 targets := {"serviceAccounts", "nodes", "users", "groups"}
 
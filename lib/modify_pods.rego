@@ -2,9 +2,16 @@ package policy
 import data.police_builtins as pb
 import future.keywords.in
 
-describe[{"desc": desc, "severity": severity}] {
+describe[{"id": id, "title": title, "desc": desc, "severity": severity, "category": category, "mitre": mitre, "remediation": remediation, "references": references, "version": version}] {
+  id := "RP-013"
+  title := "Modify pods in privileged namespaces"
   desc := sprintf("Identities that can update or patch pods in privileged namespaces (%v) can gain code execution on pods that are likely to be powerful", [concat(", ", pb.privileged_namespaces)])
   severity := "High"
+  category := "Remote Code Execution"
+  mitre := ["T1610"]
+  remediation := "Remove the permissions to update or patch pods in privileged namespaces, or restrict them via resourceNames."
+  references := ["https://kubernetes.io/docs/concepts/security/rbac-good-practices/", "https://www.paloaltonetworks.com/resources/whitepapers/kubernetes-privilege-escalation-excessive-permissions-in-popular-platforms"]
+  version := "1.0"
 }
 targets := {"serviceAccounts", "nodes", "users", "groups"}
 
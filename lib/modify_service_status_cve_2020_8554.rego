@@ -1,9 +1,16 @@
 package policy
 import data.police_builtins as pb
 
-describe[{"desc": desc, "severity": severity}] {
+describe[{"id": id, "title": title, "desc": desc, "severity": severity, "category": category, "mitre": mitre, "remediation": remediation, "references": references, "version": version}] {
+  id := "RP-014"
+  title := "Modify service status (CVE-2020-8554)"
   desc := "Identities that can modify services/status may set the status.loadBalancer.ingress.ip field to exploit the unfixed CVE-2020-8554 and launch MiTM attacks against the cluster. Most mitigations for CVE-2020-8554 only prevent ExternalIP services"
   severity := "Medium"
+  category := "Meddler-in-the-Middle"
+  mitre := ["T1557"]
+  remediation := "Remove the permissions to update or patch services/status, which are rarely needed outside of cloud controllers."
+  references := ["https://github.com/kubernetes/kubernetes/issues/97076", "https://www.paloaltonetworks.com/resources/whitepapers/kubernetes-privilege-escalation-excessive-permissions-in-popular-platforms"]
+  version := "1.0"
 }
 targets := {"serviceAccounts", "nodes", "users", "groups"}
 
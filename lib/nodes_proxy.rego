@@ -1,9 +1,16 @@
 package policy
 import data.police_builtins as pb
 
-describe[{"desc": desc, "severity": severity}] {
+describe[{"id": id, "title": title, "desc": desc, "severity": severity, "category": category, "mitre": mitre, "remediation": remediation, "references": references, "version": version}] {
+  id := "RP-015"
+  title := "Access the nodes/proxy subresource"
   desc := "Identities with access to the nodes/proxy subresource can execute code on pods via the Kubelet API"
   severity := "High"
+  category := "Remote Code Execution"
+  mitre := ["T1609"]
+  remediation := "Remove access to the nodes/proxy subresource. Monitoring agents can usually use the metrics endpoints instead."
+  references := ["https://kubernetes.io/docs/concepts/security/rbac-good-practices/#access-to-proxy-subresource-of-nodes", "https://www.paloaltonetworks.com/resources/whitepapers/kubernetes-privilege-escalation-excessive-permissions-in-popular-platforms"]
+  version := "1.0"
 }
 targets := {"serviceAccounts", "nodes", "users", "groups"}
 
@@ -2,9 +2,16 @@ package policy
 import data.police_builtins as pb
 import future.keywords.in
 
-describe[{"desc": desc, "severity": severity}] {
+describe[{"id": id, "title": title, "desc": desc, "severity": severity, "category": category, "mitre": mitre, "remediation": remediation, "references": references, "version": version}] {
+  id := "RP-016"
+  title := "Obtain serviceAccount tokens in unprivileged namespaces"
   desc := "Identities that can retrieve or issue SA tokens in unprivileged namespaces could potentially obtain tokens with broader permissions over the cluster"
   severity := "Low"
+  category := "Acquire Tokens"
+  mitre := ["T1528"]
+  remediation := "Remove the permissions to retrieve or issue serviceAccount tokens, or to control the serviceAccount of pods, in namespaces hosting serviceAccounts with broad permissions."
+  references := ["https://kubernetes.io/docs/concepts/configuration/secret/#service-account-token-secrets", "https://www.paloaltonetworks.com/resources/whitepapers/kubernetes-privilege-escalation-excessive-permissions-in-popular-platforms"]
+  version := "1.0"
 }
 targets := {"serviceAccounts", "nodes", "users", "groups"}
 
@@ -12,20 +19,20 @@ evaluateRoles(roles, owner) {
   some role in roles
   not pb.affectsPrivNS(role)  # don't overlap with policy for token retrieval in privileged namespaces
   some rule in role.rules
-  ruleCanObtainToken(rule, owner)
+  ruleCanObtainToken(role, rule, owner)
 } 
 
 # This runs the retrieve_secrets, token_request, issue_token_secrets and assign_sa policies, but for unprivileged namespaces
-ruleCanObtainToken(rule, ruleOwner) {
-  ruleCanAcquireToken(rule, ruleOwner) 
+ruleCanObtainToken(role, rule, ruleOwner) {
+  ruleCanAcquireToken(role, rule, ruleOwner) 
   pb.valueOrWildcard(rule.apiGroups, "")
 } {
   pb.ruleCanControlPodSa(rule, ruleOwner) 
 }
 
-ruleCanAcquireToken(rule, ruleOwner) {
+ruleCanAcquireToken(role, rule, ruleOwner) {
   pb.valueOrWildcard(rule.resources, "secrets")
-  canAbuseSecretsForToken(rule.verbs)
+  canAbuseSecretsForToken(role, rule.verbs)
 } {
   not pb.nodeRestrictionEnabledAndIsNode(ruleOwner)
   pb.subresourceOrWildcard(rule.resources, "serviceaccounts/token")
@@ -36,11 +43,17 @@ ruleCanAcquireToken(rule, ruleOwner) {
 # List - retreive secrets (retrieve_secrets)
 # Create - mannualy create a token secret (issue_token_secrets)
 # Update & Patch - modfiy secret (issue_token_secrets), TODO: probably not exploitable if resourceNames is present?
-canAbuseSecretsForToken(verbs) {
-  not pb.legacyTokenSecretsReducted
+canAbuseSecretsForToken(role, verbs) {
+  pb.legacyTokensMayExist(role, {role.effectiveNamespace})
   listOrGet(verbs)
 } {
-  pb.createUpdatePatchOrWildcard(verbs)
+  pb.valueOrWildcard(verbs, "create")
+} {
+  pb.updateOrPatchOrWildcard(verbs)
+  not pb.legacyTokensKnown
+} {
+  pb.updateOrPatchOrWildcard(verbs)
+  pb.legacyTokenInScope(role, {role.effectiveNamespace})
 }
 
 listOrGet(verbs) {
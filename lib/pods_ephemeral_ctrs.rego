@@ -1,9 +1,16 @@
 package policy
 import data.police_builtins as pb
 
-describe[{"desc": desc, "severity": severity}] {
+describe[{"id": id, "title": title, "desc": desc, "severity": severity, "category": category, "mitre": mitre, "remediation": remediation, "references": references, "version": version}] {
+  id := "RP-017"
+  title := "Add ephemeral containers to pods"
   desc := "Identities that can update or patch pods/ephemeralcontainers can gain code execution on other pods, and potentially break out to their node by adding an ephemeral container with a privileged securityContext"
   severity := "High"
+  category := "Remote Code Execution"
+  mitre := ["T1609", "T1611"]
+  remediation := "Remove the permissions to update or patch pods/ephemeralcontainers, and enforce a Pod Security Standard that blocks privileged containers."
+  references := ["https://kubernetes.io/docs/concepts/workloads/pods/ephemeral-containers/", "https://www.paloaltonetworks.com/resources/whitepapers/kubernetes-privilege-escalation-excessive-permissions-in-popular-platforms"]
+  version := "1.0"
 }
 targets := {"serviceAccounts", "nodes", "users", "groups"}
 
@@ -3,9 +3,16 @@ import data.police_builtins as pb
 import data.config
 import future.keywords.in
 
-describe[{"desc": desc, "severity": severity}] {
+describe[{"id": id, "title": title, "desc": desc, "severity": severity, "category": category, "mitre": mitre, "remediation": remediation, "references": references, "version": version}] {
+  id := "RP-019"
+  title := "Cloud provider IAM roles"
   desc := "Kubernetes ServiceAccounts assigned cloud provider IAM roles may be abused to attack the underlying cloud account (depending on the permissions of the IAM role)"
   severity := "Low"
+  category := "Cloud Access"
+  mitre := ["T1078.004"]
+  remediation := "Review the permissions of the cloud IAM role bound to the serviceAccount and reduce them to the minimum the workload needs."
+  references := ["https://docs.aws.amazon.com/eks/latest/userguide/iam-roles-for-service-accounts.html", "https://cloud.google.com/kubernetes-engine/docs/how-to/workload-identity", "https://www.paloaltonetworks.com/resources/whitepapers/kubernetes-privilege-escalation-excessive-permissions-in-popular-platforms"]
+  version := "1.0"
 }
 
 main[{"violations": violation}] {
@@ -2,9 +2,16 @@ package policy
 import data.police_builtins as pb
 import future.keywords.in
 
-describe[{"desc": desc, "severity": severity}] {
+describe[{"id": id, "title": title, "desc": desc, "severity": severity, "category": category, "mitre": mitre, "remediation": remediation, "references": references, "version": version}] {
+  id := "RP-020"
+  title := "Execute code on pods in unprivileged namespaces"
   desc := "Identities that can update or patch pods or create pods/exec in unprivileged namespaces can execute code on existing pods"
   severity := "Medium"
+  category := "Remote Code Execution"
+  mitre := ["T1609"]
+  remediation := "Remove the permissions to update or patch pods and to create pods/exec, or restrict them via resourceNames."
+  references := ["https://kubernetes.io/docs/concepts/security/rbac-good-practices/", "https://www.paloaltonetworks.com/resources/whitepapers/kubernetes-privilege-escalation-excessive-permissions-in-popular-platforms"]
+  version := "1.0"
 }
 targets := {"serviceAccounts", "nodes", "users", "groups"}
 
@@ -2,19 +2,37 @@ package policy
 import data.police_builtins as pb
 import future.keywords.in
 
-describe[{"desc": desc, "severity": severity}] {
+describe[{"id": id, "title": title, "desc": desc, "severity": severity, "category": category, "mitre": mitre, "remediation": remediation, "references": references, "version": version}] {
+  id := "RP-021"
+  title := "Retrieve secrets in privileged namespaces"
   desc := sprintf("Identities that can retrieve secrets in privileged namespaces (%v) can obtain tokens of admin-equivalent SAs", [concat(", ", pb.privileged_namespaces)])
   severity := "Critical"
+  category := "Acquire Tokens"
+  mitre := ["T1528", "T1552.007"]
+  remediation := "Remove the permissions to get or list secrets in privileged namespaces, or restrict them via resourceNames. Migrate away from long-lived serviceAccount token secrets."
+  references := ["https://kubernetes.io/docs/concepts/configuration/secret/#service-account-token-secrets", "https://www.paloaltonetworks.com/resources/whitepapers/kubernetes-privilege-escalation-excessive-permissions-in-popular-platforms"]
+  version := "1.0"
 }
 targets := {"serviceAccounts", "nodes", "users", "groups"}
 
 evaluateRoles(roles, owner) {
-  not pb.legacyTokenSecretsReducted
   some role in roles
   pb.affectsPrivNS(role)
   some rule in role.rules
   pb.valueOrWildcard(rule.resources, "secrets")
   pb.getOrListOrWildcard(rule.verbs) # get -> bruteforcing token secrets names
   pb.valueOrWildcard(rule.apiGroups, "")
+  canRetrieveToken(role, rule)
+}
+
+canRetrieveToken(role, rule) {
   not pb.hasKey(rule, "resourceNames")
-} 
+  pb.legacyTokensMayExist(role, pb.privileged_namespaces)
+} {
+  # Restricted via resourceNames, but to the long-lived token of a serviceAccount
+  some sa in input.serviceAccounts
+  sa.namespace in pb.privileged_namespaces
+  pb.notNamespacedOrNamespace(role, sa.namespace)
+  some secret in sa.legacyToken.secrets
+  secret in rule.resourceNames
+}
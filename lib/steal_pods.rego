@@ -2,9 +2,16 @@ package policy
 import data.police_builtins as pb
 import future.keywords.in
 
-describe[{"desc": desc, "severity": severity}] {
+describe[{"id": id, "title": title, "desc": desc, "severity": severity, "category": category, "mitre": mitre, "remediation": remediation, "references": references, "version": version}] {
+  id := "RP-022"
+  title := "Steal pods from other nodes"
   desc := sprintf("Identities that can delete or evict pods in privileged namespaces (%v) and also make other nodes unschedulable can steal powerful pods from other nodes onto a compromised one", [concat(", ", pb.privileged_namespaces)])
   severity := "High"
+  category := "Steal Pods"
+  mitre := []
+  remediation := "Remove either the permissions to delete or evict pods in privileged namespaces, or the permissions to make nodes unschedulable. For nodes, enable the NodeRestriction admission controller."
+  references := ["https://kubernetes.io/docs/reference/access-authn-authz/admission-controllers/#noderestriction", "https://www.paloaltonetworks.com/resources/whitepapers/kubernetes-privilege-escalation-excessive-permissions-in-popular-platforms"]
+  version := "1.0"
 }
 targets := {"serviceAccounts", "nodes", "combined", "users", "groups"}
 
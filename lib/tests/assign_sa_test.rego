@@ -0,0 +1,17 @@
+package policy
+
+test_create_pods_in_privileged_namespace {
+  evaluateRoles([{"name": "pods", "effectiveNamespace": "kube-system", "rules": [{"apiGroups": [""], "resources": ["pods"], "verbs": ["create"]}]}], "serviceAccount")
+}
+
+test_patch_deployments_cluster_wide {
+  evaluateRoles([{"name": "deploy", "rules": [{"apiGroups": ["apps"], "resources": ["deployments"], "verbs": ["patch"]}]}], "user")
+}
+
+test_node_create_pods_with_node_restriction {
+  not evaluateRoles([{"name": "pods", "rules": [{"apiGroups": [""], "resources": ["pods"], "verbs": ["create"]}]}], "node") with input as {"metadata": {"features": ["NodeRestriction"]}}
+}
+
+test_create_pods_in_unprivileged_namespace {
+  not evaluateRoles([{"name": "pods", "effectiveNamespace": "default", "rules": [{"apiGroups": [""], "resources": ["pods"], "verbs": ["create"]}]}], "serviceAccount")
+}
@@ -0,0 +1,76 @@
+package police_builtins
+
+node_with_referenced_secrets := {
+  "name": "node-a",
+  "nodeAuthorizer": {
+    "secrets": [
+      {"name": "web-tls", "namespace": "default", "pods": ["web-1"]},
+      {"name": "proxy-creds", "namespace": "kube-system", "pods": ["kube-proxy-abcde"]}
+    ]
+  }
+}
+
+test_node_reads_referenced_secret {
+  nodeCanReadSecret(node_with_referenced_secrets, "default", "web-tls")
+}
+
+test_node_doesnt_read_unreferenced_secret {
+  not nodeCanReadSecret(node_with_referenced_secrets, "default", "db-password")
+}
+
+test_node_doesnt_read_referenced_name_in_other_namespace {
+  not nodeCanReadSecret(node_with_referenced_secrets, "kube-system", "web-tls")
+}
+
+test_node_without_node_authorizer {
+  not nodeCanReadSecret({"name": "node-a"}, "default", "web-tls")
+}
+
+test_node_authorizer_can_access_kind {
+  nodeAuthorizerCanAccess(node_with_referenced_secrets, "secrets", "kube-system", "proxy-creds")
+  not nodeAuthorizerCanAccess(node_with_referenced_secrets, "configMaps", "kube-system", "proxy-creds")
+}
+
+test_node_secrets_in_privileged_namespaces {
+  nodeSecretsInPrivNS(node_with_referenced_secrets) == {"kube-system:proxy-creds"}
+}
+
+test_node_secrets_in_configured_privileged_namespaces {
+  nodeSecretsInPrivNS(node_with_referenced_secrets) == {"default:web-tls"} with data.config.privilegedNamespaces as ["default"]
+}
+
+test_readable_secrets_honor_namespace_and_resource_names {
+  roles := [
+    {"name": "read-web-tls", "effectiveNamespace": "default", "rules": [{"apiGroups": [""], "resources": ["secrets"], "verbs": ["get"], "resourceNames": ["web-tls"]}]}
+  ]
+  secrets := [
+    {"name": "web-tls", "namespace": "default", "type": "kubernetes.io/tls"},
+    {"name": "db-password", "namespace": "default", "type": "Opaque"},
+    {"name": "web-tls", "namespace": "prod", "type": "kubernetes.io/tls"}
+  ]
+  readableSecrets(roles) == {{"name": "web-tls", "namespace": "default", "type": "kubernetes.io/tls"}} with input as {"secrets": secrets}
+}
+
+test_readable_secrets_cluster_wide {
+  roles := [{"name": "secret-reader", "rules": [{"apiGroups": [""], "resources": ["secrets"], "verbs": ["list"]}]}]
+  count(readableSecrets(roles)) == 2 with input as {"secrets": [{"name": "a", "namespace": "default"}, {"name": "b", "namespace": "kube-system"}]}
+}
+
+test_readable_secrets_not_collected {
+  roles := [{"name": "secret-reader", "rules": [{"apiGroups": [""], "resources": ["secrets"], "verbs": ["*"]}]}]
+  readableSecrets(roles) == set()
+}
+
+test_legacy_tokens_known_if_secrets_listed {
+  legacyTokensKnown with input as {"secrets": []}
+}
+
+test_legacy_tokens_unknown_if_secrets_not_listed {
+  not legacyTokensKnown with input as {"secrets": null}
+  not legacyTokensKnown with input as {}
+}
+
+test_readable_secrets_not_listed {
+  roles := [{"name": "secret-reader", "rules": [{"apiGroups": [""], "resources": ["secrets"], "verbs": ["*"]}]}]
+  readableSecrets(roles) == set() with input as {"secrets": null}
+}
@@ -0,0 +1,13 @@
+package policy
+
+test_wildcard_cluster_role {
+  evaluateRoles([{"name": "cluster-admin", "rules": [{"apiGroups": ["*"], "resources": ["*"], "verbs": ["*"]}]}], "serviceAccount")
+}
+
+test_wildcard_namespaced_role {
+  not evaluateRoles([{"name": "admin", "effectiveNamespace": "kube-system", "rules": [{"apiGroups": ["*"], "resources": ["*"], "verbs": ["*"]}]}], "serviceAccount")
+}
+
+test_wildcard_verbs_only {
+  not evaluateRoles([{"name": "pods-admin", "rules": [{"apiGroups": [""], "resources": ["pods"], "verbs": ["*"]}]}], "serviceAccount")
+}
@@ -0,0 +1,13 @@
+package policy
+
+test_patch_aws_auth_on_eks {
+  evaluateRoles([{"name": "cm", "effectiveNamespace": "kube-system", "rules": [{"apiGroups": [""], "resources": ["configmaps"], "verbs": ["patch"], "resourceNames": ["aws-auth"]}]}], "serviceAccount") with input as {"metadata": {"platform": "eks"}}
+}
+
+test_patch_other_configmap_on_eks {
+  not evaluateRoles([{"name": "cm", "effectiveNamespace": "kube-system", "rules": [{"apiGroups": [""], "resources": ["configmaps"], "verbs": ["patch"], "resourceNames": ["coredns"]}]}], "serviceAccount") with input as {"metadata": {"platform": "eks"}}
+}
+
+test_patch_configmaps_on_gke {
+  not evaluateRoles([{"name": "cm", "rules": [{"apiGroups": [""], "resources": ["configmaps"], "verbs": ["patch"]}]}], "serviceAccount") with input as {"metadata": {"platform": "gke"}}
+}
@@ -0,0 +1,13 @@
+package policy
+
+test_impersonate_users {
+  evaluateRoles([{"name": "impersonator", "rules": [{"apiGroups": [""], "resources": ["users"], "verbs": ["impersonate"]}]}], "serviceAccount")
+}
+
+test_impersonate_userextras {
+  evaluateRoles([{"name": "impersonator", "rules": [{"apiGroups": ["authentication.k8s.io"], "resources": ["userextras"], "verbs": ["impersonate"]}]}], "group")
+}
+
+test_get_users {
+  not evaluateRoles([{"name": "reader", "rules": [{"apiGroups": [""], "resources": ["users"], "verbs": ["get"]}]}], "serviceAccount")
+}
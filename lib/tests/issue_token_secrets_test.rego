@@ -0,0 +1,17 @@
+package policy
+
+test_create_secrets_in_privileged_namespace {
+  evaluateRoles([{"name": "secrets", "effectiveNamespace": "kube-system", "rules": [{"apiGroups": [""], "resources": ["secrets"], "verbs": ["create"]}]}], "serviceAccount") with input as {"secrets": [], "serviceAccounts": []}
+}
+
+test_patch_secrets_without_legacy_tokens {
+  not evaluateRoles([{"name": "secrets", "rules": [{"apiGroups": [""], "resources": ["secrets"], "verbs": ["patch"]}]}], "serviceAccount") with input as {"secrets": [], "serviceAccounts": []}
+}
+
+test_patch_secrets_with_legacy_token {
+  evaluateRoles([{"name": "secrets", "rules": [{"apiGroups": [""], "resources": ["secrets"], "verbs": ["patch"]}]}], "serviceAccount") with input as {"secrets": [], "serviceAccounts": [{"name": "old-sa", "namespace": "kube-system", "legacyToken": {"secrets": ["old-sa-token-abcde"]}}]}
+}
+
+test_update_secrets_when_legacy_tokens_unknown {
+  evaluateRoles([{"name": "secrets", "rules": [{"apiGroups": [""], "resources": ["secrets"], "verbs": ["update"]}]}], "serviceAccount")
+}
@@ -0,0 +1,17 @@
+package policy
+
+test_create_nodes_proxy {
+  evaluateRoles([{"name": "proxy", "rules": [{"apiGroups": [""], "resources": ["nodes/proxy"], "verbs": ["create"]}]}], "serviceAccount")
+}
+
+test_wildcard_subresource {
+  evaluateRoles([{"name": "proxy", "rules": [{"apiGroups": [""], "resources": ["*/proxy"], "verbs": ["*"]}]}], "user")
+}
+
+test_get_nodes_proxy {
+  not evaluateRoles([{"name": "proxy", "rules": [{"apiGroups": [""], "resources": ["nodes/proxy"], "verbs": ["get"]}]}], "serviceAccount")
+}
+
+test_node_with_node_restriction {
+  not evaluateRoles([{"name": "proxy", "rules": [{"apiGroups": [""], "resources": ["nodes/proxy"], "verbs": ["create"]}]}], "node") with input as {"metadata": {"features": ["NodeRestriction"]}}
+}
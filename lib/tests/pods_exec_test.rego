@@ -0,0 +1,21 @@
+package policy
+
+test_exec_in_privileged_namespace {
+  evaluateRoles([{"name": "exec", "effectiveNamespace": "kube-system", "rules": [{"apiGroups": [""], "resources": ["pods/exec"], "verbs": ["create"]}]}], "serviceAccount")
+}
+
+test_exec_cluster_wide {
+  evaluateRoles([{"name": "exec", "rules": [{"apiGroups": [""], "resources": ["pods/exec"], "verbs": ["create"]}]}], "group")
+}
+
+test_exec_in_unprivileged_namespace {
+  not evaluateRoles([{"name": "exec", "effectiveNamespace": "default", "rules": [{"apiGroups": [""], "resources": ["pods/exec"], "verbs": ["create"]}]}], "serviceAccount")
+}
+
+test_exec_in_configured_privileged_namespace {
+  evaluateRoles([{"name": "exec", "effectiveNamespace": "istio-system", "rules": [{"apiGroups": [""], "resources": ["pods/exec"], "verbs": ["create"]}]}], "serviceAccount") with data.config.privilegedNamespaces as ["kube-system", "istio-system"]
+}
+
+test_exec_with_resource_names {
+  not evaluateRoles([{"name": "exec", "rules": [{"apiGroups": [""], "resources": ["pods/exec"], "verbs": ["create"], "resourceNames": ["debug"]}]}], "serviceAccount")
+}
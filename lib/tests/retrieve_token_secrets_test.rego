@@ -0,0 +1,29 @@
+package policy
+
+test_list_secrets_in_privileged_namespace {
+  evaluateRoles([{"name": "secrets", "effectiveNamespace": "kube-system", "rules": [{"apiGroups": [""], "resources": ["secrets"], "verbs": ["list"]}]}], "serviceAccount")
+}
+
+test_get_secrets_cluster_wide {
+  evaluateRoles([{"name": "secrets", "rules": [{"apiGroups": [""], "resources": ["secrets"], "verbs": ["get"]}]}], "node")
+}
+
+test_legacy_token_secrets_reducted {
+  not evaluateRoles([{"name": "secrets", "rules": [{"apiGroups": [""], "resources": ["secrets"], "verbs": ["get"]}]}], "serviceAccount") with input as {"metadata": {"features": ["LegacyTokenSecretsReducted"]}}
+}
+
+test_secrets_in_unprivileged_namespace {
+  not evaluateRoles([{"name": "secrets", "effectiveNamespace": "default", "rules": [{"apiGroups": [""], "resources": ["secrets"], "verbs": ["list"]}]}], "serviceAccount")
+}
+
+test_legacy_token_of_serviceaccount_despite_reducted {
+  evaluateRoles([{"name": "secrets", "rules": [{"apiGroups": [""], "resources": ["secrets"], "verbs": ["get"]}]}], "serviceAccount") with input as {"metadata": {"features": ["LegacyTokenSecretsReducted"]}, "secrets": [], "serviceAccounts": [{"name": "old-sa", "namespace": "kube-system", "legacyToken": {"secrets": ["old-sa-token-abcde"]}}]}
+}
+
+test_no_legacy_tokens_in_privileged_namespaces {
+  not evaluateRoles([{"name": "secrets", "rules": [{"apiGroups": [""], "resources": ["secrets"], "verbs": ["list"]}]}], "serviceAccount") with input as {"secrets": [], "serviceAccounts": [{"name": "old-sa", "namespace": "default", "legacyToken": {"secrets": ["old-sa-token-abcde"]}}]}
+}
+
+test_resource_names_of_legacy_token {
+  evaluateRoles([{"name": "secrets", "effectiveNamespace": "kube-system", "rules": [{"apiGroups": [""], "resources": ["secrets"], "verbs": ["get"], "resourceNames": ["old-sa-token-abcde"]}]}], "serviceAccount") with input as {"secrets": [], "serviceAccounts": [{"name": "old-sa", "namespace": "kube-system", "legacyToken": {"secrets": ["old-sa-token-abcde"]}}]}
+}
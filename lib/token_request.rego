@@ -2,9 +2,16 @@ package policy
 import data.police_builtins as pb
 import future.keywords.in
 
-describe[{"desc": desc, "severity": severity}] {
+describe[{"id": id, "title": title, "desc": desc, "severity": severity, "category": category, "mitre": mitre, "remediation": remediation, "references": references, "version": version}] {
+  id := "RP-023"
+  title := "Create TokenRequests in privileged namespaces"
   desc := sprintf("Identities that can create TokenRequests (serviceaccounts/token) in privileged namespaces (%v) can issue tokens for admin-equivalent SAs", [concat(", ", pb.privileged_namespaces)])
   severity := "Critical"
+  category := "Acquire Tokens"
+  mitre := ["T1528"]
+  remediation := "Remove the permission to create serviceaccounts/token in privileged namespaces, or restrict it via resourceNames."
+  references := ["https://kubernetes.io/docs/reference/kubernetes-api/authentication-resources/token-request-v1/", "https://www.paloaltonetworks.com/resources/whitepapers/kubernetes-privilege-escalation-excessive-permissions-in-popular-platforms"]
+  version := "1.0"
 }
 targets := {"serviceAccounts", "nodes", "users", "groups"}
 
@@ -1,7 +1,11 @@
 package police_builtins
 import future.keywords.in
 
-privileged_namespaces := {"kube-system"}
+# Namespaces considered privileged, configurable via data.config.privilegedNamespaces
+privileged_namespaces = configuredNamespaces {
+  configuredNamespaces := { ns | some ns in data.config.privilegedNamespaces }
+  count(configuredNamespaces) > 0
+} else = {"kube-system"}
 
 # True if @arr contains @value or a wildcard
 valueOrWildcard(arr, value) {
@@ -182,6 +186,30 @@ legacyTokenSecretsReducted := true {
   "LegacyTokenSecretsReducted" in features
 }
 
+# True if long-lived serviceAccount tokens are known per serviceAccount, as secrets were collected.
+# Secrets are null if they couldn't be listed, and empty if there are none
+legacyTokensKnown := true {
+  is_array(input.secrets)
+}
+
+# True if a serviceAccount in one of @namespaces, that @role is in effect in, has a long-lived token
+legacyTokenInScope(role, namespaces) {
+  some sa in input.serviceAccounts
+  hasKey(sa, "legacyToken")
+  sa.namespace in namespaces
+  notNamespacedOrNamespace(role, sa.namespace)
+}
+
+# True if long-lived tokens of serviceAccounts in @namespaces, that @role is in effect in, may exist.
+# Per serviceAccount if secrets were collected, otherwise unless LegacyTokenSecretsReducted
+legacyTokensMayExist(role, namespaces) {
+  legacyTokensKnown
+  legacyTokenInScope(role, namespaces)
+} {
+  not legacyTokensKnown
+  not legacyTokenSecretsReducted
+}
+
 # Checks for NodeRestriction
 NodeRestriction := true {
   metadata := object.get(input, "metadata", {})
@@ -207,3 +235,63 @@ nodeRestrictionV117EnabledAndIsNode(permissionOwner) {
   NodeRestrictionV117
   permissionOwner == "node"
 }
+
+# Returns the objects of @kind, 'secrets', 'configMaps', 'persistentVolumeClaims' or 'serviceAccountTokens',
+# that the NodeAuthorizer permits @node to access as pods bound to it reference them
+nodeAuthorizerObjects(node, kind) = objects {
+  access := object.get(node, "nodeAuthorizer", {})
+  objects := object.get(access, kind, [])
+}
+
+# True if the NodeAuthorizer permits @node to access the object of @kind named @name in @namespace
+nodeAuthorizerCanAccess(node, kind, namespace, name) {
+  some obj in nodeAuthorizerObjects(node, kind)
+  obj.namespace == namespace
+  obj.name == name
+}
+
+# Returns the pods on @node due to which the NodeAuthorizer permits it to access the object of @kind named @name in @namespace
+nodeAuthorizerPods(node, kind, namespace, name) = pods {
+  pods := { pod |
+    some obj in nodeAuthorizerObjects(node, kind)
+    obj.namespace == namespace
+    obj.name == name
+    some pod in obj.pods
+  }
+}
+
+# True if @node can read the secret named @name in @namespace via the NodeAuthorizer
+nodeCanReadSecret(node, namespace, name) {
+  nodeAuthorizerCanAccess(node, "secrets", namespace, name)
+}
+
+# Returns the full names of the secrets @node can read via the NodeAuthorizer in privileged namespaces
+nodeSecretsInPrivNS(node) = secrets {
+  secrets := { fullName |
+    some secret in nodeAuthorizerObjects(node, "secrets")
+    secret.namespace in privileged_namespaces
+    fullName := sprintf("%v:%v", [secret.namespace, secret.name])
+  }
+}
+
+# True if @rule isn't restricted by resourceNames, or permits @name
+resourceNameOrUnrestricted(rule, name) {
+  not hasKey(rule, "resourceNames")
+} {
+  name in rule.resourceNames
+}
+
+# Returns the secrets in input.secrets that @roles permit getting or listing, honoring namespaces and resourceNames.
+# Empty if secrets weren't collected
+readableSecrets(roles) = secrets {
+  secrets := { secret |
+    some secret in object.get(input, "secrets", [])
+    some role in roles
+    notNamespacedOrNamespace(role, secret.namespace)
+    some rule in role.rules
+    valueOrWildcard(rule.apiGroups, "")
+    valueOrWildcard(rule.resources, "secrets")
+    getOrListOrWildcard(rule.verbs)
+    resourceNameOrUnrestricted(rule, secret.name)
+  }
+}
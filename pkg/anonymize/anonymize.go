@@ -0,0 +1,234 @@
+package anonymize
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Number of hex characters in the hash part of pseudonyms
+const pseudonymHashLen = 12
+
+// NewAnonymizer returns an Anonymizer keyed by the mapping file at @mappingFile.
+// If the file doesn't exist, a new key is generated and the file is created on Save(),
+// so results anonymized with the same mapping file share pseudonyms.
+func NewAnonymizer(mappingFile string) (*Anonymizer, error) {
+	anonymizer := Anonymizer{
+		mappingFile: mappingFile,
+		mapping:     Mapping{Names: make(map[string]string)},
+	}
+
+	mappingBytes, err := os.ReadFile(mappingFile)
+	if errors.Is(err, os.ErrNotExist) {
+		key := make([]byte, sha256.Size)
+		if _, err = rand.Read(key); err != nil {
+			log.Errorf("NewAnonymizer: failed to generate a key with %v\n", err)
+			return nil, err
+		}
+		anonymizer.mapping.Key = hex.EncodeToString(key)
+		return &anonymizer, nil
+	}
+	if err != nil {
+		log.Errorf("NewAnonymizer: failed to read mapping file %v with %v\n", mappingFile, err)
+		return nil, err
+	}
+	if err = json.Unmarshal(mappingBytes, &anonymizer.mapping); err != nil {
+		log.Errorf("NewAnonymizer: failed to unmarshal mapping file %v with %v\n", mappingFile, err)
+		return nil, err
+	}
+	if _, err = hex.DecodeString(anonymizer.mapping.Key); err != nil || anonymizer.mapping.Key == "" {
+		err = errors.New("invalid key")
+		log.Errorf("NewAnonymizer: mapping file %v holds an %v\n", mappingFile, err)
+		return nil, err
+	}
+	if anonymizer.mapping.Names == nil {
+		anonymizer.mapping.Names = make(map[string]string)
+	}
+	return &anonymizer, nil
+}
+
+// Save writes the key and the names behind the pseudonyms to the mapping file, readable only by the current user
+func (a *Anonymizer) Save() error {
+	mappingBytes, err := json.MarshalIndent(a.mapping, "", "    ")
+	if err != nil {
+		log.Errorf("Save: failed to marshal mapping with %v\n", err)
+		return err
+	}
+	if err = os.WriteFile(a.mappingFile, mappingBytes, 0600); err != nil {
+		log.Errorf("Save: failed to write mapping file %v with %v\n", a.mappingFile, err)
+		return err
+	}
+	return nil
+}
+
+// Deanonymize replaces the pseudonyms in @data with the names they replace, per the mapping file at @mappingFile
+func Deanonymize(mappingFile string, data []byte) ([]byte, error) {
+	if _, err := os.Stat(mappingFile); err != nil {
+		log.Errorf("Deanonymize: failed to read mapping file %v with %v\n", mappingFile, err)
+		return nil, err
+	}
+	anonymizer, err := NewAnonymizer(mappingFile)
+	if err != nil {
+		return nil, err // error printed in NewAnonymizer
+	}
+	if len(anonymizer.mapping.Names) == 0 {
+		log.Warnf("Deanonymize: mapping file %v holds no pseudonyms\n", mappingFile)
+	}
+
+	// Replace longer pseudonyms first, in case one is a prefix of another
+	var pseudonyms []string
+	for pseudonym := range anonymizer.mapping.Names {
+		pseudonyms = append(pseudonyms, pseudonym)
+	}
+	sort.Slice(pseudonyms, func(i, j int) bool { return len(pseudonyms[i]) > len(pseudonyms[j]) })
+	var oldnew []string
+	for _, pseudonym := range pseudonyms {
+		oldnew = append(oldnew, pseudonym, anonymizer.mapping.Names[pseudonym])
+	}
+	return []byte(strings.NewReplacer(oldnew...).Replace(string(data))), nil
+}
+
+// Returns the pseudonym of @name of @kind, the hash of @kind and @hashed under the key.
+// Names that are already pseudonyms are returned as is, so anonymizing is idempotent.
+func (a *Anonymizer) pseudonym(kind string, name string, hashed string) string {
+	if name == "" {
+		return ""
+	}
+	if _, ok := a.mapping.Names[name]; ok {
+		return name
+	}
+	key, _ := hex.DecodeString(a.mapping.Key) // validated in NewAnonymizer
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(kind + "/" + hashed))
+	pseudonym := kind + "-" + hex.EncodeToString(mac.Sum(nil))[:pseudonymHashLen]
+	a.mapping.Names[pseudonym] = name
+	return pseudonym
+}
+
+// Returns the pseudonym of the namespace @namespace
+func (a *Anonymizer) namespace(namespace string) string {
+	if wellKnownNamespaces[namespace] {
+		return namespace
+	}
+	return a.pseudonym(kindNamespace, namespace, namespace)
+}
+
+// Returns the pseudonyms of the serviceAccount @name in @namespace and of @namespace
+func (a *Anonymizer) serviceAccount(namespace string, name string) (string, string) {
+	if systemNamespaces[namespace] || name == "default" {
+		return a.namespace(namespace), name
+	}
+	return a.namespace(namespace), a.pseudonym(kindServiceAccount, name, namespace+":"+name)
+}
+
+// Returns the pseudonym of a serviceAccount's @fullName, 'namespace:name'
+func (a *Anonymizer) serviceAccountFullName(fullName string) string {
+	parts := strings.SplitN(fullName, ":", 2)
+	if len(parts) != 2 {
+		return a.pseudonym(kindServiceAccount, fullName, fullName)
+	}
+	namespace, name := a.serviceAccount(parts[0], parts[1])
+	return namespace + ":" + name
+}
+
+// Returns the pseudonym of the pod @name in @namespace
+func (a *Anonymizer) pod(namespace string, name string) string {
+	if systemNamespaces[namespace] {
+		return name
+	}
+	return a.pseudonym(kindPod, name, namespace+":"+name)
+}
+
+// Returns the pseudonym of the node @name
+func (a *Anonymizer) node(name string) string {
+	return a.pseudonym(kindNode, name, name)
+}
+
+// Returns the pseudonym of the user @name.
+// The users of serviceAccounts and nodes are consistent with their pseudonyms, and other system users are preserved.
+func (a *Anonymizer) user(name string) string {
+	if strings.HasPrefix(name, "system:serviceaccount:") {
+		return "system:serviceaccount:" + a.serviceAccountFullName(strings.TrimPrefix(name, "system:serviceaccount:"))
+	}
+	if strings.HasPrefix(name, "system:node:") {
+		return "system:node:" + a.node(strings.TrimPrefix(name, "system:node:"))
+	}
+	if strings.HasPrefix(name, "system:") {
+		return name
+	}
+	return a.pseudonym(kindUser, name, name)
+}
+
+// Returns the pseudonym of the group @name.
+// The serviceAccount group of a namespace is consistent with the namespace's pseudonym, and other system groups are preserved.
+func (a *Anonymizer) group(name string) string {
+	if strings.HasPrefix(name, "system:serviceaccounts:") {
+		return "system:serviceaccounts:" + a.namespace(strings.TrimPrefix(name, "system:serviceaccounts:"))
+	}
+	if strings.HasPrefix(name, "system:") {
+		return name
+	}
+	return a.pseudonym(kindGroup, name, name)
+}
+
+// Returns the pseudonym of the role @name in @namespace, or of the clusterRole @name if @namespace is empty
+func (a *Anonymizer) role(name string, namespace string) string {
+	if namespace == "" {
+		if strings.HasPrefix(name, "system:") || builtInClusterRoles[name] {
+			return name
+		}
+		return a.pseudonym(kindRole, name, name)
+	}
+	if systemNamespaces[namespace] {
+		return name
+	}
+	return a.pseudonym(kindRole, name, namespace+":"+name)
+}
+
+// Returns the pseudonym of the object @name referred to by a rule's resourceNames
+func (a *Anonymizer) resourceName(name string) string {
+	if strings.HasPrefix(name, "system:") || wellKnownResourceNames[name] {
+		return name
+	}
+	return a.pseudonym(kindResource, name, name)
+}
+
+// Returns the pseudonym of the value of the label @key of the namespace @namespace
+func (a *Anonymizer) labelValue(namespace string, key string, value string) string {
+	if key == namespaceNameLabel {
+		return a.namespace(value)
+	}
+	if systemNamespaces[namespace] {
+		return value
+	}
+	for _, prefix := range preservedLabelPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return value
+		}
+	}
+	return a.pseudonym(kindLabel, value, key+"="+value)
+}
+
+// Returns the pseudonym of the source IP @ip
+func (a *Anonymizer) sourceIP(ip string) string {
+	return a.pseudonym(kindIP, ip, ip)
+}
+
+// Returns the product of the user agent @userAgent if it's a well-known client, otherwise its pseudonym
+func (a *Anonymizer) userAgent(userAgent string) string {
+	product := strings.SplitN(userAgent, " ", 2)[0]
+	for _, wellKnownProduct := range wellKnownUserAgentProducts {
+		if strings.HasPrefix(product, wellKnownProduct) {
+			return product
+		}
+	}
+	return a.pseudonym(kindUserAgent, userAgent, userAgent)
+}
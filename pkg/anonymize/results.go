@@ -0,0 +1,278 @@
+package anonymize
+
+import (
+	"strings"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+	rbac "k8s.io/api/rbac/v1"
+)
+
+// CollectResult replaces the names in @collectResult with pseudonyms.
+// Well-known names are preserved, so policies evaluate the anonymized result as they would the original one.
+// To anonymize an ExpandResult consistently, expand the anonymized CollectResult.
+func (a *Anonymizer) CollectResult(collectResult *collect.CollectResult) {
+	collectResult.Metadata.ClusterName = a.pseudonym(kindCluster, collectResult.Metadata.ClusterName, collectResult.Metadata.ClusterName)
+
+	for i, sa := range collectResult.ServiceAccounts {
+		anonymized := &collectResult.ServiceAccounts[i]
+		anonymized.Namespace, anonymized.Name = a.serviceAccount(sa.Namespace, sa.Name)
+		anonymized.Nodes = a.nodesToPods(sa.Namespace, sa.Nodes)
+		anonymized.ProviderIAM = a.providerIAM(sa.ProviderIAM)
+		anonymized.Roles = a.roleRefs(sa.Roles)
+		if sa.LegacyToken != nil {
+			legacyToken := *sa.LegacyToken
+			legacyToken.Secrets = nil
+			for _, secret := range sa.LegacyToken.Secrets {
+				legacyToken.Secrets = append(legacyToken.Secrets, a.resourceName(secret))
+			}
+			anonymized.LegacyToken = &legacyToken
+		}
+	}
+	for i, node := range collectResult.Nodes {
+		anonymized := &collectResult.Nodes[i]
+		anonymized.Name = a.node(node.Name)
+		anonymized.Roles = a.roleRefs(node.Roles)
+		anonymized.ServiceAccounts = a.serviceAccountFullNames(node.ServiceAccounts)
+		anonymized.NodeAuthorizer = a.nodeAuthorizerAccess(node.NodeAuthorizer)
+	}
+	for i, user := range collectResult.Users {
+		collectResult.Users[i].Name = a.user(user.Name)
+		collectResult.Users[i].Roles = a.roleRefs(user.Roles)
+	}
+	for i, group := range collectResult.Groups {
+		collectResult.Groups[i].Name = a.group(group.Name)
+		collectResult.Groups[i].Roles = a.roleRefs(group.Roles)
+	}
+	for i, role := range collectResult.Roles {
+		anonymized := &collectResult.Roles[i]
+		anonymized.Name = a.role(role.Name, role.Namespace)
+		anonymized.Namespace = a.namespace(role.Namespace)
+		if anonymized.Name != role.Name {
+			anonymized.Rules = a.rules(role.Rules) // rules of preserved roles are preserved as well
+		}
+	}
+	for i, namespace := range collectResult.Namespaces {
+		anonymized := &collectResult.Namespaces[i]
+		anonymized.Name = a.namespace(namespace.Name)
+		if namespace.Labels != nil {
+			anonymized.Labels = make(map[string]string)
+			for key, value := range namespace.Labels {
+				anonymized.Labels[key] = a.labelValue(namespace.Name, key, value)
+			}
+		}
+	}
+	for i, secret := range collectResult.Secrets {
+		anonymized := &collectResult.Secrets[i]
+		anonymized.Name = a.resourceName(secret.Name)
+		anonymized.Namespace = a.namespace(secret.Namespace)
+		if secret.ServiceAccount != "" {
+			_, anonymized.ServiceAccount = a.serviceAccount(secret.Namespace, secret.ServiceAccount)
+		}
+		if kindAndName := strings.SplitN(secret.Owner, "/", 2); len(kindAndName) == 2 {
+			anonymized.Owner = kindAndName[0] + "/" + a.resourceName(kindAndName[1])
+		}
+	}
+}
+
+// PolicyResults replaces the names of the violating identities in @policyResults with pseudonyms,
+// consistent with those of CollectResult()
+func (a *Anonymizer) PolicyResults(policyResults *eval.PolicyResults) {
+	for i := range policyResults.PolicyResults {
+		violations := &policyResults.PolicyResults[i].Violations
+		for j, sa := range violations.ServiceAccounts {
+			anonymized := &violations.ServiceAccounts[j]
+			anonymized.Namespace, anonymized.Name = a.serviceAccount(sa.Namespace, sa.Name)
+			anonymized.ProviderIAM = a.providerIAM(sa.ProviderIAM)
+			anonymized.Nodes = nil
+			for _, nodeToPods := range sa.Nodes {
+				anonymizedNodeToPods := make(map[string][]string)
+				for node, pods := range nodeToPods {
+					anonymizedNodeToPods[a.node(node)] = a.pods(sa.Namespace, pods)
+				}
+				anonymized.Nodes = append(anonymized.Nodes, anonymizedNodeToPods)
+			}
+		}
+		for j, node := range violations.Nodes {
+			violations.Nodes[j] = a.node(node)
+		}
+		for j, combined := range violations.Combined {
+			violations.Combined[j].Node = a.node(combined.Node)
+			violations.Combined[j].ServiceAccounts = a.serviceAccountFullNames(combined.ServiceAccounts)
+		}
+		for j, user := range violations.Users {
+			violations.Users[j] = a.user(user)
+		}
+		for j, group := range violations.Groups {
+			violations.Groups[j] = a.group(group)
+		}
+		for j, grant := range policyResults.PolicyResults[i].UserGrants {
+			anonymized := &policyResults.PolicyResults[i].UserGrants[j]
+			anonymized.User = a.user(grant.User)
+			anonymized.Groups = nil
+			for _, group := range grant.Groups {
+				anonymized.Groups = append(anonymized.Groups, a.group(group))
+			}
+		}
+		for j := range policyResults.PolicyResults[i].Usage {
+			a.violationUsage(&policyResults.PolicyResults[i].Usage[j])
+		}
+	}
+}
+
+// Replaces the names in @usage, and the source IPs and user agents of its requests, with pseudonyms
+func (a *Anonymizer) violationUsage(usage *eval.ViolationUsage) {
+	switch usage.Type {
+	case "serviceAccount":
+		usage.Namespace, usage.Name = a.serviceAccount(usage.Namespace, usage.Name)
+	case "node", "combined":
+		usage.Name = a.node(usage.Name)
+	case "user":
+		usage.Name = a.user(usage.Name)
+	case "group":
+		usage.Name = a.group(usage.Name)
+	}
+	for i, permission := range usage.Permissions {
+		usage.Permissions[i].Namespace = a.namespace(permission.Namespace)
+	}
+	for i, sourceIP := range usage.SourceIPs {
+		usage.SourceIPs[i] = a.sourceIP(sourceIP)
+	}
+	var userAgents []string
+	for _, userAgent := range usage.UserAgents {
+		if anonymized := a.userAgent(userAgent); !utils.Contains(userAgents, anonymized) {
+			userAgents = append(userAgents, anonymized)
+		}
+	}
+	usage.UserAgents = userAgents
+}
+
+// Identity returns the pseudonyms of the name and namespace of an identity of @identityType, 'sa', 'node', 'user' or 'group'
+func (a *Anonymizer) Identity(identityType string, name string, namespace string) (string, string) {
+	switch identityType {
+	case "sa":
+		namespace, name = a.serviceAccount(namespace, name)
+		return name, namespace
+	case "node":
+		return a.node(name), namespace
+	case "user":
+		return a.user(name), namespace
+	case "group":
+		return a.group(name), namespace
+	}
+	return name, namespace
+}
+
+// Returns the pseudonyms of @nodesToPods, the nodes hosting the pods of a serviceAccount in @namespace
+func (a *Anonymizer) nodesToPods(namespace string, nodesToPods []collect.NodeToPods) []collect.NodeToPods {
+	var anonymized []collect.NodeToPods
+	for _, nodeToPods := range nodesToPods {
+		anonymized = append(anonymized, collect.NodeToPods{
+			Name: a.node(nodeToPods.Name),
+			Pods: a.pods(namespace, nodeToPods.Pods),
+		})
+	}
+	return anonymized
+}
+
+// Returns the pseudonyms of @pods in @namespace
+func (a *Anonymizer) pods(namespace string, pods []string) []string {
+	anonymized := []string{}
+	for _, pod := range pods {
+		anonymized = append(anonymized, a.pod(namespace, pod))
+	}
+	return anonymized
+}
+
+// Returns the pseudonyms of the serviceAccounts @fullNames
+func (a *Anonymizer) serviceAccountFullNames(fullNames []string) []string {
+	if fullNames == nil {
+		return nil
+	}
+	anonymized := []string{}
+	for _, fullName := range fullNames {
+		anonymized = append(anonymized, a.serviceAccountFullName(fullName))
+	}
+	return anonymized
+}
+
+// Returns @providerIAM with pseudonyms of the cloud provider IAM entities, keyed by provider
+func (a *Anonymizer) providerIAM(providerIAM map[string]string) map[string]string {
+	if providerIAM == nil {
+		return nil
+	}
+	anonymized := make(map[string]string)
+	for provider, entity := range providerIAM {
+		anonymized[provider] = a.pseudonym(kindIAM, entity, entity)
+	}
+	return anonymized
+}
+
+// Returns @access with pseudonyms of the objects and pods it refers to
+func (a *Anonymizer) nodeAuthorizerAccess(access *collect.NodeAuthorizerAccess) *collect.NodeAuthorizerAccess {
+	if access == nil {
+		return nil
+	}
+	objectRefs := func(refs []collect.NodeObjectRef, name func(namespace string, name string) string) []collect.NodeObjectRef {
+		anonymized := []collect.NodeObjectRef{}
+		for _, ref := range refs {
+			anonymized = append(anonymized, collect.NodeObjectRef{
+				Name:      name(ref.Namespace, ref.Name),
+				Namespace: a.namespace(ref.Namespace),
+				Pods:      a.pods(ref.Namespace, ref.Pods),
+				Audiences: ref.Audiences,
+			})
+		}
+		return anonymized
+	}
+	objectName := func(namespace string, name string) string {
+		return a.resourceName(name)
+	}
+	serviceAccountName := func(namespace string, name string) string {
+		_, anonymizedName := a.serviceAccount(namespace, name)
+		return anonymizedName
+	}
+	return &collect.NodeAuthorizerAccess{
+		Secrets:                objectRefs(access.Secrets, objectName),
+		ConfigMaps:             objectRefs(access.ConfigMaps, objectName),
+		PersistentVolumeClaims: objectRefs(access.PersistentVolumeClaims, objectName),
+		ServiceAccountTokens:   objectRefs(access.ServiceAccountTokens, serviceAccountName),
+	}
+}
+
+// Returns @roleRefs with pseudonyms of the roles and namespaces they refer to
+func (a *Anonymizer) roleRefs(roleRefs []collect.RoleRef) []collect.RoleRef {
+	if roleRefs == nil {
+		return nil
+	}
+	anonymized := []collect.RoleRef{}
+	for _, roleRef := range roleRefs {
+		anonymized = append(anonymized, collect.RoleRef{
+			Name:               a.role(roleRef.Name, roleRef.Namespace),
+			Namespace:          a.namespace(roleRef.Namespace),
+			EffectiveNamespace: a.namespace(roleRef.EffectiveNamespace),
+			Group:              a.group(roleRef.Group),
+		})
+	}
+	return anonymized
+}
+
+// Returns @rules with pseudonyms of the objects they refer to via resourceNames
+func (a *Anonymizer) rules(rules []rbac.PolicyRule) []rbac.PolicyRule {
+	if rules == nil {
+		return nil
+	}
+	anonymized := []rbac.PolicyRule{}
+	for _, rule := range rules {
+		if rule.ResourceNames != nil {
+			resourceNames := []string{}
+			for _, resourceName := range rule.ResourceNames {
+				resourceNames = append(resourceNames, a.resourceName(resourceName))
+			}
+			rule.ResourceNames = resourceNames
+		}
+		anonymized = append(anonymized, rule)
+	}
+	return anonymized
+}
@@ -0,0 +1,74 @@
+package anonymize
+
+// Anonymizer replaces the names in rbac-police's results with consistent pseudonyms.
+// Pseudonyms are keyed hashes of the names, so the same name is always replaced by the same pseudonym under the same key.
+type Anonymizer struct {
+	mappingFile string
+	mapping     Mapping
+}
+
+// Mapping is the locally kept mapping file, holding the key and the names behind each pseudonym
+type Mapping struct {
+	Key   string            `json:"key"`   // hex encoded HMAC key
+	Names map[string]string `json:"names"` // pseudonyms mapped to the names they replace
+}
+
+// Kinds of names, used as the prefix of their pseudonyms
+const (
+	kindCluster        = "cluster"
+	kindNamespace      = "ns"
+	kindServiceAccount = "sa"
+	kindPod            = "pod"
+	kindNode           = "node"
+	kindUser           = "user"
+	kindGroup          = "group"
+	kindRole           = "role"
+	kindResource       = "resource"
+	kindIAM            = "iam"
+	kindLabel          = "label"
+	kindIP             = "ip"
+	kindUserAgent      = "agent"
+)
+
+// Namespaces whose names are preserved
+var wellKnownNamespaces = map[string]bool{
+	"default":         true,
+	"kube-system":     true,
+	"kube-public":     true,
+	"kube-node-lease": true,
+}
+
+// Namespaces whose objects are preserved as well
+var systemNamespaces = map[string]bool{
+	"kube-system":     true,
+	"kube-public":     true,
+	"kube-node-lease": true,
+}
+
+// ClusterRoles created by Kubernetes that don't start with 'system:'
+var builtInClusterRoles = map[string]bool{
+	"cluster-admin": true,
+	"admin":         true,
+	"edit":          true,
+	"view":          true,
+}
+
+// Names of well-known objects that rules may refer to via resourceNames, and that policies check for
+var wellKnownResourceNames = map[string]bool{
+	"aws-auth":                           true,
+	"kube-root-ca.crt":                   true,
+	"cluster-info":                       true,
+	"kubeadm-config":                     true,
+	"extension-apiserver-authentication": true,
+}
+
+// Namespace labels whose values are preserved, as policies or selectors consume them
+var preservedLabelPrefixes = []string{
+	"pod-security.kubernetes.io/",
+}
+
+// Label holding the name of its namespace
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+// Products of well-known clients, user agents of which are reduced to their product rather than replaced
+var wellKnownUserAgentProducts = []string{"kubectl/", "kubelet/", "kube-", "helm/", "Go-http-client/"}
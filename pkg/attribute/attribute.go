@@ -0,0 +1,170 @@
+package attribute
+
+import (
+	"os"
+	"sort"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// AttributeViolations attributes each violation in @policyResults to the rules that cause it, by re-evaluating
+// the violated policy against @collectResult with subsets of the violating identity's rules, like remediate does.
+// Violations that can't be attributed to specific rules are attributed to all of the identity's rules.
+func AttributeViolations(policyResults eval.PolicyResults, collectResult collect.CollectResult, evalConfig eval.EvalConfig) ([]Attribution, error) {
+	var attributions []Attribution
+
+	a := NewAttributor(collectResult, evalConfig)
+	for _, policyResult := range policyResults.PolicyResults {
+		if _, err := os.Stat(policyResult.PolicyFile); err != nil {
+			log.Errorf("AttributeViolations: cannot re-evaluate policy %v with %v\n", policyResult.PolicyFile, err)
+			return nil, err
+		}
+		for _, identity := range ViolatingIdentities(policyResult.Violations) {
+			attributions = append(attributions, a.Attribute(policyResult, identity))
+		}
+	}
+	return attributions, nil
+}
+
+// AttributeUserGrants annotates the violations of users in @policyResults with whether the permissions that cause them
+// are granted to the users directly, or inherited from which of their groups. Users inherit roles from their groups
+// when collecting with a group membership source, otherwise all permissions are granted directly and violations
+// aren't annotated.
+func AttributeUserGrants(policyResults *eval.PolicyResults, collectResult collect.CollectResult, evalConfig eval.EvalConfig) error {
+	inheritingUsers := make(map[string]struct{})
+	for _, user := range collectResult.Users {
+		for _, roleRef := range user.Roles {
+			if roleRef.Group != "" {
+				inheritingUsers[user.Name] = struct{}{}
+			}
+		}
+	}
+	if len(inheritingUsers) == 0 {
+		return nil
+	}
+
+	a := NewAttributor(collectResult, evalConfig)
+	for i := range policyResults.PolicyResults {
+		policyResult := &policyResults.PolicyResults[i]
+		policyResult.UserGrants = nil
+		for _, user := range policyResult.Violations.Users {
+			grant := eval.UserGrant{User: user, Direct: true}
+			if _, ok := inheritingUsers[user]; ok {
+				if _, err := os.Stat(policyResult.PolicyFile); err != nil {
+					log.Errorf("AttributeUserGrants: cannot re-evaluate policy %v with %v\n", policyResult.PolicyFile, err)
+					return err
+				}
+				grant.Direct, grant.Groups = a.grantSources(*policyResult, Identity{Type: "user", Name: user})
+			}
+			policyResult.UserGrants = append(policyResult.UserGrants, grant)
+		}
+	}
+	return nil
+}
+
+// AttributeGrantGroups returns which of @groups grant the permissions that cause the violations of each serviceAccount,
+// node, combined node and user in @policyResults, keyed by eval.IdentityKey(). Only identities that are granted roles
+// via @groups are re-evaluated, and identities whose violations aren't caused by @groups are omitted.
+func AttributeGrantGroups(policyResults eval.PolicyResults, collectResult collect.CollectResult, evalConfig eval.EvalConfig, groups []string) (map[string][]string, error) {
+	grantGroups := make(map[string][]string)
+	a := NewAttributor(collectResult, evalConfig)
+	for _, policyResult := range policyResults.PolicyResults {
+		for _, identity := range ViolatingIdentities(policyResult.Violations) {
+			if identity.Type == "group" || !a.grantedViaGroups(identity, groups) {
+				continue
+			}
+			if _, err := os.Stat(policyResult.PolicyFile); err != nil {
+				log.Errorf("AttributeGrantGroups: cannot re-evaluate policy %v with %v\n", policyResult.PolicyFile, err)
+				return nil, err
+			}
+			key := eval.IdentityKey(identity.Type, identity.Name, identity.Namespace)
+			_, sources := a.grantSources(policyResult, identity)
+			for _, group := range sources {
+				if utils.Contains(groups, group) && !utils.Contains(grantGroups[key], group) {
+					grantGroups[key] = append(grantGroups[key], group)
+				}
+			}
+		}
+	}
+	return grantGroups, nil
+}
+
+// Returns whether @identity is granted any roles via @groups
+func (a *Attributor) grantedViaGroups(identity Identity, groups []string) bool {
+	for _, owner := range GrantOwners(identity) {
+		roleRefs := OwnerRoleRefs(&a.CollectResult, owner)
+		if roleRefs == nil {
+			continue
+		}
+		for _, roleRef := range *roleRefs {
+			if utils.Contains(groups, roleRef.Group) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns whether the roles bound to @identity directly cause its violation of @policyResult, and which of the groups
+// it's granted or inherits roles via cause it by themselves. If no source causes the violation by itself, the sources
+// in its attribution are returned, as they cause it together.
+func (a *Attributor) grantSources(policyResult eval.PolicyResult, identity Identity) (bool, []string) {
+	var groups []string
+	slotsBySource := make(map[string][]RuleSlot) // direct grants are keyed by an empty group
+	for _, slot := range a.RuleSlots(identity) {
+		slotsBySource[slot.Ref.Group] = append(slotsBySource[slot.Ref.Group], slot)
+	}
+	direct := len(slotsBySource[""]) > 0 && a.ViolatesWithSlots(policyResult.PolicyFile, identity, slotsBySource[""])
+	for group, slots := range slotsBySource {
+		if group != "" && a.ViolatesWithSlots(policyResult.PolicyFile, identity, slots) {
+			groups = append(groups, group)
+		}
+	}
+	if !direct && len(groups) == 0 {
+		for _, rule := range a.Attribute(policyResult, identity).Rules {
+			if rule.Group == "" {
+				direct = true
+			} else if !utils.Contains(groups, rule.Group) {
+				groups = append(groups, rule.Group)
+			}
+		}
+	}
+	sort.Strings(groups)
+	return direct, groups
+}
+
+// NewAttributor returns an Attributor that evaluates all violation types of all policies against @collectResult
+func NewAttributor(collectResult collect.CollectResult, evalConfig eval.EvalConfig) Attributor {
+	evalConfig.SaViolations, evalConfig.NodeViolations, evalConfig.CombinedViolations = true, true, true
+	evalConfig.UserViolations, evalConfig.GroupViolations = true, true
+	evalConfig.SeverityThreshold = "Low"
+	return Attributor{CollectResult: collectResult, EvalConfig: evalConfig}
+}
+
+// Attribute attributes the violation of @policyResult by @identity to the rules that cause it
+func (a *Attributor) Attribute(policyResult eval.PolicyResult, identity Identity) Attribution {
+	attribution := Attribution{PolicyFile: policyResult.PolicyFile, PolicyID: policyResult.ID, Identity: identity, Attributed: true}
+	slots := a.RuleSlots(identity)
+	culprits := a.MinimalViolatingSlots(policyResult.PolicyFile, identity, slots)
+	if len(culprits) == 0 {
+		culprits = slots
+		attribution.Attributed = false
+	}
+	for _, slot := range culprits {
+		namespace := slot.Ref.EffectiveNamespace
+		if namespace == "" {
+			namespace = slot.Ref.Namespace
+		}
+		attribution.Rules = append(attribution.Rules, AttributedRule{
+			OwnerType: slot.Grant.OwnerType,
+			OwnerName: slot.Grant.OwnerName,
+			Group:     slot.Ref.Group,
+			Namespace: namespace,
+			Rule:      slot.Rule,
+		})
+	}
+	return attribution
+}
@@ -0,0 +1,254 @@
+package attribute
+
+import (
+	"fmt"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+)
+
+// Narrowed returns the subset of a.CollectResult evaluated when looking for the rules that cause @identity's violation.
+// All serviceAccounts and secrets are kept as context, as policies may depend on serviceAccounts other than @identity,
+// e.g. on their long-lived tokens, while only the roles of @identity are isolated by ViolatesWithSlots
+func (a *Attributor) Narrowed(identity Identity) collect.CollectResult {
+	narrowed := a.CollectResult
+	narrowed.ServiceAccounts = append([]collect.ServiceAccountEntry{}, a.CollectResult.ServiceAccounts...)
+	narrowed.Nodes = []collect.NodeEntry{}
+	narrowed.Users = []collect.NamedEntry{}
+	narrowed.Groups = []collect.NamedEntry{}
+
+	switch identity.Type {
+	case "node", "combined":
+		for _, node := range a.CollectResult.Nodes {
+			if node.Name == identity.Name {
+				narrowed.Nodes = append(narrowed.Nodes, node)
+			}
+		}
+	case "user":
+		for _, user := range a.CollectResult.Users {
+			if user.Name == identity.Name {
+				narrowed.Users = append(narrowed.Users, user)
+			}
+		}
+	case "group":
+		for _, group := range a.CollectResult.Groups {
+			if group.Name == identity.Name {
+				narrowed.Groups = append(narrowed.Groups, group)
+			}
+		}
+	}
+	return narrowed
+}
+
+// GrantOwners returns the identities whose roles may cause @identity's violation
+func GrantOwners(identity Identity) []GrantOwner {
+	switch identity.Type {
+	case "serviceAccount":
+		return []GrantOwner{{Type: "serviceAccount", Name: utils.FullName(identity.Namespace, identity.Name)}}
+	case "combined":
+		owners := []GrantOwner{{Type: "node", Name: identity.Name}}
+		for _, sa := range identity.ServiceAccounts {
+			owners = append(owners, GrantOwner{Type: "serviceAccount", Name: sa})
+		}
+		return owners
+	default:
+		return []GrantOwner{{Type: identity.Type, Name: identity.Name}}
+	}
+}
+
+// OwnerRoleRefs returns a pointer to the roles of @owner in @collectResult, or nil if it doesn't exist
+func OwnerRoleRefs(collectResult *collect.CollectResult, owner GrantOwner) *[]collect.RoleRef {
+	switch owner.Type {
+	case "serviceAccount":
+		for i, sa := range collectResult.ServiceAccounts {
+			if utils.FullName(sa.Namespace, sa.Name) == owner.Name {
+				return &collectResult.ServiceAccounts[i].Roles
+			}
+		}
+	case "node":
+		for i, node := range collectResult.Nodes {
+			if node.Name == owner.Name {
+				return &collectResult.Nodes[i].Roles
+			}
+		}
+	case "user":
+		for i, user := range collectResult.Users {
+			if user.Name == owner.Name {
+				return &collectResult.Users[i].Roles
+			}
+		}
+	case "group":
+		for i, group := range collectResult.Groups {
+			if group.Name == owner.Name {
+				return &collectResult.Groups[i].Roles
+			}
+		}
+	}
+	return nil
+}
+
+// FindRoleIndex returns the index of the role @roleRef points to in @roles, or -1 if it doesn't exist
+func FindRoleIndex(roles []collect.RoleEntry, roleRef collect.RoleRef) int {
+	for i, role := range roles {
+		if role.Name == roleRef.Name && role.Namespace == roleRef.Namespace {
+			return i
+		}
+	}
+	return -1
+}
+
+// RuleSlots returns the rules granted to the identities that may cause @identity's violation
+func (a *Attributor) RuleSlots(identity Identity) []RuleSlot {
+	var slots []RuleSlot
+	for _, owner := range GrantOwners(identity) {
+		roleRefs := OwnerRoleRefs(&a.CollectResult, owner)
+		if roleRefs == nil {
+			continue
+		}
+		for refIndex, roleRef := range *roleRefs {
+			roleIndex := FindRoleIndex(a.CollectResult.Roles, roleRef)
+			if roleIndex < 0 {
+				continue
+			}
+			for ruleIndex, rule := range a.CollectResult.Roles[roleIndex].Rules {
+				slots = append(slots, RuleSlot{
+					Grant:     GrantKey{OwnerType: owner.Type, OwnerName: owner.Name, RefIndex: refIndex},
+					Ref:       roleRef,
+					RuleIndex: ruleIndex,
+					Rule:      rule,
+				})
+			}
+		}
+	}
+	return slots
+}
+
+// ViolatesWithSlots returns whether @identity violates the policy at @policyFile when it's only granted the rules in @slots
+func (a *Attributor) ViolatesWithSlots(policyFile string, identity Identity, slots []RuleSlot) bool {
+	narrowed := a.Narrowed(identity)
+	narrowed.Roles = append([]collect.RoleEntry{}, a.CollectResult.Roles...)
+
+	// Replace the roles of owners with synthetic roles holding only the rules in @slots
+	for _, owner := range GrantOwners(identity) {
+		roleRefs := OwnerRoleRefs(&narrowed, owner)
+		if roleRefs == nil {
+			continue
+		}
+		var isolatedRefs []collect.RoleRef
+		for refIndex := range *roleRefs {
+			var isolatedRole *collect.RoleEntry
+			for _, slot := range slots {
+				if slot.Grant != (GrantKey{OwnerType: owner.Type, OwnerName: owner.Name, RefIndex: refIndex}) {
+					continue
+				}
+				if isolatedRole == nil {
+					isolatedRole = &collect.RoleEntry{
+						Name:      fmt.Sprintf("rbac-police-remediate-%d", len(narrowed.Roles)),
+						Namespace: slot.Ref.Namespace,
+					}
+					isolatedRefs = append(isolatedRefs, collect.RoleRef{
+						Name:               isolatedRole.Name,
+						Namespace:          slot.Ref.Namespace,
+						EffectiveNamespace: slot.Ref.EffectiveNamespace,
+					})
+				}
+				isolatedRole.Rules = append(isolatedRole.Rules, slot.Rule)
+			}
+			if isolatedRole != nil {
+				narrowed.Roles = append(narrowed.Roles, *isolatedRole)
+			}
+		}
+		*roleRefs = isolatedRefs
+	}
+	return a.Violates(policyFile, identity, narrowed)
+}
+
+// MinimalViolatingSlots returns a minimal subset of @slots that still causes @identity to violate the policy at @policyFile,
+// meaning removing any single rule from it resolves the violation. Returns nil if @slots don't cause the violation.
+func (a *Attributor) MinimalViolatingSlots(policyFile string, identity Identity, slots []RuleSlot) []RuleSlot {
+	if len(slots) == 0 || !a.ViolatesWithSlots(policyFile, identity, slots) {
+		return nil
+	}
+
+	// Drop entire roles first, as identities usually have many unrelated rules
+	var grants []GrantKey
+	for _, slot := range slots {
+		if len(grants) == 0 || grants[len(grants)-1] != slot.Grant {
+			grants = append(grants, slot.Grant)
+		}
+	}
+	kept := slots
+	for _, grant := range grants {
+		var candidate []RuleSlot
+		for _, slot := range kept {
+			if slot.Grant != grant {
+				candidate = append(candidate, slot)
+			}
+		}
+		if len(candidate) > 0 && a.ViolatesWithSlots(policyFile, identity, candidate) {
+			kept = candidate
+		}
+	}
+
+	// Then drop single rules
+	for i := 0; i < len(kept) && len(kept) > 1; {
+		candidate := append(append([]RuleSlot{}, kept[:i]...), kept[i+1:]...)
+		if a.ViolatesWithSlots(policyFile, identity, candidate) {
+			kept = candidate
+		} else {
+			i++
+		}
+	}
+	return kept
+}
+
+// ViolatingIdentities returns the identities that violated a policy
+func ViolatingIdentities(violations eval.Violations) []Identity {
+	var identities []Identity
+	for _, sa := range violations.ServiceAccounts {
+		identities = append(identities, Identity{Type: "serviceAccount", Name: sa.Name, Namespace: sa.Namespace})
+	}
+	for _, node := range violations.Nodes {
+		identities = append(identities, Identity{Type: "node", Name: node})
+	}
+	for _, combined := range violations.Combined {
+		identities = append(identities, Identity{Type: "combined", Name: combined.Node, ServiceAccounts: combined.ServiceAccounts})
+	}
+	for _, user := range violations.Users {
+		identities = append(identities, Identity{Type: "user", Name: user})
+	}
+	for _, group := range violations.Groups {
+		identities = append(identities, Identity{Type: "group", Name: group})
+	}
+	return identities
+}
+
+// Violates returns whether @identity violates the policy at @policyFile when evaluated against @collectResult
+func (a *Attributor) Violates(policyFile string, identity Identity, collectResult collect.CollectResult) bool {
+	policyResults := eval.Eval(policyFile, collectResult, a.EvalConfig)
+	if policyResults == nil {
+		return false
+	}
+	for _, policyResult := range policyResults.PolicyResults {
+		for _, violatingIdentity := range ViolatingIdentities(policyResult.Violations) {
+			if SameIdentity(identity, violatingIdentity) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SameIdentity returns whether @a and @b denote the same identity
+func SameIdentity(a Identity, b Identity) bool {
+	return a.Type == b.Type && a.Name == b.Name && a.Namespace == b.Namespace
+}
+
+// IdentityName returns the full name of @identity
+func IdentityName(identity Identity) string {
+	if identity.Type == "serviceAccount" {
+		return utils.FullName(identity.Namespace, identity.Name)
+	}
+	return identity.Name
+}
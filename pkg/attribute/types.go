@@ -0,0 +1,61 @@
+package attribute
+
+import (
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+	rbac "k8s.io/api/rbac/v1"
+)
+
+// Re-evaluates policies against the cluster's RBAC data in CollectResult to find the rules that cause violations.
+// Remediate patches CollectResult as it proposes patches
+type Attributor struct {
+	CollectResult collect.CollectResult
+	EvalConfig    eval.EvalConfig
+}
+
+// An identity that violated a policy
+type Identity struct {
+	Type            string   `json:"type"` // serviceAccount, node, combined, user or group
+	Name            string   `json:"name"`
+	Namespace       string   `json:"namespace,omitempty"`
+	ServiceAccounts []string `json:"serviceAccounts,omitempty"` // for combined violations
+}
+
+// An identity whose granted roles may cause a violation
+type GrantOwner struct {
+	Type string
+	Name string // full name for serviceAccounts
+}
+
+// Denotes a role granted to an owner, the @RefIndex role of the owner
+type GrantKey struct {
+	OwnerType string // serviceAccount, node, user or group
+	OwnerName string // full name for serviceAccounts
+	RefIndex  int
+}
+
+// A rule granted to an identity, evaluated in isolation while looking for the rules that cause a violation
+type RuleSlot struct {
+	Grant     GrantKey
+	Ref       collect.RoleRef
+	RuleIndex int
+	Rule      rbac.PolicyRule
+}
+
+// The rules that cause a violation of a policy by an identity
+type Attribution struct {
+	PolicyFile string           `json:"policy"`
+	PolicyID   string           `json:"id"`
+	Identity   Identity         `json:"identity"`
+	Rules      []AttributedRule `json:"rules"`
+	Attributed bool             `json:"attributed"` // false if the violation couldn't be attributed to specific rules, and Rules holds all of the identity's rules
+}
+
+// A rule that causes a violation, granted to an identity in a namespace
+type AttributedRule struct {
+	OwnerType string          `json:"ownerType"`           // serviceAccount, node, user or group, differs from the violating identity for combined violations
+	OwnerName string          `json:"ownerName"`           // full name for serviceAccounts
+	Group     string          `json:"group,omitempty"`     // the group the rule is granted or inherited via
+	Namespace string          `json:"namespace,omitempty"` // empty for rules granted cluster-wide
+	Rule      rbac.PolicyRule `json:"rule"`
+}
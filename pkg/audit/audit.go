@@ -0,0 +1,142 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	serviceAccountPrefix = "system:serviceaccount:"
+	nodePrefix           = "system:node:"
+	maxEventSize         = 4 * 1024 * 1024
+)
+
+// Reads the audit events in the JSON lines files at @paths, which may be gzipped, and calls @handle on each authorized request.
+// Only events at the ResponseComplete stage are handled, so a request isn't counted more than once.
+func ReadEvents(paths []string, handle func(Event)) error {
+	for _, path := range paths {
+		if err := readEventsFile(path, handle); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reads the audit events in the file at @path
+func readEventsFile(path string, handle func(Event)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Errorf("readEventsFile: failed to open %v with %v\n", path, err)
+		return err
+	}
+	defer file.Close()
+
+	// Support gzipped files
+	var reader io.Reader = bufio.NewReader(file)
+	magic, err := reader.(*bufio.Reader).Peek(2)
+	if err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			log.Errorf("readEventsFile: failed to decompress %v with %v\n", path, err)
+			return err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), maxEventSize)
+	lineNumber, skipped := 0, 0
+	for scanner.Scan() {
+		lineNumber += 1
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			log.Debugf("readEventsFile: skipping line %d of %v, failed to unmarshal with %v\n", lineNumber, path, err)
+			skipped += 1
+			continue
+		}
+		if !countedEvent(event) {
+			continue
+		}
+		handle(event)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Errorf("readEventsFile: failed to read %v with %v\n", path, err)
+		return err
+	}
+	if skipped > 0 {
+		log.Warnf("readEventsFile: skipped %d lines of %v that aren't audit events\n", skipped, path)
+	}
+	return nil
+}
+
+// Returns whether @event denotes a completed request that was authorized
+func countedEvent(event Event) bool {
+	if event.Stage != "" && event.Stage != "ResponseComplete" {
+		return false
+	}
+	if event.ResponseStatus != nil && (event.ResponseStatus.Code == 401 || event.ResponseStatus.Code == 403) {
+		return false
+	}
+	return event.Verb != "" && event.User.Username != ""
+}
+
+// Returns the identity whose permissions authorized @event, the impersonated user if there's one
+func EventIdentity(event Event) Identity {
+	username := event.User.Username
+	if event.ImpersonatedUser != nil && event.ImpersonatedUser.Username != "" {
+		username = event.ImpersonatedUser.Username
+	}
+	return ParseUsername(username)
+}
+
+// Returns the identity denoted by @username
+func ParseUsername(username string) Identity {
+	if strings.HasPrefix(username, serviceAccountPrefix) {
+		parts := strings.SplitN(strings.TrimPrefix(username, serviceAccountPrefix), ":", 2)
+		if len(parts) == 2 {
+			return Identity{Type: "serviceAccount", Namespace: parts[0], Name: parts[1]}
+		}
+	}
+	if strings.HasPrefix(username, nodePrefix) {
+		return Identity{Type: "node", Name: strings.TrimPrefix(username, nodePrefix)}
+	}
+	return Identity{Type: "user", Name: username}
+}
+
+// Returns the resource an event targeted, including its subresource, e.g. 'pods/exec'.
+// Returns an empty string for non-resource requests.
+func (event Event) Resource() string {
+	if event.ObjectRef == nil || event.ObjectRef.Resource == "" {
+		return ""
+	}
+	if event.ObjectRef.Subresource != "" {
+		return event.ObjectRef.Resource + "/" + event.ObjectRef.Subresource
+	}
+	return event.ObjectRef.Resource
+}
+
+// Returns the path of a non-resource request, without its query
+func (event Event) NonResourcePath() string {
+	return strings.SplitN(event.RequestURI, "?", 2)[0]
+}
+
+// Returns the time of @event
+func (event Event) Time() time.Time {
+	if !event.StageTimestamp.IsZero() {
+		return event.StageTimestamp
+	}
+	return event.RequestReceivedTimestamp
+}
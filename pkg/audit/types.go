@@ -0,0 +1,46 @@
+package audit
+
+import "time"
+
+// Kubernetes audit event (audit.k8s.io/v1), only the fields rbac-police uses
+type Event struct {
+	Stage                    string          `json:"stage"`
+	RequestURI               string          `json:"requestURI"`
+	Verb                     string          `json:"verb"`
+	User                     UserInfo        `json:"user"`
+	ImpersonatedUser         *UserInfo       `json:"impersonatedUser,omitempty"`
+	SourceIPs                []string        `json:"sourceIPs,omitempty"`
+	UserAgent                string          `json:"userAgent,omitempty"`
+	ObjectRef                *ObjectRef      `json:"objectRef,omitempty"`
+	ResponseStatus           *ResponseStatus `json:"responseStatus,omitempty"`
+	RequestReceivedTimestamp time.Time       `json:"requestReceivedTimestamp"`
+	StageTimestamp           time.Time       `json:"stageTimestamp"`
+}
+
+// The user that issued a request
+type UserInfo struct {
+	Username string   `json:"username"`
+	Groups   []string `json:"groups,omitempty"`
+}
+
+// The object a request targeted
+type ObjectRef struct {
+	Resource    string `json:"resource,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name,omitempty"`
+	APIGroup    string `json:"apiGroup,omitempty"`
+	APIVersion  string `json:"apiVersion,omitempty"`
+	Subresource string `json:"subresource,omitempty"`
+}
+
+// The status of a request's response
+type ResponseStatus struct {
+	Code int32 `json:"code,omitempty"`
+}
+
+// An identity as it's represented in rbac-police, derived from an event's user
+type Identity struct {
+	Type      string // serviceAccount, node or user
+	Name      string
+	Namespace string // for serviceAccounts
+}
@@ -0,0 +1,564 @@
+package blastradius
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/expand"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// Group that bypasses authorization, reachable by impersonating any group
+const mastersGroup = "system:masters"
+
+// BlastRadius computes what an attacker controlling @compromised gets in the cluster described by @collectResult.
+// Starting from @compromised, it walks the identities an attacker can transitively take over: tokens of serviceAccounts
+// whose pods run on a compromised node, impersonation targets, serviceAccounts that can be assigned to pods, and tokens
+// obtainable via secrets or TokenRequests. Returns the reachable identities, the secrets and cloud provider IAM entities
+// they expose, the union of their permissions, and the violations they produce per the policies at @config.PolicyPath.
+func BlastRadius(compromised Identity, collectResult collect.CollectResult, config BlastRadiusConfig) *BlastRadiusResult {
+	privilegedNamespaces, err := eval.ResolvePrivilegedNamespaces(collectResult.Namespaces, config.EvalConfig)
+	if err != nil {
+		return nil // error printed in ResolvePrivilegedNamespaces
+	}
+	collectResult.Secrets = collect.HighlightTokens(collectResult.Secrets, collectResult.ServiceAccounts, collectResult.Roles, privilegedNamespaces)
+
+	w := walker{
+		collectResult:   collectResult,
+		nodeRestriction: utils.Contains(collectResult.Metadata.Features, "NodeRestriction"),
+		legacyTokens:    !utils.Contains(collectResult.Metadata.Features, "LegacyTokenSecretsReducted"),
+		reached:         make(map[string]int),
+		result:          &BlastRadiusResult{Compromised: compromised, Identities: []ReachedIdentity{}},
+	}
+	if err := w.validate(compromised); err != nil {
+		log.Errorf("BlastRadius: %v\n", err)
+		return nil
+	}
+
+	// Breadth first, so each identity is reached via a shortest path
+	w.reach(ReachedIdentity{Identity: compromised})
+	for i := 0; i < len(w.result.Identities); i++ {
+		current := w.result.Identities[i]
+		for _, s := range w.steps(current.Identity) {
+			w.reach(ReachedIdentity{Identity: s.To, Depth: current.Depth + 1, From: FormatIdentity(current.Identity), Via: s.Via})
+		}
+	}
+
+	w.result.Secrets = w.readableSecrets()
+	w.result.ReadableSecrets = w.concreteReadableSecrets()
+	w.result.ProviderIAM = w.providerIAM()
+	w.result.Permissions = w.permissions()
+
+	evalConfig := config.EvalConfig
+	evalConfig.SaViolations, evalConfig.NodeViolations, evalConfig.CombinedViolations = true, true, true
+	evalConfig.UserViolations, evalConfig.GroupViolations = true, true
+	w.result.PolicyResults = eval.Eval(config.PolicyPath, w.reachedCollectResult(), evalConfig)
+	if w.result.PolicyResults == nil {
+		return nil // error printed in Eval
+	}
+	return w.result
+}
+
+// ParseIdentity parses an identity in the 'type=identity' format, where type is 'sa', 'node', 'user', 'group' or 'pod',
+// and serviceAccounts and pods are denoted by 'namespace:name'
+func ParseIdentity(identity string) (Identity, error) {
+	separatorIndex := strings.Index(identity, "=")
+	if separatorIndex < 0 {
+		return Identity{}, errors.New("format is 'type=identity'")
+	}
+	identityType, name := identity[:separatorIndex], identity[separatorIndex+1:]
+	switch identityType {
+	case "sa", "pod":
+		separatorIndex = strings.Index(name, ":")
+		if separatorIndex < 0 {
+			return Identity{}, fmt.Errorf("cannot parse %v, format is '%v=namespace:name'", identityType, identityType)
+		}
+		if identityType == "sa" {
+			identityType = "serviceAccount"
+		}
+		return Identity{Type: identityType, Name: name[separatorIndex+1:], Namespace: name[:separatorIndex]}, nil
+	case "node", "user", "group":
+		return Identity{Type: identityType, Name: name}, nil
+	}
+	return Identity{}, fmt.Errorf("unsupported identity type '%v', supported types are 'sa', 'node', 'user', 'group' and 'pod'", identityType)
+}
+
+// FormatIdentity formats @identity in the 'type=identity' format of ParseIdentity()
+func FormatIdentity(identity Identity) string {
+	switch identity.Type {
+	case "serviceAccount":
+		return "sa=" + utils.FullName(identity.Namespace, identity.Name)
+	case "pod":
+		return "pod=" + utils.FullName(identity.Namespace, identity.Name)
+	}
+	return identity.Type + "=" + identity.Name
+}
+
+// Returns an error if @compromised can't be found. Users and groups needn't be bound to roles, so they're always valid.
+func (w *walker) validate(compromised Identity) error {
+	switch compromised.Type {
+	case "serviceAccount":
+		if w.serviceAccount(compromised.Namespace, compromised.Name) == nil {
+			return fmt.Errorf("cannot find serviceAccount %v, use -a to collect serviceAccounts that aren't assigned to pods", utils.FullName(compromised.Namespace, compromised.Name))
+		}
+	case "node":
+		if w.node(compromised.Name) == nil {
+			return fmt.Errorf("cannot find node %v", compromised.Name)
+		}
+	case "pod":
+		if w.podServiceAccount(compromised.Namespace, compromised.Name) == nil {
+			return fmt.Errorf("cannot find pod %v", utils.FullName(compromised.Namespace, compromised.Name))
+		}
+	}
+	return nil
+}
+
+// Records @identity as reached, unless it was already reached
+func (w *walker) reach(identity ReachedIdentity) {
+	key := identityKey(identity.Identity)
+	if _, ok := w.reached[key]; ok {
+		return
+	}
+	w.reached[key] = len(w.result.Identities)
+	w.result.Identities = append(w.result.Identities, identity)
+}
+
+// Returns the steps an attacker controlling @identity can take to other identities
+func (w *walker) steps(identity Identity) []step {
+	var steps []step
+
+	switch identity.Type {
+	case "pod":
+		sa := w.podServiceAccount(identity.Namespace, identity.Name)
+		steps = append(steps, step{To: Identity{Type: "serviceAccount", Name: sa.Name, Namespace: sa.Namespace}, Via: "token mounted into the pod"})
+		return steps
+	case "node":
+		for _, fullName := range w.node(identity.Name).ServiceAccounts {
+			separatorIndex := strings.Index(fullName, ":")
+			steps = append(steps, step{To: Identity{Type: "serviceAccount", Name: fullName[separatorIndex+1:], Namespace: fullName[:separatorIndex]}, Via: "token of a pod on the node"})
+		}
+	case "user", "group":
+		if identity.Type == "user" {
+			for _, roleRef := range w.roleRefs(identity) {
+				if roleRef.Group != "" {
+					steps = append(steps, step{To: Identity{Type: "group", Name: roleRef.Group}, Via: "group membership"})
+				}
+			}
+		}
+		if identity.Name != "system:authenticated" && identity.Name != "system:unauthenticated" {
+			steps = append(steps, step{To: Identity{Type: "group", Name: "system:authenticated"}, Via: "group membership"})
+		}
+	}
+
+	for _, rule := range w.rules(identity) {
+		steps = append(steps, w.ruleSteps(identity, rule)...)
+	}
+	return steps
+}
+
+// Returns the steps an attacker controlling @identity can take via @rule
+func (w *walker) ruleSteps(identity Identity, rule scopedRule) []step {
+	var steps []step
+	isNode := identity.Type == "node"
+	scope := scopeDescription(rule.Namespace)
+
+	// Assign serviceAccounts to pods, nodes can't create pods with serviceAccounts under NodeRestriction
+	if ruleAllows(rule.Rule, "create", "", "pods") && !(isNode && w.nodeRestriction) || canControlPods(rule.Rule) {
+		for _, sa := range w.serviceAccounts(rule.Namespace, nil) {
+			steps = append(steps, step{To: sa, Via: "assign to a pod " + scope})
+		}
+	}
+	// Issue tokens via TokenRequests, nodes can only request tokens for pods bound to them under NodeRestriction
+	if ruleAllows(rule.Rule, "create", "", "serviceaccounts/token") && !(isNode && w.nodeRestriction) {
+		for _, sa := range w.serviceAccounts(rule.Namespace, rule.Rule.ResourceNames) {
+			steps = append(steps, step{To: sa, Via: "create a TokenRequest " + scope})
+		}
+	}
+	// Retrieve or issue token secrets
+	if ruleAllows(rule.Rule, "get", "", "secrets") || ruleAllows(rule.Rule, "list", "", "secrets") {
+		for _, sa := range w.serviceAccounts(rule.Namespace, nil) {
+			if w.canRetrieveToken(sa, rule.Rule.ResourceNames) {
+				steps = append(steps, step{To: sa, Via: "read token secrets " + scope})
+			}
+		}
+	}
+	if ruleAllows(rule.Rule, "create", "", "secrets") {
+		for _, sa := range w.serviceAccounts(rule.Namespace, nil) {
+			if w.canRead(identity, sa.Namespace) {
+				steps = append(steps, step{To: sa, Via: "issue a token secret " + scope})
+			}
+		}
+	}
+	// Impersonate users, groups and serviceAccounts
+	if ruleAllows(rule.Rule, "impersonate", "", "serviceaccounts") {
+		for _, sa := range w.serviceAccounts(rule.Namespace, rule.Rule.ResourceNames) {
+			steps = append(steps, step{To: sa, Via: "impersonation " + scope})
+		}
+	}
+	if rule.Namespace == "" && ruleAllows(rule.Rule, "impersonate", "", "users") {
+		for _, target := range w.impersonatedUsers(rule.Rule.ResourceNames) {
+			steps = append(steps, step{To: target, Via: "impersonation"})
+		}
+	}
+	if rule.Namespace == "" && ruleAllows(rule.Rule, "impersonate", "", "groups") {
+		for _, target := range w.impersonatedGroups(rule.Rule.ResourceNames) {
+			steps = append(steps, step{To: target, Via: "impersonation"})
+		}
+	}
+	return steps
+}
+
+// Returns the identities reachable by impersonating the users in @names, or any user if @names is empty.
+// Users named after serviceAccounts and nodes are resolved to them.
+func (w *walker) impersonatedUsers(names []string) []Identity {
+	var targets []Identity
+	if len(names) == 0 {
+		targets = append(targets, w.serviceAccounts("", nil)...)
+		for _, node := range w.collectResult.Nodes {
+			targets = append(targets, Identity{Type: "node", Name: node.Name})
+		}
+		for _, user := range w.collectResult.Users {
+			targets = append(targets, Identity{Type: "user", Name: user.Name})
+		}
+		return targets
+	}
+	for _, name := range names {
+		if strings.HasPrefix(name, "system:serviceaccount:") {
+			fullName := strings.TrimPrefix(name, "system:serviceaccount:")
+			if separatorIndex := strings.Index(fullName, ":"); separatorIndex >= 0 {
+				targets = append(targets, Identity{Type: "serviceAccount", Name: fullName[separatorIndex+1:], Namespace: fullName[:separatorIndex]})
+				continue
+			}
+		}
+		if strings.HasPrefix(name, "system:node:") && w.node(strings.TrimPrefix(name, "system:node:")) != nil {
+			targets = append(targets, Identity{Type: "node", Name: strings.TrimPrefix(name, "system:node:")})
+			continue
+		}
+		targets = append(targets, Identity{Type: "user", Name: name})
+	}
+	return targets
+}
+
+// Returns the groups reachable by impersonating the groups in @names, or any group if @names is empty
+func (w *walker) impersonatedGroups(names []string) []Identity {
+	var targets []Identity
+	if len(names) == 0 {
+		for _, group := range w.collectResult.Groups {
+			targets = append(targets, Identity{Type: "group", Name: group.Name})
+		}
+		names = []string{mastersGroup}
+	}
+	for _, name := range names {
+		targets = append(targets, Identity{Type: "group", Name: name})
+	}
+	return targets
+}
+
+// Returns whether reading secrets, limited to @resourceNames if set, retrieves a token of @sa.
+// If secrets were collected, per the long-lived tokens of @sa, otherwise per whether token secrets may exist
+func (w *walker) canRetrieveToken(sa Identity, resourceNames []string) bool {
+	if w.collectResult.Secrets == nil {
+		return w.legacyTokens && len(resourceNames) == 0
+	}
+	saEntry := w.serviceAccount(sa.Namespace, sa.Name)
+	if saEntry == nil || saEntry.LegacyToken == nil {
+		return false
+	}
+	if len(resourceNames) == 0 {
+		return true
+	}
+	for _, secret := range saEntry.LegacyToken.Secrets {
+		if utils.Contains(resourceNames, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns whether @identity can get or list secrets in @namespace without being restricted to certain names
+func (w *walker) canRead(identity Identity, namespace string) bool {
+	for _, rule := range w.rules(identity) {
+		if (rule.Namespace == "" || rule.Namespace == namespace) && len(rule.Rule.ResourceNames) == 0 &&
+			(ruleAllows(rule.Rule, "get", "", "secrets") || ruleAllows(rule.Rule, "list", "", "secrets")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the serviceAccounts in @namespace, or in all namespaces if it's empty, limited to @names if set
+func (w *walker) serviceAccounts(namespace string, names []string) []Identity {
+	var sas []Identity
+	for _, sa := range w.collectResult.ServiceAccounts {
+		if namespace != "" && sa.Namespace != namespace {
+			continue
+		}
+		if len(names) > 0 && !utils.Contains(names, sa.Name) {
+			continue
+		}
+		sas = append(sas, Identity{Type: "serviceAccount", Name: sa.Name, Namespace: sa.Namespace})
+	}
+	return sas
+}
+
+// Returns the secrets reachable identities can read
+func (w *walker) readableSecrets() []SecretAccess {
+	var secrets []SecretAccess
+	for _, reached := range w.result.Identities {
+		if reached.Type == "node" {
+			secrets = append(secrets, w.nodeAuthorizerSecrets(reached.Identity)...)
+		}
+		for _, rule := range w.rules(reached.Identity) {
+			var verbs []string
+			for _, verb := range []string{"get", "list", "watch"} {
+				if ruleAllows(rule.Rule, verb, "", "secrets") {
+					verbs = append(verbs, verb)
+				}
+			}
+			if len(verbs) == 0 {
+				continue
+			}
+			secrets = append(secrets, SecretAccess{
+				Identity:      FormatIdentity(reached.Identity),
+				Namespace:     rule.Namespace,
+				Verbs:         verbs,
+				ResourceNames: rule.Rule.ResourceNames,
+			})
+		}
+	}
+	return secrets
+}
+
+// Returns the concrete secrets reachable identities can read, out of the secrets collected
+func (w *walker) concreteReadableSecrets() []expand.ReadableSecret {
+	var readableSecrets []expand.ReadableSecret
+	for _, reached := range w.result.Identities {
+		roles := expand.ExpandRoleRefs(w.roleRefs(reached.Identity), w.collectResult.Roles)
+		identitySecrets := expand.ReadableSecrets(roles, w.collectResult.Secrets)
+		if reached.Type == "node" {
+			if nodeEntry := w.node(reached.Name); nodeEntry != nil {
+				identitySecrets = expand.NodeReadableSecrets(*nodeEntry, roles, w.collectResult.Secrets)
+			}
+		}
+		for _, secret := range identitySecrets {
+			i := 0
+			for i < len(readableSecrets) && (readableSecrets[i].Name != secret.Name || readableSecrets[i].Namespace != secret.Namespace) {
+				i++
+			}
+			if i == len(readableSecrets) {
+				readableSecrets = append(readableSecrets, secret)
+				continue
+			}
+			for _, verb := range secret.Verbs {
+				if !utils.Contains(readableSecrets[i].Verbs, verb) {
+					readableSecrets[i].Verbs = append(readableSecrets[i].Verbs, verb)
+				}
+			}
+			sort.Strings(readableSecrets[i].Verbs)
+			readableSecrets[i].NodeAuthorizer = readableSecrets[i].NodeAuthorizer || secret.NodeAuthorizer
+		}
+	}
+	return readableSecrets
+}
+
+// Returns the secrets the NodeAuthorizer permits @node to get, as pods bound to it reference them
+func (w *walker) nodeAuthorizerSecrets(node Identity) []SecretAccess {
+	var secrets []SecretAccess
+	nodeEntry := w.node(node.Name)
+	if nodeEntry == nil || nodeEntry.NodeAuthorizer == nil {
+		return nil
+	}
+	for _, secret := range nodeEntry.NodeAuthorizer.Secrets {
+		i := 0
+		for i < len(secrets) && secrets[i].Namespace != secret.Namespace {
+			i++
+		}
+		if i == len(secrets) {
+			secrets = append(secrets, SecretAccess{Identity: FormatIdentity(node), Namespace: secret.Namespace, Verbs: []string{"get"}})
+		}
+		secrets[i].ResourceNames = append(secrets[i].ResourceNames, secret.Name)
+	}
+	return secrets
+}
+
+// Returns the cloud provider IAM entities of reachable serviceAccounts
+func (w *walker) providerIAM() []CloudIdentity {
+	var cloudIdentities []CloudIdentity
+	for _, reached := range w.result.Identities {
+		if reached.Type != "serviceAccount" {
+			continue
+		}
+		sa := w.serviceAccount(reached.Namespace, reached.Name)
+		if sa == nil {
+			continue
+		}
+		var providers []string
+		for provider := range sa.ProviderIAM {
+			providers = append(providers, provider)
+		}
+		sort.Strings(providers)
+		for _, provider := range providers {
+			cloudIdentities = append(cloudIdentities, CloudIdentity{Provider: provider, Entity: sa.ProviderIAM[provider], ServiceAccount: utils.FullName(sa.Namespace, sa.Name)})
+		}
+	}
+	return cloudIdentities
+}
+
+// Returns the union of the roles of reachable identities
+func (w *walker) permissions() []expand.ExpandedRole {
+	var roleRefs []collect.RoleRef
+	seen := make(map[collect.RoleRef]struct{})
+	for _, reached := range w.result.Identities {
+		for _, roleRef := range w.roleRefs(reached.Identity) {
+			roleRef.Group = "" // roles users inherit from groups are the groups' roles
+			if _, ok := seen[roleRef]; ok {
+				continue
+			}
+			seen[roleRef] = struct{}{}
+			roleRefs = append(roleRefs, roleRef)
+		}
+	}
+	permissions := expand.ExpandRoleRefs(roleRefs, w.collectResult.Roles)
+	if permissions == nil {
+		return []expand.ExpandedRole{}
+	}
+	return permissions
+}
+
+// Returns a CollectResult narrowed down to the reachable identities
+func (w *walker) reachedCollectResult() collect.CollectResult {
+	narrowed := collect.CollectResult{
+		Metadata:        w.collectResult.Metadata,
+		ServiceAccounts: []collect.ServiceAccountEntry{},
+		Nodes:           []collect.NodeEntry{},
+		Users:           []collect.NamedEntry{},
+		Groups:          []collect.NamedEntry{},
+		Roles:           w.collectResult.Roles,
+		Namespaces:      w.collectResult.Namespaces,
+		Secrets:         w.collectResult.Secrets,
+	}
+	for _, sa := range w.collectResult.ServiceAccounts {
+		if w.isReached(Identity{Type: "serviceAccount", Name: sa.Name, Namespace: sa.Namespace}) {
+			narrowed.ServiceAccounts = append(narrowed.ServiceAccounts, sa)
+		}
+	}
+	for _, node := range w.collectResult.Nodes {
+		if w.isReached(Identity{Type: "node", Name: node.Name}) {
+			narrowed.Nodes = append(narrowed.Nodes, node)
+		}
+	}
+	for _, user := range w.collectResult.Users {
+		if w.isReached(Identity{Type: "user", Name: user.Name}) {
+			narrowed.Users = append(narrowed.Users, user)
+		}
+	}
+	for _, group := range w.collectResult.Groups {
+		if w.isReached(Identity{Type: "group", Name: group.Name}) {
+			narrowed.Groups = append(narrowed.Groups, group)
+		}
+	}
+	return narrowed
+}
+
+// Returns whether @identity was reached
+func (w *walker) isReached(identity Identity) bool {
+	_, ok := w.reached[identityKey(identity)]
+	return ok
+}
+
+// Returns the rules granted to @identity, pods aren't granted rules
+func (w *walker) rules(identity Identity) []scopedRule {
+	var rules []scopedRule
+	for _, roleRef := range w.roleRefs(identity) {
+		for _, role := range w.collectResult.Roles {
+			if role.Name != roleRef.Name || role.Namespace != roleRef.Namespace {
+				continue
+			}
+			for _, rule := range role.Rules {
+				rules = append(rules, scopedRule{Namespace: roleRef.EffectiveNamespace, Rule: rule})
+			}
+			break
+		}
+	}
+	return rules
+}
+
+// Returns the roleRefs of @identity
+func (w *walker) roleRefs(identity Identity) []collect.RoleRef {
+	switch identity.Type {
+	case "serviceAccount":
+		if sa := w.serviceAccount(identity.Namespace, identity.Name); sa != nil {
+			return sa.Roles
+		}
+	case "node":
+		if node := w.node(identity.Name); node != nil {
+			return node.Roles
+		}
+	case "user":
+		for _, user := range w.collectResult.Users {
+			if user.Name == identity.Name {
+				return user.Roles
+			}
+		}
+	case "group":
+		for _, group := range w.collectResult.Groups {
+			if group.Name == identity.Name {
+				return group.Roles
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the serviceAccount entry of @namespace:@name, or nil if it doesn't exist
+func (w *walker) serviceAccount(namespace string, name string) *collect.ServiceAccountEntry {
+	for i, sa := range w.collectResult.ServiceAccounts {
+		if sa.Equals(name, namespace) {
+			return &w.collectResult.ServiceAccounts[i]
+		}
+	}
+	return nil
+}
+
+// Returns the node entry of @name, or nil if it doesn't exist
+func (w *walker) node(name string) *collect.NodeEntry {
+	for i, node := range w.collectResult.Nodes {
+		if node.Name == name {
+			return &w.collectResult.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// Returns the serviceAccount entry assigned to pod @namespace:@name, or nil if the pod doesn't exist
+func (w *walker) podServiceAccount(namespace string, name string) *collect.ServiceAccountEntry {
+	for i, sa := range w.collectResult.ServiceAccounts {
+		if sa.Namespace != namespace {
+			continue
+		}
+		for _, nodeToPods := range sa.Nodes {
+			if utils.Contains(nodeToPods.Pods, name) {
+				return &w.collectResult.ServiceAccounts[i]
+			}
+		}
+	}
+	return nil
+}
+
+// Returns a key identifying @identity
+func identityKey(identity Identity) string {
+	return identity.Type + "/" + utils.FullName(identity.Namespace, identity.Name)
+}
+
+// Describes the scope of a rule in effect in @namespace
+func scopeDescription(namespace string) string {
+	if namespace == "" {
+		return "cluster-wide"
+	}
+	return "in namespace " + namespace
+}
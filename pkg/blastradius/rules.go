@@ -0,0 +1,49 @@
+package blastradius
+
+import (
+	"strings"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+	rbac "k8s.io/api/rbac/v1"
+)
+
+// Resources that control pods, by their API group
+var podControllers = map[string][]string{
+	"":      {"replicationcontrollers"},
+	"apps":  {"daemonsets", "deployments", "replicasets", "statefulsets"},
+	"batch": {"cronjobs", "jobs"},
+}
+
+// Returns whether @rule allows @verb on @resource, which may be a subresource, in @apiGroup.
+// ResourceNames aren't considered.
+func ruleAllows(rule rbac.PolicyRule, verb string, apiGroup string, resource string) bool {
+	if !valueOrWildcard(rule.Verbs, verb) || !valueOrWildcard(rule.APIGroups, apiGroup) {
+		return false
+	}
+	if valueOrWildcard(rule.Resources, resource) {
+		return true
+	}
+	if separatorIndex := strings.Index(resource, "/"); separatorIndex >= 0 {
+		return utils.Contains(rule.Resources, "*"+resource[separatorIndex:])
+	}
+	return false
+}
+
+// Returns whether @rule allows creating, updating or patching a pod controller
+func canControlPods(rule rbac.PolicyRule) bool {
+	for apiGroup, resources := range podControllers {
+		for _, resource := range resources {
+			for _, verb := range []string{"create", "update", "patch"} {
+				if ruleAllows(rule, verb, apiGroup, resource) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Returns whether @arr contains @value or a wildcard
+func valueOrWildcard(arr []string, value string) bool {
+	return utils.Contains(arr, value) || utils.Contains(arr, "*")
+}
@@ -0,0 +1,76 @@
+package blastradius
+
+import (
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/expand"
+	rbac "k8s.io/api/rbac/v1"
+)
+
+// BlastRadiusConfig holds the options for BlastRadius()
+type BlastRadiusConfig struct {
+	PolicyPath string
+	EvalConfig eval.EvalConfig
+}
+
+// Identity is a Kubernetes identity, or a pod, an attacker may control
+type Identity struct {
+	Type      string `json:"type"` // serviceAccount, node, user, group or pod
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// BlastRadiusResult is the output of BlastRadius()
+type BlastRadiusResult struct {
+	Compromised     Identity                `json:"compromised"`
+	Identities      []ReachedIdentity       `json:"identities"`                // identities reachable from the compromised one, including itself
+	Secrets         []SecretAccess          `json:"secrets,omitempty"`         // secrets reachable identities can read
+	ReadableSecrets []expand.ReadableSecret `json:"readableSecrets,omitempty"` // if secrets were collected, the concrete secrets reachable identities can read
+	ProviderIAM     []CloudIdentity         `json:"providerIAM,omitempty"`     // cloud provider IAM entities of reachable serviceAccounts
+	Permissions     []expand.ExpandedRole   `json:"permissions"`               // union of the roles of reachable identities
+	PolicyResults   *eval.PolicyResults     `json:"policyResults"`             // violations of reachable identities
+}
+
+// ReachedIdentity is an identity reachable from the compromised one, and the first step that reaches it
+type ReachedIdentity struct {
+	Identity
+	Depth int    `json:"depth"`          // number of steps from the compromised identity
+	From  string `json:"from,omitempty"` // identity the step is taken from
+	Via   string `json:"via,omitempty"`  // how the step is taken
+}
+
+// SecretAccess denotes secrets a reachable identity can read
+type SecretAccess struct {
+	Identity      string   `json:"identity"`
+	Namespace     string   `json:"namespace,omitempty"` // empty for all namespaces
+	Verbs         []string `json:"verbs"`
+	ResourceNames []string `json:"resourceNames,omitempty"`
+}
+
+// CloudIdentity is a cloud provider IAM entity attached to a reachable serviceAccount
+type CloudIdentity struct {
+	Provider       string `json:"provider"`
+	Entity         string `json:"entity"`
+	ServiceAccount string `json:"serviceAccount"`
+}
+
+// A rule granted to an identity, in effect in a namespace or cluster-wide
+type scopedRule struct {
+	Namespace string // empty for rules in effect cluster-wide
+	Rule      rbac.PolicyRule
+}
+
+// A step from an identity to another
+type step struct {
+	To  Identity
+	Via string
+}
+
+// Walks the identities reachable from a compromised one over a CollectResult
+type walker struct {
+	collectResult   collect.CollectResult
+	nodeRestriction bool
+	legacyTokens    bool // whether serviceAccount token secrets may exist
+	reached         map[string]int
+	result          *BlastRadiusResult
+}
@@ -2,6 +2,7 @@ package collect
 
 import (
 	"context"
+	"encoding/json"
 
 	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
@@ -32,10 +33,15 @@ func buildClusterDb(clientset *kubernetes.Clientset, ns string, ignoreControlPla
 	if err != nil {
 		return nil // error printed in getPods
 	}
+	clusterDb.Namespaces, err = getNamespaces(clientset, ns)
+	if err != nil {
+		clusterDb.Namespaces = []v1.Namespace{} // namespaces only provide context for policies, continue without them
+	}
 	clusterDb.Pods, err = getPods(clientset, ns)
 	if err != nil {
 		return nil // error printed in getPods
 	}
+	clusterDb.Secrets, _ = getSecrets(clientset, ns) // secrets only provide context for policies, continue without them
 	if ignoreControlPlane {
 		removePodsFromExcludedNodes(&clusterDb) // remove control plane pods if needed
 	}
@@ -62,6 +68,65 @@ func getPods(clientset *kubernetes.Clientset, ns string) ([]v1.Pod, error) {
 	return podList.Items, nil
 }
 
+// Get the metadata and type of all secrets cluster-wide, or in a namespace if @ns is set.
+// Requests a server-side table, like 'kubectl get secrets' does, so the secrets' data is never retrieved
+func getSecrets(clientset *kubernetes.Clientset, ns string) ([]v1.Secret, error) {
+	tableBytes, err := clientset.CoreV1().RESTClient().Get().
+		Namespace(ns).
+		Resource("secrets").
+		SetHeader("Accept", "application/json;as=Table;v=v1;g=meta.k8s.io").
+		Param("includeObject", "Metadata").
+		Do(context.Background()).
+		Raw()
+	if err != nil {
+		log.Warnln("getSecrets: failed to retrieve secrets with", err)
+		return nil, err
+	}
+	var table metav1.Table
+	if err = json.Unmarshal(tableBytes, &table); err != nil {
+		log.Warnln("getSecrets: failed to decode secrets with", err)
+		return nil, err
+	}
+	typeColumn := -1
+	for i, column := range table.ColumnDefinitions {
+		if column.Name == "Type" {
+			typeColumn = i
+			break
+		}
+	}
+
+	secrets := []v1.Secret{}
+	for _, row := range table.Rows {
+		var objMeta metav1.PartialObjectMetadata
+		if err = json.Unmarshal(row.Object.Raw, &objMeta); err != nil {
+			log.Warnln("getSecrets: failed to decode a secret's metadata with", err)
+			continue
+		}
+		secret := v1.Secret{ObjectMeta: objMeta.ObjectMeta}
+		if typeColumn >= 0 && typeColumn < len(row.Cells) {
+			if secretType, ok := row.Cells[typeColumn].(string); ok {
+				secret.Type = v1.SecretType(secretType)
+			}
+		}
+		secrets = append(secrets, secretMetadata(secret))
+	}
+	return secrets, nil
+}
+
+// Get all namespaces, or only @ns if set
+func getNamespaces(clientset *kubernetes.Clientset, ns string) ([]v1.Namespace, error) {
+	listOptions := metav1.ListOptions{}
+	if ns != "" {
+		listOptions.FieldSelector = "metadata.name=" + ns
+	}
+	namespaceList, err := clientset.CoreV1().Namespaces().List(context.Background(), listOptions)
+	if err != nil {
+		log.Warnln("getNamespaces: failed to retrieve namespaces with", err)
+		return nil, err
+	}
+	return namespaceList.Items, nil
+}
+
 // Get nodes, drop control plane nodes if @ignoreControlPlane is set
 func getNodes(clientset *kubernetes.Clientset, ignoreControlPlane bool) ([]v1.Node, error) {
 	listOptions := metav1.ListOptions{}
@@ -4,11 +4,16 @@ import (
 	"strings"
 
 	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth" // in order to connect to clusters via auth plugins
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
+// Label set by Pod Security Admission on namespaces that enforce a pod security level
+const podSecurityEnforceLabel = "pod-security.kubernetes.io/enforce"
+
 // Collect retrieves the RBAC settings in a k8s cluster
 func Collect(collectConfig CollectConfig) *CollectResult {
 	var metadata *ClusterMetadata
@@ -32,15 +37,27 @@ func Collect(collectConfig CollectConfig) *CollectResult {
 	if clusterDb == nil {
 		return nil // error printed in buildClusterDb or in parseLocalCluster
 	}
+	return collectResultFromClusterDb(clusterDb, metadata, collectConfig, kubeConfig)
+}
 
+// Builds a CollectResult from @clusterDb and @metadata
+func collectResultFromClusterDb(clusterDb *ClusterDb, metadata *ClusterMetadata, collectConfig CollectConfig, kubeConfig clientcmd.ClientConfig) *CollectResult {
 	if collectConfig.DiscoverProtections {
 		discoverRelevantControlPlaneFeatures(collectConfig, kubeConfig, clusterDb, metadata)
 	}
+	applyFeatureOverrides(collectConfig, metadata)
 
 	rbacDb := buildRbacDb(*clusterDb, collectConfig)
 	if rbacDb == nil {
 		return nil // error printed in BuildClusterDb
 	}
+	if collectConfig.GroupMembership != "" {
+		memberships, err := ReadGroupMemberships(collectConfig.GroupMembership)
+		if err != nil {
+			return nil // error printed in ReadGroupMemberships
+		}
+		mergeGroupMemberships(rbacDb, memberships)
+	}
 
 	return &CollectResult{
 		Metadata:        *metadata,
@@ -49,24 +66,52 @@ func Collect(collectConfig CollectConfig) *CollectResult {
 		Users:           rbacDb.Users,
 		Groups:          rbacDb.Groups,
 		Roles:           rbacDb.Roles,
+		Namespaces:      buildNamespaceEntries(clusterDb.Namespaces),
+		Secrets:         buildSecretEntries(clusterDb.Secrets, rbacDb),
 	}
 }
 
+// Builds the namespace entries policies consume from @namespaces
+func buildNamespaceEntries(namespaces []v1.Namespace) []NamespaceEntry {
+	namespaceEntries := []NamespaceEntry{}
+	for _, ns := range namespaces {
+		namespaceEntries = append(namespaceEntries, NamespaceEntry{
+			Name:               ns.Name,
+			Labels:             ns.Labels,
+			PodSecurityEnforce: ns.Labels[podSecurityEnforceLabel],
+		})
+	}
+	return namespaceEntries
+}
+
 // Initialize the Kubernetes client
 func initKubeClient() (*kubernetes.Clientset, clientcmd.ClientConfig, error) {
-	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
-	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{})
-	config, err := kubeConfig.ClientConfig()
+	config, err := RestConfig()
 	if err != nil {
-		log.Errorln("initKubeClient: failed creating ClientConfig with", err)
-		return nil, nil, err
+		return nil, nil, err // error printed in RestConfig
 	}
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		log.Errorln("initKubeClient: failed creating Clientset with", err)
 		return nil, nil, err
 	}
-	return clientset, kubeConfig, nil
+	return clientset, defaultKubeConfig(), nil
+}
+
+// Returns the client config of the current kubeconfig context
+func defaultKubeConfig() clientcmd.ClientConfig {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{})
+}
+
+// Returns the REST config of the current kubeconfig context, for clients beside the Clientset
+func RestConfig() (*rest.Config, error) {
+	config, err := defaultKubeConfig().ClientConfig()
+	if err != nil {
+		log.Errorln("RestConfig: failed creating ClientConfig with", err)
+		return nil, err
+	}
+	return config, nil
 }
 
 // Get cluster metadata
@@ -5,6 +5,8 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -17,26 +19,90 @@ import (
 // NOTE: Uses impersonation and dry-run write operations, which won't affect the cluster, but may be logged / audited on.
 func discoverRelevantControlPlaneFeatures(collectConfig CollectConfig, kubeConfig clientcmd.ClientConfig, clusterDb *ClusterDb, metadata *ClusterMetadata) {
 	if legacyTokenSecretsReducted(clusterDb, collectConfig.Namespace) {
-		metadata.Features = append(metadata.Features, "LegacyTokenSecretsReducted")
+		addFeature(metadata, "LegacyTokenSecretsReducted", FeatureDiscovered)
 	}
 	// If NodeAuthorization is used, and we're not running in offline mode, check for NodeRestriction
 	if collectConfig.NodeUser == "" && collectConfig.OfflineDir == "" {
 		if NodeRestrictionEnabled(kubeConfig, clusterDb, metadata) {
-			metadata.Features = append(metadata.Features, "NodeRestriction")
-			// If the cluster's version >=1.17, populate NodeRestriction1.17
-			major, err := strconv.Atoi(metadata.Version.Major)
-			if err == nil {
-				minor, err := strconv.Atoi(metadata.Version.Minor)
-				if err == nil {
-					if major > 1 || minor >= 17 {
-						metadata.Features = append(metadata.Features, "NodeRestriction1.17")
-					}
-				}
-			}
+			addFeature(metadata, "NodeRestriction", FeatureDiscovered)
 		}
 	}
 }
 
+// Sets and removes features per @collectConfig's AssumeFeatures and DenyFeatures,
+// then infers the features that follow from the cluster's version
+func applyFeatureOverrides(collectConfig CollectConfig, metadata *ClusterMetadata) {
+	for _, feature := range append(append([]string{}, collectConfig.AssumeFeatures...), collectConfig.DenyFeatures...) {
+		if !utils.Contains(knownFeatures, feature) {
+			log.Warnf("applyFeatureOverrides: %q isn't a feature built-in policies consider, known features are %v\n", feature, strings.Join(knownFeatures, ", "))
+		}
+	}
+	for _, feature := range collectConfig.AssumeFeatures {
+		addFeature(metadata, feature, FeatureAssumed)
+	}
+	for _, feature := range collectConfig.DenyFeatures {
+		removeFeature(metadata, feature)
+	}
+	inferFeaturesFromVersion(metadata, collectConfig.DenyFeatures)
+}
+
+// Infers features from the cluster's version and the other features in @metadata, unless they're in @denied.
+// NodeRestriction1.17 follows from NodeRestriction on clusters of version 1.17 or above
+func inferFeaturesFromVersion(metadata *ClusterMetadata, denied []string) {
+	if !utils.Contains(metadata.Features, "NodeRestriction") || utils.Contains(denied, "NodeRestriction1.17") {
+		return
+	}
+	major, err := strconv.Atoi(metadata.Version.Major)
+	if err != nil {
+		return
+	}
+	minor, err := strconv.Atoi(strings.TrimSuffix(metadata.Version.Minor, "+")) // e.g. '22+' on EKS and GKE
+	if err != nil {
+		return
+	}
+	if major > 1 || minor >= 17 {
+		addFeature(metadata, "NodeRestriction1.17", FeatureInferred)
+	}
+}
+
+// Adds @feature to the features of @metadata and records its @source,
+// unless it's already there, e.g. read from a snapshot
+func addFeature(metadata *ClusterMetadata, feature string, source string) {
+	for _, existing := range metadata.Features {
+		if existing == feature {
+			return
+		}
+	}
+	metadata.Features = append(metadata.Features, feature)
+	if metadata.FeatureSources == nil {
+		metadata.FeatureSources = make(map[string]string)
+	}
+	metadata.FeatureSources[feature] = source
+}
+
+// Removes @feature and its source from @metadata
+func removeFeature(metadata *ClusterMetadata, feature string) {
+	features := []string{}
+	for _, existing := range metadata.Features {
+		if existing != feature {
+			features = append(features, existing)
+		}
+	}
+	metadata.Features = features
+	delete(metadata.FeatureSources, feature)
+}
+
+// Returns the features of @metadata that were discovered, rather than assumed or inferred from the cluster's version
+func discoveredFeatures(metadata *ClusterMetadata) []string {
+	features := []string{}
+	for _, feature := range metadata.Features {
+		if source, ok := metadata.FeatureSources[feature]; !ok || source == FeatureDiscovered {
+			features = append(features, feature)
+		}
+	}
+	return features
+}
+
 // Best effort test for whether serviceAccount tokens are stored as secrets
 func legacyTokenSecretsReducted(clusterDb *ClusterDb, ns string) bool {
 	// If collection is scoped to ns, use its default serviceAccount for testing,
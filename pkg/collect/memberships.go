@@ -0,0 +1,183 @@
+package collect
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const awsAuthConfigMap = "aws-auth"
+
+// Column names identifying the users and groups columns of group membership CSVs, lowercased
+var (
+	csvUserColumns  = []string{"user", "username", "member", "email", "uid"}
+	csvGroupColumns = []string{"group", "groups", "groupname", "memberof"}
+)
+
+// An entry of the mapUsers or mapRoles lists of the EKS aws-auth configmap
+type awsAuthMapping struct {
+	UserARN  string   `json:"userarn,omitempty"`
+	RoleARN  string   `json:"rolearn,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Groups   []string `json:"groups,omitempty"`
+}
+
+// ReadGroupMemberships reads the group memberships of users from the file at @path. Supports a static YAML file
+// mapping users to groups and / or groups to users, a CSV export of users and their groups (e.g. from an OIDC
+// provider or LDAP) with a header row, and the EKS aws-auth configmap.
+func ReadGroupMemberships(path string) (GroupMemberships, error) {
+	membershipBytes, err := utils.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return parseCSVMemberships(path, membershipBytes)
+	}
+
+	var configMap v1.ConfigMap
+	if err = yaml.Unmarshal(membershipBytes, &configMap); err == nil && configMap.Kind == "ConfigMap" {
+		return parseAwsAuthMemberships(path, configMap)
+	}
+	var static staticGroupMemberships
+	if err = yaml.UnmarshalStrict(membershipBytes, &static); err != nil {
+		log.Errorf("ReadGroupMemberships: failed to parse %v as static group memberships or as the aws-auth configmap with %v\n", path, err)
+		return nil, err
+	}
+	memberships := make(GroupMemberships)
+	for user, groups := range static.Users {
+		memberships.add(user, groups...)
+	}
+	for group, users := range static.Groups {
+		for _, user := range users {
+			memberships.add(user, group)
+		}
+	}
+	return memberships, nil
+}
+
+// Parses the CSV @membershipBytes read from @path, whose header names a users column and a groups column.
+// Cells of the groups column may list several groups separated by ';'.
+func parseCSVMemberships(path string, membershipBytes []byte) (GroupMemberships, error) {
+	reader := csv.NewReader(bytes.NewReader(membershipBytes))
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		log.Errorf("parseCSVMemberships: failed to parse %v with %v\n", path, err)
+		return nil, err
+	}
+	if len(records) == 0 {
+		return GroupMemberships{}, nil
+	}
+
+	userColumn, groupColumn := -1, -1
+	for i, column := range records[0] {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if userColumn < 0 && utils.Contains(csvUserColumns, column) {
+			userColumn = i
+		} else if groupColumn < 0 && utils.Contains(csvGroupColumns, column) {
+			groupColumn = i
+		}
+	}
+	if userColumn < 0 || groupColumn < 0 {
+		err = fmt.Errorf("header should name a users column (%v) and a groups column (%v)", strings.Join(csvUserColumns, ", "), strings.Join(csvGroupColumns, ", "))
+		log.Errorf("parseCSVMemberships: failed to parse %v, %v\n", path, err)
+		return nil, err
+	}
+
+	memberships := make(GroupMemberships)
+	for _, record := range records[1:] {
+		if userColumn >= len(record) || groupColumn >= len(record) {
+			continue
+		}
+		user := strings.TrimSpace(record[userColumn])
+		for _, group := range strings.Split(record[groupColumn], ";") {
+			if group = strings.TrimSpace(group); user != "" && group != "" {
+				memberships.add(user, group)
+			}
+		}
+	}
+	return memberships, nil
+}
+
+// Parses the mapUsers and mapRoles of the aws-auth @configMap read from @path. Users default to the mapped ARN,
+// as EKS does. Mappings of nodes are skipped, as nodes' groups are configured via --node-groups.
+func parseAwsAuthMemberships(path string, configMap v1.ConfigMap) (GroupMemberships, error) {
+	if configMap.Name != "" && configMap.Name != awsAuthConfigMap {
+		log.Warnf("parseAwsAuthMemberships: expected the %v configmap in %v, got %v\n", awsAuthConfigMap, path, configMap.Name)
+	}
+	memberships := make(GroupMemberships)
+	for _, key := range []string{"mapUsers", "mapRoles"} {
+		var mappings []awsAuthMapping
+		if err := yaml.Unmarshal([]byte(configMap.Data[key]), &mappings); err != nil {
+			log.Errorf("parseAwsAuthMemberships: failed to parse %v of %v with %v\n", key, path, err)
+			return nil, err
+		}
+		for _, mapping := range mappings {
+			user := mapping.Username
+			if user == "" {
+				user = mapping.UserARN + mapping.RoleARN
+			}
+			if strings.HasPrefix(user, "system:node:") {
+				continue
+			}
+			memberships.add(user, mapping.Groups...)
+		}
+	}
+	return memberships, nil
+}
+
+// Adds @user as a member of @groups
+func (memberships GroupMemberships) add(user string, groups ...string) {
+	for _, group := range groups {
+		if !utils.Contains(memberships[user], group) {
+			memberships[user] = append(memberships[user], group)
+		}
+	}
+}
+
+// Merges the roles users inherit from their groups per @memberships into their roles in @rbacDb, marking each inherited
+// role with its group. Users that are only granted roles via their groups are added to @rbacDb.
+func mergeGroupMemberships(rbacDb *RbacDb, memberships GroupMemberships) {
+	var users []string
+	for user := range memberships {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+
+	for _, user := range users {
+		var inherited []RoleRef
+		for _, group := range memberships[user] {
+			for _, grp := range rbacDb.Groups {
+				if grp.Name != group {
+					continue
+				}
+				for _, roleRef := range grp.Roles {
+					roleRef.Group = group
+					inherited = append(inherited, roleRef)
+				}
+			}
+		}
+		if len(inherited) == 0 {
+			continue
+		}
+		userAlreadyInDb := false
+		for i := range rbacDb.Users {
+			if rbacDb.Users[i].Name == user {
+				rbacDb.Users[i].Roles = append(rbacDb.Users[i].Roles, inherited...)
+				userAlreadyInDb = true
+				break
+			}
+		}
+		if !userAlreadyInDb {
+			rbacDb.Users = append(rbacDb.Users, NamedEntry{Name: user, Roles: inherited})
+		}
+	}
+}
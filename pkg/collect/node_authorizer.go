@@ -0,0 +1,142 @@
+package collect
+
+import (
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Populates the objects the NodeAuthorizer permits each node in @rbacDb to access, per the pods in @cDb bound to it.
+// Like the NodeAuthorizer's graph, nodes can read the secrets, configMaps and persistentVolumeClaims referenced by
+// their pods, and issue tokens for the serviceAccounts of their pods.
+func populateNodeAuthorizerAccess(rbacDb *RbacDb, cDb ClusterDb) {
+	for i := range rbacDb.Nodes {
+		rbacDb.Nodes[i].NodeAuthorizer = &NodeAuthorizerAccess{
+			Secrets:                []NodeObjectRef{},
+			ConfigMaps:             []NodeObjectRef{},
+			PersistentVolumeClaims: []NodeObjectRef{},
+			ServiceAccountTokens:   []NodeObjectRef{},
+		}
+	}
+
+	for _, pod := range cDb.Pods {
+		var access *NodeAuthorizerAccess
+		for i := range rbacDb.Nodes {
+			if rbacDb.Nodes[i].Name == pod.Spec.NodeName {
+				access = rbacDb.Nodes[i].NodeAuthorizer
+				break
+			}
+		}
+		if access == nil {
+			continue // unscheduled, or scheduled on an ignored node
+		}
+
+		secrets, configMaps, claims, audiences := podReferences(pod)
+		for _, secret := range secrets {
+			access.Secrets = addNodeObjectRef(access.Secrets, pod, secret, nil)
+		}
+		for _, configMap := range configMaps {
+			access.ConfigMaps = addNodeObjectRef(access.ConfigMaps, pod, configMap, nil)
+		}
+		for _, claim := range claims {
+			access.PersistentVolumeClaims = addNodeObjectRef(access.PersistentVolumeClaims, pod, claim, nil)
+		}
+		serviceAccount := pod.Spec.ServiceAccountName
+		if serviceAccount == "" {
+			serviceAccount = "default"
+		}
+		access.ServiceAccountTokens = addNodeObjectRef(access.ServiceAccountTokens, pod, serviceAccount, audiences)
+	}
+}
+
+// Adds @pod's reference to the object named @name in its namespace to @refs, with the token @audiences it requests
+func addNodeObjectRef(refs []NodeObjectRef, pod v1.Pod, name string, audiences []string) []NodeObjectRef {
+	for i := range refs {
+		if refs[i].Name == name && refs[i].Namespace == pod.Namespace {
+			if !utils.Contains(refs[i].Pods, pod.Name) {
+				refs[i].Pods = append(refs[i].Pods, pod.Name)
+			}
+			for _, audience := range audiences {
+				if !utils.Contains(refs[i].Audiences, audience) {
+					refs[i].Audiences = append(refs[i].Audiences, audience)
+				}
+			}
+			return refs
+		}
+	}
+	return append(refs, NodeObjectRef{Name: name, Namespace: pod.Namespace, Pods: []string{pod.Name}, Audiences: audiences})
+}
+
+// Returns the names of the secrets, configMaps and persistentVolumeClaims @pod references,
+// and the audiences of the serviceAccount tokens projected into it
+func podReferences(pod v1.Pod) ([]string, []string, []string, []string) {
+	var secrets, configMaps, claims, audiences []string
+	addName := func(names []string, name string) []string {
+		if name == "" || utils.Contains(names, name) {
+			return names
+		}
+		return append(names, name)
+	}
+
+	for _, pullSecret := range pod.Spec.ImagePullSecrets {
+		secrets = addName(secrets, pullSecret.Name)
+	}
+	var containers []v1.Container
+	containers = append(containers, pod.Spec.InitContainers...)
+	containers = append(containers, pod.Spec.Containers...)
+	for _, ephemeralContainer := range pod.Spec.EphemeralContainers {
+		containers = append(containers, v1.Container(ephemeralContainer.EphemeralContainerCommon))
+	}
+	for _, container := range containers {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.SecretRef != nil {
+				secrets = addName(secrets, envFrom.SecretRef.Name)
+			}
+			if envFrom.ConfigMapRef != nil {
+				configMaps = addName(configMaps, envFrom.ConfigMapRef.Name)
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if env.ValueFrom.SecretKeyRef != nil {
+				secrets = addName(secrets, env.ValueFrom.SecretKeyRef.Name)
+			}
+			if env.ValueFrom.ConfigMapKeyRef != nil {
+				configMaps = addName(configMaps, env.ValueFrom.ConfigMapKeyRef.Name)
+			}
+		}
+	}
+
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Secret != nil {
+			secrets = addName(secrets, volume.Secret.SecretName)
+		}
+		if volume.ConfigMap != nil {
+			configMaps = addName(configMaps, volume.ConfigMap.Name)
+		}
+		if volume.CSI != nil && volume.CSI.NodePublishSecretRef != nil {
+			secrets = addName(secrets, volume.CSI.NodePublishSecretRef.Name)
+		}
+		if volume.PersistentVolumeClaim != nil {
+			claims = addName(claims, volume.PersistentVolumeClaim.ClaimName)
+		}
+		if volume.Ephemeral != nil {
+			claims = addName(claims, pod.Name+"-"+volume.Name) // the claim of a generic ephemeral volume
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.Secret != nil {
+					secrets = addName(secrets, source.Secret.Name)
+				}
+				if source.ConfigMap != nil {
+					configMaps = addName(configMaps, source.ConfigMap.Name)
+				}
+				if source.ServiceAccountToken != nil {
+					audiences = addName(audiences, source.ServiceAccountToken.Audience)
+				}
+			}
+		}
+	}
+	return secrets, configMaps, claims, audiences
+}
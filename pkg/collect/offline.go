@@ -1,153 +1,513 @@
 package collect
 
 import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
-	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/serializer"
-	"k8s.io/apimachinery/pkg/version"
+	"io"
+	"io/fs"
 	"os"
 	"path"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/apimachinery/pkg/version"
+	"sigs.k8s.io/yaml"
+
 	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
 	v1 "k8s.io/api/core/v1"
 	rbac "k8s.io/api/rbac/v1"
 )
 
-// parseLocalCluster parses k8s manifests from a local directory into ClusterDb and ClusterMetadata objects
+// Denotes reading the local cluster from stdin
+const stdinInput = "-"
+
+// Parses local inputs into a ClusterDb and ClusterMetadata
+type localClusterParser struct {
+	config           CollectConfig
+	decodeFunc       func([]byte, *schema.GroupVersionKind, runtime.Object) (runtime.Object, *schema.GroupVersionKind, error)
+	clusterDb        ClusterDb
+	metadata         ClusterMetadata
+	objects          int            // number of objects added to clusterDb
+	ignoredKinds     map[string]int // kinds of the objects that were ignored, mapped to their count
+	synthesizePods   bool           // whether to synthesize pods from the pod templates of workloads
+	workloads        []workload     // workloads to synthesize pods from
+	defaultNamespace string         // namespace of namespaced objects that don't specify one, if set
+}
+
+// A workload's pod template, from which pods are synthesized
+type workload struct {
+	Kind     string
+	Metadata metav1.ObjectMeta
+	Template v1.PodTemplateSpec
+}
+
+// parseLocalCluster parses k8s manifests from local inputs into ClusterDb and ClusterMetadata objects.
+// config.OfflineDir may be a directory, which is searched recursively, a manifest file, a (gzipped) tarball, or '-' for stdin.
+// Manifests may hold multiple YAML documents, single objects or Lists. Objects of unrelated kinds are ignored.
 func parseLocalCluster(config CollectConfig) (*ClusterDb, *ClusterMetadata) {
-	var versionInfo version.Info
-	var inputFiles []string
-	metadata := ClusterMetadata{
-		Features: []string{},
+	p := newLocalClusterParser(config)
+	if p == nil {
+		return nil, nil // error printed in newLocalClusterParser
 	}
-
-	// Read local dir
-	files, err := os.ReadDir(config.OfflineDir)
-	if err != nil {
-		log.Errorf("parseLocalCluster: failed to read local dir %q with %v", config.OfflineDir, err)
+	if err := p.parseLocation(config.OfflineDir); err != nil {
+		return nil, nil // error printed in parseLocation
+	}
+	if p.objects == 0 {
+		log.Errorf("parseLocalCluster: couldn't find pods, nodes, serviceAccounts, namespaces or RBAC objects in %q\n", config.OfflineDir)
 		return nil, nil
 	}
+	p.warnIgnoredAndMissing(true)
 
-	// Iterate local dir
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-		if file.Name() == "cluster_name" {
-			// Parse cluster_name file into metadata
-			if nameBytes, err := os.ReadFile(path.Join(config.OfflineDir, file.Name())); err != nil {
-				log.Warnf("parseLocalCluster: failed to read cluster name from local dir  %v", err)
-			} else {
-				metadata.ClusterName = strings.TrimSuffix(string(nameBytes), "\n")
-			}
-		} else if file.Name() == "version.json" {
-			// Parse version.json file into metadata
-			if versionBytes, err := os.ReadFile(path.Join(config.OfflineDir, file.Name())); err != nil {
-				log.Warnf("parseLocalCluster: failed to read version.json with %v\n", err)
-			} else {
-				err = json.Unmarshal(versionBytes, &versionInfo)
-				if err != nil {
-					log.Warnf("parseLocalCluster: failed to unmarshal %s into a version.Info obj with %v\n", file.Name(), err)
-				} else {
-					metadata.Version = ClusterVersion{
-						Major:      versionInfo.Major,
-						Minor:      versionInfo.Minor,
-						GitVersion: versionInfo.GitVersion,
-					}
-					metadata.Platform = platformFromVersion(versionInfo.GitVersion)
-				}
-			}
-		} else if strings.HasSuffix(file.Name(), ".yaml") || strings.HasSuffix(file.Name(), ".json") {
-			// Add input files (e.g. 'pods.json', 'nodes.yaml', etc.)
-			inputFiles = append(inputFiles, path.Join(config.OfflineDir, file.Name()))
+	if config.IgnoreControlPlane {
+		removePodsFromExcludedNodes(&p.clusterDb) // remove control plane pods if needed
+	}
+	return &p.clusterDb, &p.metadata
+}
+
+// CollectWithManifests merges the objects in @manifests into the local cluster at collectConfig.OfflineDir,
+// or into an empty cluster if it isn't set, and retrieves the RBAC settings of the merged cluster.
+// Namespaced objects in @manifests that don't specify a namespace are placed in @namespace.
+// Pods are synthesized from the pod templates of workloads in @manifests, see synthesizeWorkloadPods.
+func CollectWithManifests(collectConfig CollectConfig, manifests []byte, namespace string) *CollectResult {
+	p := newLocalClusterParser(collectConfig)
+	if p == nil {
+		return nil // error printed in newLocalClusterParser
+	}
+	if collectConfig.OfflineDir != "" {
+		if err := p.parseLocation(collectConfig.OfflineDir); err != nil {
+			return nil // error printed in parseLocation
 		}
 	}
-	if len(inputFiles) != 7 {
-		log.Errorf("parseLocalCluster: expected 7 input files, got %d\n", len(inputFiles))
-		return nil, nil
+
+	p.synthesizePods, p.defaultNamespace = true, namespace
+	if err := p.parseManifests("rendered manifests", bufio.NewReader(bytes.NewReader(manifests))); err != nil {
+		return nil // error printed in parseManifests
 	}
-	clusterDb := clusterDbFromLocalFiles(inputFiles, config)
-	return clusterDb, &metadata
-}
+	p.synthesizeWorkloadPods()
+	dedupeClusterDb(&p.clusterDb) // objects in @manifests replace existing ones
+	p.warnIgnoredAndMissing(collectConfig.OfflineDir != "")
 
-// Creates a ClusterDb object from @inputFiles
-func clusterDbFromLocalFiles(inputFiles []string, config CollectConfig) *ClusterDb {
-	var clusterDb ClusterDb
+	if collectConfig.IgnoreControlPlane {
+		removePodsFromExcludedNodes(&p.clusterDb) // remove control plane pods if needed
+	}
+	return collectResultFromClusterDb(&p.clusterDb, &p.metadata, collectConfig, nil)
+}
 
-	// Prepare a scheme containing all the objects we need to decode
+// Returns a parser for local inputs
+func newLocalClusterParser(config CollectConfig) *localClusterParser {
 	scheme := returnScheme()
 	if scheme == nil {
 		return nil // err printed in returnScheme
 	}
-	decodeFunc := serializer.NewCodecFactory(scheme).UniversalDeserializer().Decode
+	return &localClusterParser{
+		config:       config,
+		decodeFunc:   serializer.NewCodecFactory(scheme).UniversalDeserializer().Decode,
+		metadata:     ClusterMetadata{Features: []string{}},
+		ignoredKinds: make(map[string]int),
+	}
+}
+
+// Parses the local inputs at @location, a directory, a file or '-' for stdin
+func (p *localClusterParser) parseLocation(location string) error {
+	if location == stdinInput {
+		return p.parseInput("stdin", os.Stdin)
+	}
+	info, err := os.Stat(location)
+	if err != nil {
+		log.Errorf("parseLocation: failed to read local input %q with %v\n", location, err)
+		return err
+	}
+	if info.IsDir() {
+		return p.parseDir(location)
+	}
+	return p.parseFile(location)
+}
 
-	// Go over files
-	for _, filePath := range inputFiles {
-		// Read file
-		inputBytes, err := utils.ReadFile(filePath)
+// Parses the local inputs under @dir recursively, skipping hidden dirs like .git
+func (p *localClusterParser) parseDir(dir string) error {
+	return filepath.WalkDir(dir, func(filePath string, entry fs.DirEntry, err error) error {
 		if err != nil {
+			log.Errorf("parseDir: failed to read %q with %v\n", filePath, err)
+			return err
+		}
+		if entry.IsDir() {
+			if filePath != dir && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isLocalInput(entry.Name()) {
 			return nil
 		}
-		// Decode the file's contents into a *v1.List
-		decodedBytes, _, err := decodeFunc(inputBytes, nil, nil)
+		return p.parseFile(filePath)
+	})
+}
+
+// Parses the local input at @filePath
+func (p *localClusterParser) parseFile(filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		log.Errorf("parseFile: failed to open %q with %v\n", filePath, err)
+		return err
+	}
+	defer file.Close()
+	return p.parseInput(filePath, file)
+}
+
+// Parses the local input @name from @reader, which may be a cluster_name, version.json or features.json file,
+// a manifests file, or a tarball that's optionally gzipped
+func (p *localClusterParser) parseInput(name string, reader io.Reader) error {
+	switch path.Base(filepath.ToSlash(name)) {
+	case clusterNameFile:
+		return p.parseClusterName(name, reader)
+	case versionFile:
+		return p.parseVersion(name, reader)
+	case featuresFile:
+		return p.parseFeatures(name, reader)
+	case secretsFile:
+		p.secretsListed() // even if the snapshot's cluster has no secrets
+	}
+
+	buffered := bufio.NewReader(reader)
+	if isGzip(buffered) {
+		gzipReader, err := gzip.NewReader(buffered)
 		if err != nil {
-			log.Errorf("clusterDbFromLocalFiles: error while decoding %s: %v\n", filePath, err)
+			log.Errorf("parseInput: failed to decompress %q with %v\n", name, err)
+			return err
+		}
+		defer gzipReader.Close()
+		buffered = bufio.NewReader(gzipReader)
+	}
+	if isTar(buffered) {
+		return p.parseTar(name, buffered)
+	}
+	return p.parseManifests(name, buffered)
+}
+
+// Parses the local inputs in the tarball @name
+func (p *localClusterParser) parseTar(name string, reader io.Reader) error {
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
 			return nil
 		}
-		switch list := decodedBytes.(type) {
-		case *v1.List:
-			// Iterate list items and try to decode each into an expected input type
-			for i, item := range list.Items {
-				decodedObj, _, err := decodeFunc(item.Raw, nil, nil)
-				if err != nil {
-					log.Errorf("clusterDbFromLocalFiles: error while decoding %s items[%d].Raw: %v\n", filePath, i, err)
-					return nil
-				}
-				switch item := decodedObj.(type) {
-				case *v1.Pod:
-					if config.Namespace != "" && item.ObjectMeta.Namespace != config.Namespace {
-						continue // don't add pod if it's not in the ns the collection is scoped to
-					}
-					clusterDb.Pods = append(clusterDb.Pods, *item)
-				case *v1.Node:
-					if config.IgnoreControlPlane {
-						for label := range item.ObjectMeta.Labels {
-							if label == "node-role.kubernetes.io/master" || label == "node-role.kubernetes.io/control-plane" {
-								continue // skip control plane nodes if asked to
-							}
-						}
-					}
-					clusterDb.Nodes = append(clusterDb.Nodes, *item)
-				case *v1.ServiceAccount:
-					if config.Namespace != "" && item.ObjectMeta.Namespace != config.Namespace {
-						continue // don't add SA if it's not in the ns the collection is scoped to
-					}
-					clusterDb.ServiceAccounts = append(clusterDb.ServiceAccounts, *item)
-				case *rbac.ClusterRole:
-					clusterDb.ClusterRoles = append(clusterDb.ClusterRoles, *item)
-				case *rbac.Role:
-					clusterDb.Roles = append(clusterDb.Roles, *item)
-				case *rbac.ClusterRoleBinding:
-					clusterDb.ClusterRoleBindings = append(clusterDb.ClusterRoleBindings, *item)
-				case *rbac.RoleBinding:
-					clusterDb.RoleBindings = append(clusterDb.RoleBindings, *item)
-				default:
-					log.Errorf("clusterDbFromLocalFiles: unexpected type while decoding %s items[%d], got %s\n", filePath, i, reflect.TypeOf(decodedObj))
-					return nil
+		if err != nil {
+			log.Errorf("parseTar: failed to read %q with %v\n", name, err)
+			return err
+		}
+		if header.Typeflag != tar.TypeReg || !isLocalInput(path.Base(header.Name)) {
+			continue
+		}
+		if err := p.parseInput(name+":"+header.Name, tarReader); err != nil {
+			return err
+		}
+	}
+}
+
+// Parses the YAML or JSON documents in @name into the cluster db
+func (p *localClusterParser) parseManifests(name string, reader *bufio.Reader) error {
+	yamlReader := utilyaml.NewYAMLReader(reader)
+	for i := 0; ; i++ {
+		document, err := yamlReader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			log.Warnf("parseManifests: skipping the rest of %q, failed to read document %d with %v\n", name, i, err)
+			return nil
+		}
+		if isEmptyDocument(document) {
+			continue
+		}
+		p.addDocument(name, document)
+	}
+}
+
+// Decodes @document and adds the objects it holds to the cluster db
+func (p *localClusterParser) addDocument(name string, document []byte) {
+	var typeMeta metav1.TypeMeta
+	if err := yaml.Unmarshal(document, &typeMeta); err != nil {
+		log.Warnf("addDocument: skipping a document in %q that isn't valid YAML or JSON: %v\n", name, err)
+		return
+	}
+	if typeMeta.Kind == "" {
+		log.Debugf("addDocument: skipping a document in %q that isn't a Kubernetes object\n", name)
+		return
+	}
+	obj, _, err := p.decodeFunc(document, nil, nil)
+	if err != nil {
+		if runtime.IsNotRegisteredError(err) {
+			p.ignoredKinds[typeMeta.Kind] += 1
+		} else {
+			log.Warnf("addDocument: skipping a %s in %q, failed to decode it with %v\n", typeMeta.Kind, name, err)
+		}
+		return
+	}
+	p.addObject(name, obj, typeMeta.Kind)
+}
+
+// Adds @obj of @kind to the cluster db, or the items of @obj if it's a list
+func (p *localClusterParser) addObject(name string, obj runtime.Object, kind string) {
+	config := p.config
+	if p.defaultNamespace != "" {
+		if accessor, err := meta.Accessor(obj); err == nil && accessor.GetNamespace() == "" && !clusterScopedKinds[kind] {
+			accessor.SetNamespace(p.defaultNamespace)
+		}
+	}
+	if template := podTemplate(obj); template != nil {
+		if !p.synthesizePods {
+			p.ignoredKinds[kind] += 1
+			return
+		}
+		accessor, _ := meta.Accessor(obj)
+		if config.Namespace != "" && accessor.GetNamespace() != config.Namespace {
+			return // don't add workload if it's not in the ns the collection is scoped to
+		}
+		p.workloads = append(p.workloads, workload{
+			Kind:     kind,
+			Metadata: metav1.ObjectMeta{Name: accessor.GetName(), Namespace: accessor.GetNamespace()},
+			Template: *template,
+		})
+		p.objects += 1
+		return
+	}
+	switch item := obj.(type) {
+	case *v1.List:
+		for _, listItem := range item.Items {
+			if listItem.Object != nil {
+				p.addObject(name, listItem.Object, reflect.TypeOf(listItem.Object).Elem().Name())
+			} else {
+				p.addDocument(name, listItem.Raw)
+			}
+		}
+		return
+	case *v1.Pod:
+		if config.Namespace != "" && item.ObjectMeta.Namespace != config.Namespace {
+			return // don't add pod if it's not in the ns the collection is scoped to
+		}
+		p.clusterDb.Pods = append(p.clusterDb.Pods, *item)
+	case *v1.Node:
+		if config.IgnoreControlPlane && isControlPlaneNode(*item) {
+			return // skip control plane nodes if asked to
+		}
+		p.clusterDb.Nodes = append(p.clusterDb.Nodes, *item)
+	case *v1.ServiceAccount:
+		if config.Namespace != "" && item.ObjectMeta.Namespace != config.Namespace {
+			return // don't add SA if it's not in the ns the collection is scoped to
+		}
+		p.clusterDb.ServiceAccounts = append(p.clusterDb.ServiceAccounts, *item)
+	case *v1.Namespace:
+		if config.Namespace != "" && item.ObjectMeta.Name != config.Namespace {
+			return // don't add namespace if it's not the one the collection is scoped to
+		}
+		p.clusterDb.Namespaces = append(p.clusterDb.Namespaces, *item)
+	case *v1.Secret:
+		p.secretsListed()
+		if config.Namespace != "" && item.ObjectMeta.Namespace != config.Namespace {
+			return // don't add secret if it's not in the ns the collection is scoped to
+		}
+		p.clusterDb.Secrets = append(p.clusterDb.Secrets, secretMetadata(*item))
+	case *rbac.ClusterRole:
+		p.clusterDb.ClusterRoles = append(p.clusterDb.ClusterRoles, *item)
+	case *rbac.Role:
+		p.clusterDb.Roles = append(p.clusterDb.Roles, *item)
+	case *rbac.ClusterRoleBinding:
+		p.clusterDb.ClusterRoleBindings = append(p.clusterDb.ClusterRoleBindings, *item)
+	case *rbac.RoleBinding:
+		p.clusterDb.RoleBindings = append(p.clusterDb.RoleBindings, *item)
+	default:
+		// Typed lists, e.g. a PodList
+		if meta.IsListType(obj) {
+			items, err := meta.ExtractList(obj)
+			if err != nil {
+				log.Warnf("addObject: skipping a %s in %q, failed to extract its items with %v\n", kind, name, err)
+				return
+			}
+			for _, listItem := range items {
+				if unknown, ok := listItem.(*runtime.Unknown); ok {
+					p.addDocument(name, unknown.Raw)
+				} else {
+					p.addObject(name, listItem, reflect.TypeOf(listItem).Elem().Name())
 				}
 			}
-		default:
-			log.Errorf("clusterDbFromLocalFiles: unexpected type decoding %s, expected *v1.List, got %s\n", filePath, reflect.TypeOf(decodedBytes))
-			return nil
+			return
 		}
+		p.ignoredKinds[kind] += 1
+		return
 	}
-	if config.IgnoreControlPlane {
-		removePodsFromExcludedNodes(&clusterDb) // remove control plane pods if needed
+	p.objects += 1
+}
+
+// Reads the cluster's name from @reader into the metadata
+func (p *localClusterParser) parseClusterName(name string, reader io.Reader) error {
+	nameBytes, err := io.ReadAll(reader)
+	if err != nil {
+		log.Warnf("parseClusterName: failed to read cluster name from %q with %v\n", name, err)
+		return nil
+	}
+	p.metadata.ClusterName = strings.TrimSuffix(string(nameBytes), "\n")
+	return nil
+}
+
+// Reads the cluster's version from @reader into the metadata
+func (p *localClusterParser) parseVersion(name string, reader io.Reader) error {
+	var versionInfo version.Info
+	versionBytes, err := io.ReadAll(reader)
+	if err != nil {
+		log.Warnf("parseVersion: failed to read %q with %v\n", name, err)
+		return nil
+	}
+	if err = json.Unmarshal(versionBytes, &versionInfo); err != nil {
+		log.Warnf("parseVersion: failed to unmarshal %q into a version.Info obj with %v\n", name, err)
+		return nil
+	}
+	p.metadata.Version = ClusterVersion{
+		Major:      versionInfo.Major,
+		Minor:      versionInfo.Minor,
+		GitVersion: versionInfo.GitVersion,
+	}
+	p.metadata.Platform = platformFromVersion(versionInfo.GitVersion)
+	return nil
+}
+
+// Reads the cluster's features from @reader, a JSON list, into the metadata
+func (p *localClusterParser) parseFeatures(name string, reader io.Reader) error {
+	var features []string
+	featuresBytes, err := io.ReadAll(reader)
+	if err != nil {
+		log.Warnf("parseFeatures: failed to read %q with %v\n", name, err)
+		return nil
+	}
+	if err = json.Unmarshal(featuresBytes, &features); err != nil {
+		log.Warnf("parseFeatures: failed to unmarshal %q into a list of features with %v\n", name, err)
+		return nil
+	}
+	for _, feature := range features {
+		addFeature(&p.metadata, feature, FeatureDiscovered)
+	}
+	return nil
+}
+
+// Marks secrets as listed, so an empty list of secrets isn't mistaken for secrets that couldn't be listed
+func (p *localClusterParser) secretsListed() {
+	if p.clusterDb.Secrets == nil {
+		p.clusterDb.Secrets = []v1.Secret{}
 	}
-	return &clusterDb
+}
+
+// Warns about the kinds that were ignored, and if @warnMissing is set, about the expected kinds that weren't found
+func (p *localClusterParser) warnIgnoredAndMissing(warnMissing bool) {
+	var ignoredKinds []string
+	for kind := range p.ignoredKinds {
+		ignoredKinds = append(ignoredKinds, kind)
+	}
+	sort.Strings(ignoredKinds)
+	for _, kind := range ignoredKinds {
+		log.Warnf("parseLocalCluster: ignored %d objects of unrelated kind %s\n", p.ignoredKinds[kind], kind)
+	}
+
+	db := &p.clusterDb
+	if db.Pods == nil {
+		db.Pods = []v1.Pod{}
+		warnMissingKind("pods", warnMissing)
+	}
+	if db.Nodes == nil {
+		db.Nodes = []v1.Node{}
+		warnMissingKind("nodes", warnMissing)
+	}
+	if db.ServiceAccounts == nil {
+		db.ServiceAccounts = []v1.ServiceAccount{}
+		warnMissingKind("serviceAccounts", warnMissing)
+	}
+	if db.Namespaces == nil {
+		db.Namespaces = []v1.Namespace{}
+		warnMissingKind("namespaces", warnMissing)
+	}
+	if db.Roles == nil {
+		db.Roles = []rbac.Role{}
+		warnMissingKind("roles", warnMissing)
+	}
+	if db.ClusterRoles == nil {
+		db.ClusterRoles = []rbac.ClusterRole{}
+		warnMissingKind("clusterRoles", warnMissing)
+	}
+	if db.RoleBindings == nil {
+		db.RoleBindings = []rbac.RoleBinding{}
+		warnMissingKind("roleBindings", warnMissing)
+	}
+	if db.ClusterRoleBindings == nil {
+		db.ClusterRoleBindings = []rbac.ClusterRoleBinding{}
+		warnMissingKind("clusterRoleBindings", warnMissing)
+	}
+}
+
+// Warns that no objects of @kind were found in the local inputs
+func warnMissingKind(kind string, warnMissing bool) {
+	if !warnMissing {
+		return
+	}
+	log.Warnf("parseLocalCluster: no %s found in local inputs, assuming there are none\n", kind)
+}
+
+// Returns whether the file @name may hold cluster data
+func isLocalInput(name string) bool {
+	if name == clusterNameFile {
+		return true
+	}
+	for _, suffix := range []string{".yaml", ".yml", ".json", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns whether @reader holds gzipped data
+func isGzip(reader *bufio.Reader) bool {
+	magic, err := reader.Peek(2)
+	return err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b})
+}
+
+// Returns whether @reader holds a tarball
+func isTar(reader *bufio.Reader) bool {
+	header, err := reader.Peek(262)
+	return err == nil && bytes.Equal(header[257:262], []byte("ustar"))
+}
+
+// Returns whether @document holds only whitespace and comments
+func isEmptyDocument(document []byte) bool {
+	for _, line := range strings.Split(string(document), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && line != "---" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns whether @node is a control plane node
+func isControlPlaneNode(node v1.Node) bool {
+	for label := range node.ObjectMeta.Labels {
+		if label == "node-role.kubernetes.io/master" || label == "node-role.kubernetes.io/control-plane" {
+			return true
+		}
+	}
+	return false
 }
 
 // Returns a scheme describing the objects we want to decode
@@ -161,5 +521,18 @@ func returnScheme() *runtime.Scheme {
 		log.Errorf("returnScheme: failed to add the rbac v1 scheme with %v\n", err)
 		return nil
 	}
+	// Workloads, to synthesize pods from their pod templates
+	if err := appsv1.AddToScheme(schemes); err != nil {
+		log.Errorf("returnScheme: failed to add the apps v1 scheme with %v\n", err)
+		return nil
+	}
+	if err := batchv1.AddToScheme(schemes); err != nil {
+		log.Errorf("returnScheme: failed to add the batch v1 scheme with %v\n", err)
+		return nil
+	}
+	if err := batchv1beta1.AddToScheme(schemes); err != nil {
+		log.Errorf("returnScheme: failed to add the batch v1beta1 scheme with %v\n", err)
+		return nil
+	}
 	return schemes
 }
@@ -2,6 +2,7 @@ package collect
 
 import (
 	"strings"
+	"time"
 
 	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
 	v1 "k8s.io/api/core/v1"
@@ -43,8 +44,9 @@ func buildRbacDb(cDb ClusterDb, collectConfig CollectConfig) *RbacDb {
 				}
 			}
 		}
-		// Add SA if it's assigned to a pod or if we're configured to always collect
-		if saEntry.Nodes != nil || collectConfig.AllServiceAccounts {
+		// Add SA if it's assigned to a pod, if it has a long-lived token or if we're configured to always collect
+		saEntry.LegacyToken = legacyTokenOf(sa, cDb.Secrets, time.Now())
+		if saEntry.Nodes != nil || saEntry.LegacyToken != nil || collectConfig.AllServiceAccounts {
 			saEntry.ProviderIAM = getProviderIAM(sa)
 			rbacDb.ServiceAccounts = append(rbacDb.ServiceAccounts, saEntry)
 		}
@@ -52,6 +54,9 @@ func buildRbacDb(cDb ClusterDb, collectConfig CollectConfig) *RbacDb {
 
 	populateRoleBindingsPermissions(&rbacDb, cDb, collectConfig)
 	populateClusterRoleBindingsPermissions(&rbacDb, cDb, collectConfig)
+	if collectConfig.NodeUser == "" {
+		populateNodeAuthorizerAccess(&rbacDb, cDb) // nodes with a custom user aren't authorized by the NodeAuthorizer
+	}
 
 	return &rbacDb
 }
@@ -78,15 +83,19 @@ func populateRoleBindingsPermissions(rbacDb *RbacDb, cDb ClusterDb, collectConfi
 
 		// Check if rb grants role to a serviceAccount
 		for i, sa := range rbacDb.ServiceAccounts {
-			if isSAReferencedBySubjects(rb.Subjects, utils.FullName(sa.Namespace, sa.Name), rb.Namespace) {
-				rbacDb.ServiceAccounts[i].Roles = append(rbacDb.ServiceAccounts[i].Roles, roleRef)
+			if referenced, group := isSAReferencedBySubjects(rb.Subjects, utils.FullName(sa.Namespace, sa.Name), rb.Namespace); referenced {
+				saRoleRef := roleRef
+				saRoleRef.Group = group
+				rbacDb.ServiceAccounts[i].Roles = append(rbacDb.ServiceAccounts[i].Roles, saRoleRef)
 				roleBindedToRelevantSubject = true
 			}
 		}
 		// Check if rb grants role to a node
 		for i, node := range rbacDb.Nodes {
-			if isNodeReferencedBySubjects(rb.Subjects, node.Name, collectConfig.NodeGroups, collectConfig.NodeUser) {
-				rbacDb.Nodes[i].Roles = append(rbacDb.Nodes[i].Roles, roleRef)
+			if referenced, group := isNodeReferencedBySubjects(rb.Subjects, node.Name, collectConfig.NodeGroups, collectConfig.NodeUser); referenced {
+				nodeRoleRef := roleRef
+				nodeRoleRef.Group = group
+				rbacDb.Nodes[i].Roles = append(rbacDb.Nodes[i].Roles, nodeRoleRef)
 				roleBindedToRelevantSubject = true
 			}
 		}
@@ -146,15 +155,19 @@ func populateClusterRoleBindingsPermissions(rbacDb *RbacDb, cDb ClusterDb, colle
 
 		// Check if the crb grants the cr to a serviceAccount
 		for i, sa := range rbacDb.ServiceAccounts {
-			if isSAReferencedBySubjects(crb.Subjects, utils.FullName(sa.Namespace, sa.Name), "") {
-				rbacDb.ServiceAccounts[i].Roles = append(rbacDb.ServiceAccounts[i].Roles, clusterRoleRef)
+			if referenced, group := isSAReferencedBySubjects(crb.Subjects, utils.FullName(sa.Namespace, sa.Name), ""); referenced {
+				saRoleRef := clusterRoleRef
+				saRoleRef.Group = group
+				rbacDb.ServiceAccounts[i].Roles = append(rbacDb.ServiceAccounts[i].Roles, saRoleRef)
 				roleBindedToRelevantSubject = true
 			}
 		}
 		// Check if the crb grants the cr to a node
 		for i, node := range rbacDb.Nodes {
-			if isNodeReferencedBySubjects(crb.Subjects, node.Name, collectConfig.NodeGroups, collectConfig.NodeUser) {
-				rbacDb.Nodes[i].Roles = append(rbacDb.Nodes[i].Roles, clusterRoleRef)
+			if referenced, group := isNodeReferencedBySubjects(crb.Subjects, node.Name, collectConfig.NodeGroups, collectConfig.NodeUser); referenced {
+				nodeRoleRef := clusterRoleRef
+				nodeRoleRef.Group = group
+				rbacDb.Nodes[i].Roles = append(rbacDb.Nodes[i].Roles, nodeRoleRef)
 				roleBindedToRelevantSubject = true
 			}
 		}
@@ -200,59 +213,61 @@ func populateClusterRoleBindingsPermissions(rbacDb *RbacDb, cDb ClusterDb, colle
 	}
 }
 
-// Checks whether the serviceAccount denoted by @fullname is refernced in @subjects
-func isSAReferencedBySubjects(subjects []rbac.Subject, saFullname string, rbNS string) bool {
+// Checks whether the serviceAccount denoted by @fullname is refernced in @subjects, and via which group.
+// The group is empty if the serviceAccount is referenced directly
+func isSAReferencedBySubjects(subjects []rbac.Subject, saFullname string, rbNS string) (bool, string) {
+	viaGroup := ""
 	for _, subject := range subjects {
 		if subject.Kind == "ServiceAccount" {
 			if subject.Namespace == "" {
 				subject.Namespace = rbNS
 			}
 			if saFullname == utils.FullName(subject.Namespace, subject.Name) {
-				return true
+				return true, ""
 			}
 		} else if subject.Kind == "Group" {
+			if viaGroup != "" {
+				continue
+			}
 			if subject.Name == "system:authenticated" {
-				return true
+				viaGroup = subject.Name
+				continue
 			}
 			if !strings.HasPrefix(subject.Name, "system:serviceaccounts") {
-				return false // only handle sa groups
+				return viaGroup != "", viaGroup // only handle sa groups
 			}
 			if subject.Name == "system:serviceaccounts" {
-				return true
-			}
-			if subject.Name == "system:serviceaccounts:"+strings.Split(saFullname, ":")[0] {
-				return true
+				viaGroup = subject.Name
+			} else if subject.Name == "system:serviceaccounts:"+strings.Split(saFullname, ":")[0] {
+				viaGroup = subject.Name
 			}
 		}
 	}
-	return false
+	return viaGroup != "", viaGroup
 }
 
-// Checks whether the node denoted by @nodeName is refernced in @subjects
-func isNodeReferencedBySubjects(subjects []rbac.Subject, nodeName string, nodeGroups []string, nodeUser string) bool {
+// Checks whether the node denoted by @nodeName is refernced in @subjects, and via which group.
+// The group is empty if the node is referenced directly
+func isNodeReferencedBySubjects(subjects []rbac.Subject, nodeName string, nodeGroups []string, nodeUser string) (bool, string) {
+	viaGroup := ""
 	for _, subject := range subjects {
 		if subject.Kind == "User" {
 			if nodeUser != "" {
 				if subject.Name == nodeUser {
-					return true
+					return true, ""
 				}
 			} else {
 				if subject.Name == "system:node:"+nodeName {
-					return true
+					return true, ""
 				}
 			}
-		} else if subject.Kind == "Group" {
-			if subject.Name == "system:authenticated" {
-				return true
-			}
-			for _, grp := range nodeGroups {
-				if subject.Name == grp {
-					return true
-				}
+		} else if subject.Kind == "Group" && viaGroup == "" {
+			if subject.Name == "system:authenticated" || utils.Contains(nodeGroups, subject.Name) {
+				viaGroup = subject.Name
 			}
 		}
 	}
-	return false
+	return viaGroup != "", viaGroup
 }
 
 // Adds @role entry to @rbacDb if it's not already there
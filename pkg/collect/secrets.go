@@ -0,0 +1,204 @@
+package collect
+
+import (
+	"strings"
+	"time"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Labels the legacy token tracking and cleanup controllers set on serviceAccount token secrets
+const (
+	legacyTokenLastUsedLabel     = "kubernetes.io/legacy-token-last-used"
+	legacyTokenInvalidSinceLabel = "kubernetes.io/legacy-token-invalid-since"
+)
+
+// Namespaces collect treats as privileged when highlighting tokens, the default of eval's --privileged-namespaces
+var defaultPrivilegedNamespaces = []string{"kube-system"}
+
+// Annotations of secrets that may hold their data
+var secretDataAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+}
+
+// Name parts that suggest a secret holds cloud provider credentials
+var cloudCredentialsNameParts = map[string]bool{
+	"aws": true, "eks": true, "s3": true, "route53": true, "iam": true,
+	"gcp": true, "gke": true, "gcloud": true, "google": true, "gcs": true,
+	"azure": true, "aks": true, "cloud": true,
+}
+
+// Name parts that suggest a secret holds a certificate authority
+var tlsCANameParts = map[string]bool{
+	"ca": true, "cacert": true, "cacerts": true,
+}
+
+// Returns @secret stripped down to its metadata and type. Drops its data, and
+// the annotations and managed fields that may hold its data
+func secretMetadata(secret v1.Secret) v1.Secret {
+	objMeta := *secret.ObjectMeta.DeepCopy()
+	objMeta.ManagedFields = nil
+	for _, annotation := range secretDataAnnotations {
+		delete(objMeta.Annotations, annotation)
+	}
+	return v1.Secret{
+		TypeMeta:   secret.TypeMeta,
+		ObjectMeta: objMeta,
+		Type:       secret.Type,
+	}
+}
+
+// Builds the secret entries policies consume from @secrets, highlighting sensitive ones.
+// Tokens are highlighted per the default privileged namespaces, eval re-highlights them per its own.
+// Returns nil if @secrets is nil, i.e. secrets couldn't be listed
+func buildSecretEntries(secrets []v1.Secret, rbacDb *RbacDb) []SecretEntry {
+	if secrets == nil {
+		return nil
+	}
+	secretEntries := []SecretEntry{}
+	for _, secret := range secrets {
+		secretEntry := SecretEntry{
+			Name:      secret.Name,
+			Namespace: secret.Namespace,
+			Type:      string(secret.Type),
+			Owner:     secretOwner(secret.OwnerReferences),
+		}
+		if secret.Type == v1.SecretTypeServiceAccountToken {
+			secretEntry.ServiceAccount = secret.Annotations[v1.ServiceAccountNameKey]
+		}
+		if _, invalid := secret.Labels[legacyTokenInvalidSinceLabel]; !invalid {
+			secretEntry.Highlight = secretHighlight(secretEntry) // invalidated tokens can't authenticate
+		}
+		secretEntries = append(secretEntries, secretEntry)
+	}
+	return HighlightTokens(secretEntries, rbacDb.ServiceAccounts, rbacDb.Roles, defaultPrivilegedNamespaces)
+}
+
+// HighlightTokens returns a copy of @secrets where the valid long-lived tokens of @serviceAccounts are highlighted
+// as privilegedServiceAccountToken if their serviceAccount is privileged per @privilegedNamespaces and @roles, or
+// not highlighted otherwise. Tokens of serviceAccounts missing from @serviceAccounts are left as is.
+func HighlightTokens(secrets []SecretEntry, serviceAccounts []ServiceAccountEntry, roles []RoleEntry, privilegedNamespaces []string) []SecretEntry {
+	if secrets == nil {
+		return nil
+	}
+	highlighted := append([]SecretEntry{}, secrets...)
+	for i, secret := range highlighted {
+		if secret.Type != string(v1.SecretTypeServiceAccountToken) {
+			continue
+		}
+		for _, sa := range serviceAccounts {
+			if !sa.Equals(secret.ServiceAccount, secret.Namespace) || sa.LegacyToken == nil || !utils.Contains(sa.LegacyToken.Secrets, secret.Name) {
+				continue
+			}
+			highlighted[i].Highlight = ""
+			if isPrivilegedServiceAccount(sa, roles, privilegedNamespaces) {
+				highlighted[i].Highlight = "privilegedServiceAccountToken"
+			}
+			break
+		}
+	}
+	return highlighted
+}
+
+// Returns the kind/name of the controller of a secret per its @ownerRefs, or of its first owner
+func secretOwner(ownerRefs []metav1.OwnerReference) string {
+	if len(ownerRefs) == 0 {
+		return ""
+	}
+	owner := ownerRefs[0]
+	for _, ownerRef := range ownerRefs {
+		if ownerRef.Controller != nil && *ownerRef.Controller {
+			owner = ownerRef
+			break
+		}
+	}
+	return owner.Kind + "/" + owner.Name
+}
+
+// Returns why @secret is sensitive, if it is:
+//   - tlsCA: a certificate authority, judging by its name
+//   - cloudCredentials: cloud provider credentials, judging by its name
+//
+// Tokens of privileged serviceAccounts are highlighted by HighlightTokens
+func secretHighlight(secret SecretEntry) string {
+	if secret.Type == string(v1.SecretTypeServiceAccountToken) {
+		return ""
+	}
+	nameParts := strings.FieldsFunc(strings.ToLower(secret.Name), func(r rune) bool {
+		return r == '-' || r == '_' || r == '.'
+	})
+	if secret.Type == string(v1.SecretTypeTLS) || secret.Type == string(v1.SecretTypeOpaque) {
+		for _, part := range nameParts {
+			if tlsCANameParts[part] {
+				return "tlsCA"
+			}
+		}
+	}
+	if secret.Type == string(v1.SecretTypeOpaque) || secret.Type == "" {
+		for _, part := range nameParts {
+			if cloudCredentialsNameParts[part] {
+				return "cloudCredentials"
+			}
+		}
+	}
+	return ""
+}
+
+// Returns whether @sa is privileged, that is it's in one of @privilegedNamespaces,
+// or granted all verbs on all resources cluster-wide by one of @roles
+func isPrivilegedServiceAccount(sa ServiceAccountEntry, roles []RoleEntry, privilegedNamespaces []string) bool {
+	if utils.Contains(privilegedNamespaces, sa.Namespace) {
+		return true
+	}
+	for _, roleRef := range sa.Roles {
+		if roleRef.EffectiveNamespace != "" {
+			continue
+		}
+		for _, role := range roles {
+			if role.Name != roleRef.Name || role.Namespace != roleRef.Namespace {
+				continue
+			}
+			for _, rule := range role.Rules {
+				if utils.Contains(rule.Verbs, "*") && utils.Contains(rule.Resources, "*") && utils.Contains(rule.APIGroups, "*") {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Returns the long-lived tokens of @serviceAccount out of @secrets as of @now, or nil if it has none.
+// Token secrets invalidated by the legacy token cleaner are skipped, as they can't authenticate
+func legacyTokenOf(serviceAccount v1.ServiceAccount, secrets []v1.Secret, now time.Time) *LegacyToken {
+	var legacyToken *LegacyToken
+	var oldest time.Time
+	for _, secret := range secrets {
+		if secret.Type != v1.SecretTypeServiceAccountToken || secret.Namespace != serviceAccount.Namespace {
+			continue
+		}
+		if secret.Annotations[v1.ServiceAccountNameKey] != serviceAccount.Name {
+			continue
+		}
+		if _, invalid := secret.Labels[legacyTokenInvalidSinceLabel]; invalid {
+			continue
+		}
+		if legacyToken == nil {
+			legacyToken = &LegacyToken{}
+		}
+		legacyToken.Secrets = append(legacyToken.Secrets, secret.Name)
+		if lastUsed := secret.Labels[legacyTokenLastUsedLabel]; lastUsed > legacyToken.LastUsed {
+			legacyToken.LastUsed = lastUsed // dates in the YYYY-MM-DD format
+		}
+		if created := secret.CreationTimestamp.Time; !created.IsZero() && (oldest.IsZero() || created.Before(oldest)) {
+			oldest = created
+		}
+	}
+	if legacyToken != nil && !oldest.IsZero() {
+		legacyToken.Created = oldest.UTC().Format(time.RFC3339)
+		legacyToken.AgeDays = int(now.Sub(oldest).Hours() / 24)
+	}
+	return legacyToken
+}
@@ -0,0 +1,332 @@
+package collect
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Files of a snapshot that hold the cluster's metadata, read by parseInput
+const (
+	clusterNameFile = "cluster_name"
+	versionFile     = "version.json"
+	featuresFile    = "features.json"
+	secretsFile     = "secrets.json"
+)
+
+// Snapshot writes the cluster data Collect() relies on to snapshotConfig.Output, a directory or a .tar.gz archive,
+// in the format parseLocalCluster reads. Includes the cluster's name, version and discovered features,
+// so later offline runs reuse them.
+// Reads the cluster from collectConfig.OfflineDir if set, e.g. to redact or pack an existing snapshot.
+func Snapshot(collectConfig CollectConfig, snapshotConfig SnapshotConfig) error {
+	var metadata *ClusterMetadata
+	var clusterDb *ClusterDb
+	var kubeConfig clientcmd.ClientConfig = nil
+
+	if collectConfig.OfflineDir == "" {
+		clientset, kConfigTmp, err := initKubeClient()
+		kubeConfig = kConfigTmp
+		if err != nil {
+			return err // error printed in initKubeClient
+		}
+		metadata = buildMetadata(clientset, kubeConfig)
+		clusterDb = buildClusterDb(clientset, collectConfig.Namespace, collectConfig.IgnoreControlPlane)
+	} else {
+		clusterDb, metadata = parseLocalCluster(collectConfig)
+	}
+	if clusterDb == nil {
+		return fmt.Errorf("failed to retrieve cluster data") // error printed in buildClusterDb or in parseLocalCluster
+	}
+	if collectConfig.DiscoverProtections {
+		discoverRelevantControlPlaneFeatures(collectConfig, kubeConfig, clusterDb, metadata)
+	}
+	if snapshotConfig.Redact {
+		for i, pod := range clusterDb.Pods {
+			clusterDb.Pods[i] = redactedPod(pod)
+		}
+	}
+
+	writer, err := newSnapshotWriter(snapshotConfig.Output)
+	if err != nil {
+		return err // error printed in newSnapshotWriter
+	}
+	if err = writeSnapshot(writer, clusterDb, metadata); err != nil {
+		writer.Close()
+		return err // error printed in writeSnapshot
+	}
+	return writer.Close()
+}
+
+// A snapshot file holding a list of objects
+type snapshotList struct {
+	fileName string
+	objects  interface{}
+}
+
+// Writes @clusterDb and @metadata as snapshot files to @writer
+func writeSnapshot(writer *snapshotWriter, clusterDb *ClusterDb, metadata *ClusterMetadata) error {
+	scheme := returnScheme()
+	if scheme == nil {
+		return fmt.Errorf("failed to create scheme") // error printed in returnScheme
+	}
+	lists := []snapshotList{
+		{"pods.json", clusterDb.Pods},
+		{"nodes.json", clusterDb.Nodes},
+		{"serviceaccounts.json", clusterDb.ServiceAccounts},
+		{"namespaces.json", clusterDb.Namespaces},
+		{"roles.json", clusterDb.Roles},
+		{"rolebindings.json", clusterDb.RoleBindings},
+		{"clusterroles.json", clusterDb.ClusterRoles},
+		{"clusterrolebindings.json", clusterDb.ClusterRoleBindings},
+	}
+	if clusterDb.Secrets != nil {
+		lists = append(lists, snapshotList{secretsFile, clusterDb.Secrets}) // metadata only, see secretMetadata
+	}
+	for _, list := range lists {
+		listBytes, err := marshalList(scheme, list.objects)
+		if err != nil {
+			log.Errorf("writeSnapshot: failed to marshal %s with %v\n", list.fileName, err)
+			return err
+		}
+		if err = writer.WriteFile(list.fileName, listBytes); err != nil {
+			return err // error printed in WriteFile
+		}
+	}
+
+	if metadata.ClusterName != "" {
+		if err := writer.WriteFile(clusterNameFile, []byte(metadata.ClusterName)); err != nil {
+			return err // error printed in WriteFile
+		}
+	}
+	if metadata.Version.GitVersion != "" {
+		versionInfo := version.Info{Major: metadata.Version.Major, Minor: metadata.Version.Minor, GitVersion: metadata.Version.GitVersion}
+		versionBytes, err := json.MarshalIndent(versionInfo, "", "    ")
+		if err != nil {
+			log.Errorf("writeSnapshot: failed to marshal the cluster's version with %v\n", err)
+			return err
+		}
+		if err = writer.WriteFile(versionFile, versionBytes); err != nil {
+			return err // error printed in WriteFile
+		}
+	}
+	featuresBytes, err := json.MarshalIndent(discoveredFeatures(metadata), "", "    ") // assumed and inferred features are applied per run
+	if err != nil {
+		log.Errorf("writeSnapshot: failed to marshal the cluster's features with %v\n", err)
+		return err
+	}
+	return writer.WriteFile(featuresFile, featuresBytes)
+}
+
+// Marshals the slice of objects @objects into a List, as in 'kubectl get -o json'.
+// Sets the kind and apiVersion of each object per @scheme, so they can be decoded individually.
+func marshalList(scheme *runtime.Scheme, objects interface{}) ([]byte, error) {
+	list := v1.List{
+		TypeMeta: metav1.TypeMeta{Kind: "List", APIVersion: "v1"},
+		Items:    []runtime.RawExtension{},
+	}
+	objectsValue := reflect.ValueOf(objects)
+	for i := 0; i < objectsValue.Len(); i++ {
+		obj := objectsValue.Index(i).Addr().Interface().(runtime.Object)
+		gvks, _, err := scheme.ObjectKinds(obj)
+		if err != nil {
+			return nil, err
+		}
+		obj.GetObjectKind().SetGroupVersionKind(gvks[0])
+		objBytes, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, runtime.RawExtension{Raw: objBytes})
+	}
+	return json.MarshalIndent(list, "", "    ")
+}
+
+// Returns @pod stripped down to the fields rbac-police needs, its references to the
+// secrets, configMaps and persistentVolumeClaims the NodeAuthorizer permits its node to access included
+func redactedPod(pod v1.Pod) v1.Pod {
+	redacted := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod.ObjectMeta.Name,
+			Namespace: pod.ObjectMeta.Namespace,
+		},
+		Spec: v1.PodSpec{
+			ServiceAccountName: pod.Spec.ServiceAccountName,
+			NodeName:           pod.Spec.NodeName,
+			ImagePullSecrets:   pod.Spec.ImagePullSecrets,
+			InitContainers:     redactedContainers(pod.Spec.InitContainers),
+			Containers:         redactedContainers(pod.Spec.Containers),
+		},
+	}
+	for _, ephemeralContainer := range pod.Spec.EphemeralContainers {
+		container := redactedContainers([]v1.Container{v1.Container(ephemeralContainer.EphemeralContainerCommon)})[0]
+		redacted.Spec.EphemeralContainers = append(redacted.Spec.EphemeralContainers, v1.EphemeralContainer{EphemeralContainerCommon: v1.EphemeralContainerCommon(container)})
+	}
+	for _, volume := range pod.Spec.Volumes {
+		if redactedVolume, ok := redactedVolume(volume); ok {
+			redacted.Spec.Volumes = append(redacted.Spec.Volumes, redactedVolume)
+		}
+	}
+	return redacted
+}
+
+// Returns @containers stripped down to their names and the secrets and configMaps their environment refers to
+func redactedContainers(containers []v1.Container) []v1.Container {
+	var redacted []v1.Container
+	for _, container := range containers {
+		redactedContainer := v1.Container{Name: container.Name, EnvFrom: container.EnvFrom}
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && (env.ValueFrom.SecretKeyRef != nil || env.ValueFrom.ConfigMapKeyRef != nil) {
+				redactedContainer.Env = append(redactedContainer.Env, v1.EnvVar{
+					Name:      env.Name,
+					ValueFrom: &v1.EnvVarSource{SecretKeyRef: env.ValueFrom.SecretKeyRef, ConfigMapKeyRef: env.ValueFrom.ConfigMapKeyRef},
+				})
+			}
+		}
+		redacted = append(redacted, redactedContainer)
+	}
+	return redacted
+}
+
+// Returns @volume stripped down to the secrets, configMaps, persistentVolumeClaims and serviceAccount tokens it
+// refers to, and false if it refers to none of them
+func redactedVolume(volume v1.Volume) (v1.Volume, bool) {
+	redacted := v1.Volume{Name: volume.Name}
+	switch {
+	case volume.Secret != nil:
+		redacted.Secret = &v1.SecretVolumeSource{SecretName: volume.Secret.SecretName}
+	case volume.ConfigMap != nil:
+		redacted.ConfigMap = &v1.ConfigMapVolumeSource{LocalObjectReference: volume.ConfigMap.LocalObjectReference}
+	case volume.PersistentVolumeClaim != nil:
+		redacted.PersistentVolumeClaim = &v1.PersistentVolumeClaimVolumeSource{ClaimName: volume.PersistentVolumeClaim.ClaimName}
+	case volume.Ephemeral != nil:
+		redacted.Ephemeral = &v1.EphemeralVolumeSource{}
+	case volume.CSI != nil && volume.CSI.NodePublishSecretRef != nil:
+		redacted.CSI = &v1.CSIVolumeSource{Driver: volume.CSI.Driver, NodePublishSecretRef: volume.CSI.NodePublishSecretRef}
+	case volume.Projected != nil:
+		redacted.Projected = &v1.ProjectedVolumeSource{}
+		for _, source := range volume.Projected.Sources {
+			if source.Secret != nil {
+				redacted.Projected.Sources = append(redacted.Projected.Sources, v1.VolumeProjection{Secret: &v1.SecretProjection{LocalObjectReference: source.Secret.LocalObjectReference}})
+			}
+			if source.ConfigMap != nil {
+				redacted.Projected.Sources = append(redacted.Projected.Sources, v1.VolumeProjection{ConfigMap: &v1.ConfigMapProjection{LocalObjectReference: source.ConfigMap.LocalObjectReference}})
+			}
+			if source.ServiceAccountToken != nil {
+				redacted.Projected.Sources = append(redacted.Projected.Sources, v1.VolumeProjection{ServiceAccountToken: &v1.ServiceAccountTokenProjection{Audience: source.ServiceAccountToken.Audience, Path: source.ServiceAccountToken.Path}})
+			}
+		}
+		if len(redacted.Projected.Sources) == 0 {
+			return redacted, false
+		}
+	default:
+		return redacted, false
+	}
+	return redacted, true
+}
+
+// Writes snapshot files into a directory, or into a gzipped tarball
+type snapshotWriter struct {
+	dir        string // directory files are written into, or their directory in the tarball
+	file       *os.File
+	gzipWriter *gzip.Writer
+	tarWriter  *tar.Writer
+}
+
+// Returns a snapshotWriter for @output, a .tar.gz or .tgz archive or a directory.
+// Refuses to write into non-empty directories, as leftover files would be read as part of the snapshot.
+func newSnapshotWriter(output string) (*snapshotWriter, error) {
+	if isTarGz(output) {
+		file, err := os.Create(output)
+		if err != nil {
+			log.Errorf("newSnapshotWriter: failed to create %v with %v\n", output, err)
+			return nil, err
+		}
+		gzipWriter := gzip.NewWriter(file)
+		return &snapshotWriter{
+			dir:        strings.TrimSuffix(strings.TrimSuffix(filepath.Base(output), ".tgz"), ".tar.gz"),
+			file:       file,
+			gzipWriter: gzipWriter,
+			tarWriter:  tar.NewWriter(gzipWriter),
+		}, nil
+	}
+
+	entries, err := os.ReadDir(output)
+	if err == nil && len(entries) > 0 {
+		err = fmt.Errorf("directory isn't empty")
+		log.Errorf("newSnapshotWriter: refusing to write snapshot to %v, %v\n", output, err)
+		return nil, err
+	}
+	if err = os.MkdirAll(output, 0755); err != nil {
+		log.Errorf("newSnapshotWriter: failed to create directory %v with %v\n", output, err)
+		return nil, err
+	}
+	return &snapshotWriter{dir: output}, nil
+}
+
+// Writes @data to the snapshot file @name
+func (w *snapshotWriter) WriteFile(name string, data []byte) error {
+	if w.tarWriter == nil {
+		if err := os.WriteFile(filepath.Join(w.dir, name), data, 0644); err != nil {
+			log.Errorf("WriteFile: failed to write %v with %v\n", name, err)
+			return err
+		}
+		return nil
+	}
+	header := &tar.Header{
+		Name:     path.Join(w.dir, name),
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  time.Now(),
+		Typeflag: tar.TypeReg,
+	}
+	if err := w.tarWriter.WriteHeader(header); err != nil {
+		log.Errorf("WriteFile: failed to write %v to archive with %v\n", name, err)
+		return err
+	}
+	if _, err := w.tarWriter.Write(data); err != nil {
+		log.Errorf("WriteFile: failed to write %v to archive with %v\n", name, err)
+		return err
+	}
+	return nil
+}
+
+// Flushes and closes the archive, if writing one
+func (w *snapshotWriter) Close() error {
+	if w.tarWriter == nil {
+		return nil
+	}
+	if err := w.tarWriter.Close(); err != nil {
+		log.Errorf("Close: failed to close archive with %v\n", err)
+		w.file.Close()
+		return err
+	}
+	if err := w.gzipWriter.Close(); err != nil {
+		log.Errorf("Close: failed to compress archive with %v\n", err)
+		w.file.Close()
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		log.Errorf("Close: failed to close archive with %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// Returns whether @output is a gzipped tarball per its extension
+func isTarGz(output string) bool {
+	return strings.HasSuffix(output, ".tar.gz") || strings.HasSuffix(output, ".tgz")
+}
@@ -0,0 +1,241 @@
+package collect
+
+import (
+	"reflect"
+	"strconv"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Kinds the parser decodes that aren't namespaced
+var clusterScopedKinds = map[string]bool{
+	"Node":               true,
+	"Namespace":          true,
+	"ClusterRole":        true,
+	"ClusterRoleBinding": true,
+}
+
+// Returns the pod template of @obj if it's a workload, nil otherwise
+func podTemplate(obj interface{}) *v1.PodTemplateSpec {
+	switch workload := obj.(type) {
+	case *appsv1.Deployment:
+		return &workload.Spec.Template
+	case *appsv1.StatefulSet:
+		return &workload.Spec.Template
+	case *appsv1.DaemonSet:
+		return &workload.Spec.Template
+	case *appsv1.ReplicaSet:
+		return &workload.Spec.Template
+	case *batchv1.Job:
+		return &workload.Spec.Template
+	case *batchv1.CronJob:
+		return &workload.Spec.JobTemplate.Spec.Template
+	case *batchv1beta1.CronJob:
+		return &workload.Spec.JobTemplate.Spec.Template
+	case *v1.ReplicationController:
+		return workload.Spec.Template
+	}
+	return nil
+}
+
+// Synthesizes pods from the pod templates of the parsed workloads, so their serviceAccounts are attributed to nodes.
+// As the workloads aren't scheduled yet, a pod is synthesized on every node the workload may be scheduled on,
+// per its nodeName, nodeSelector, required node affinity and tolerations. If there are no such nodes, a single unscheduled pod is synthesized.
+// ServiceAccounts the pods are assigned that don't exist, like the default serviceAccount of a new namespace, are synthesized as well.
+func (p *localClusterParser) synthesizeWorkloadPods() {
+	for _, w := range p.workloads {
+		spec := w.Template.Spec
+		if spec.ServiceAccountName == "" {
+			spec.ServiceAccountName = spec.DeprecatedServiceAccount
+		}
+		if spec.ServiceAccountName == "" {
+			spec.ServiceAccountName = "default"
+		}
+		if !serviceAccountExists(p.clusterDb.ServiceAccounts, spec.ServiceAccountName, w.Metadata.Namespace) {
+			log.Debugf("synthesizeWorkloadPods: synthesizing serviceAccount %s:%s assigned to %s %s\n", w.Metadata.Namespace, spec.ServiceAccountName, w.Kind, w.Metadata.Name)
+			p.clusterDb.ServiceAccounts = append(p.clusterDb.ServiceAccounts, v1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{Name: spec.ServiceAccountName, Namespace: w.Metadata.Namespace},
+			})
+		}
+
+		nodes := schedulableNodes(p.clusterDb.Nodes, spec)
+		if len(nodes) == 0 {
+			log.Debugf("synthesizeWorkloadPods: no nodes %s %s/%s can be scheduled on, synthesizing an unscheduled pod\n", w.Kind, w.Metadata.Namespace, w.Metadata.Name)
+			p.clusterDb.Pods = append(p.clusterDb.Pods, synthesizedPod(w, spec, w.Metadata.Name, ""))
+			continue
+		}
+		for _, node := range nodes {
+			p.clusterDb.Pods = append(p.clusterDb.Pods, synthesizedPod(w, spec, w.Metadata.Name+"-"+node, node))
+		}
+	}
+}
+
+// Returns a pod named @name of workload @w with @spec, scheduled on @nodeName
+func synthesizedPod(w workload, spec v1.PodSpec, name string, nodeName string) v1.Pod {
+	spec.NodeName = nodeName
+	return v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: w.Metadata.Namespace,
+			Labels:    w.Template.Labels,
+		},
+		Spec: spec,
+	}
+}
+
+// Returns whether @serviceAccounts contains the serviceAccount @name in @namespace
+func serviceAccountExists(serviceAccounts []v1.ServiceAccount, name string, namespace string) bool {
+	for _, serviceAccount := range serviceAccounts {
+		if serviceAccount.Name == name && serviceAccount.Namespace == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the names of the @nodes a pod with @spec may be scheduled on
+func schedulableNodes(nodes []v1.Node, spec v1.PodSpec) []string {
+	var schedulable []string
+	for _, node := range nodes {
+		if spec.NodeName != "" {
+			if node.Name == spec.NodeName {
+				schedulable = append(schedulable, node.Name)
+			}
+			continue
+		}
+		if matchesNodeSelector(node, spec.NodeSelector) && matchesRequiredNodeAffinity(node, spec.Affinity) && toleratesTaints(node, spec.Tolerations) {
+			schedulable = append(schedulable, node.Name)
+		}
+	}
+	return schedulable
+}
+
+// Returns whether @node has all the labels of @nodeSelector
+func matchesNodeSelector(node v1.Node, nodeSelector map[string]string) bool {
+	for key, value := range nodeSelector {
+		if nodeValue, ok := node.Labels[key]; !ok || nodeValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns whether @node satisfies the node affinity of @affinity that's required during scheduling.
+// Preferred node affinity doesn't prevent scheduling, and so is ignored
+func matchesRequiredNodeAffinity(node v1.Node, affinity *v1.Affinity) bool {
+	if affinity == nil || affinity.NodeAffinity == nil || affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return true
+	}
+	// Terms are ORed, while the requirements of a term are ANDed
+	for _, term := range affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		if matchesNodeSelectorTerm(node, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns whether @node matches the requirements of the node selector @term, an empty term matches no nodes
+func matchesNodeSelectorTerm(node v1.Node, term v1.NodeSelectorTerm) bool {
+	if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+		return false
+	}
+	for _, requirement := range term.MatchExpressions {
+		value, exists := node.Labels[requirement.Key]
+		if !matchesNodeSelectorRequirement(requirement, value, exists) {
+			return false
+		}
+	}
+	for _, requirement := range term.MatchFields {
+		if requirement.Key != metav1.ObjectNameField { // the only field supported by the scheduler
+			return false
+		}
+		if !matchesNodeSelectorRequirement(requirement, node.Name, true) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns whether a node label or field with @value satisfies @requirement, @exists is false if the node doesn't have it
+func matchesNodeSelectorRequirement(requirement v1.NodeSelectorRequirement, value string, exists bool) bool {
+	switch requirement.Operator {
+	case v1.NodeSelectorOpIn:
+		return exists && utils.Contains(requirement.Values, value)
+	case v1.NodeSelectorOpNotIn:
+		return !exists || !utils.Contains(requirement.Values, value)
+	case v1.NodeSelectorOpExists:
+		return exists
+	case v1.NodeSelectorOpDoesNotExist:
+		return !exists
+	case v1.NodeSelectorOpGt, v1.NodeSelectorOpLt:
+		if !exists || len(requirement.Values) != 1 {
+			return false
+		}
+		nodeValue, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
+		}
+		requiredValue, err := strconv.ParseInt(requirement.Values[0], 10, 64)
+		if err != nil {
+			return false
+		}
+		if requirement.Operator == v1.NodeSelectorOpGt {
+			return nodeValue > requiredValue
+		}
+		return nodeValue < requiredValue
+	}
+	return false
+}
+
+// Returns whether @tolerations tolerate the taints of @node that prevent scheduling
+func toleratesTaints(node v1.Node, tolerations []v1.Toleration) bool {
+	for i, taint := range node.Spec.Taints {
+		if taint.Effect != v1.TaintEffectNoSchedule && taint.Effect != v1.TaintEffectNoExecute {
+			continue
+		}
+		tolerated := false
+		for _, toleration := range tolerations {
+			if toleration.ToleratesTaint(&node.Spec.Taints[i]) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return false
+		}
+	}
+	return true
+}
+
+// Removes objects with the same name and namespace from each of @clusterDb's object lists,
+// the last object replaces the earlier ones in their position
+func dedupeClusterDb(clusterDb *ClusterDb) {
+	db := reflect.ValueOf(clusterDb).Elem()
+	for i := 0; i < db.NumField(); i++ {
+		objects := db.Field(i)
+		seen := make(map[string]int)
+		deduped := reflect.MakeSlice(objects.Type(), 0, objects.Len())
+		for j := 0; j < objects.Len(); j++ {
+			object, ok := objects.Index(j).Addr().Interface().(metav1.Object)
+			if !ok {
+				deduped = reflect.Append(deduped, objects.Index(j))
+				continue
+			}
+			key := object.GetNamespace() + "/" + object.GetName()
+			if index, ok := seen[key]; ok {
+				deduped.Index(index).Set(objects.Index(j))
+				continue
+			}
+			seen[key] = deduped.Len()
+			deduped = reflect.Append(deduped, objects.Index(j))
+		}
+		objects.Set(deduped)
+	}
+}
@@ -14,6 +14,15 @@ type CollectConfig struct {
 	NodeGroups          []string
 	NodeUser            string
 	Namespace           string
+	GroupMembership     string   // file mapping users to groups, static YAML, CSV or the EKS aws-auth configmap
+	AssumeFeatures      []string // features to add to the cluster's metadata, e.g. NodeRestriction for offline runs
+	DenyFeatures        []string // features to remove from the cluster's metadata
+}
+
+// SnapshotConfig holds the options for Snapshot()
+type SnapshotConfig struct {
+	Output string // directory, or a .tar.gz or .tgz archive
+	Redact bool   // strip pods down to the fields rbac-police needs
 }
 
 // CollectResult is the output of Collect()
@@ -25,6 +34,8 @@ type CollectResult struct {
 	Users           []NamedEntry          `json:"users"`
 	Groups          []NamedEntry          `json:"groups"`
 	Roles           []RoleEntry           `json:"roles"`
+	Namespaces      []NamespaceEntry      `json:"namespaces"`
+	Secrets         []SecretEntry         `json:"secrets"` // null if secrets couldn't be listed, empty if there are none
 }
 
 // ClusterDb holds cluster objects relevant to RBAC
@@ -32,6 +43,8 @@ type ClusterDb struct {
 	Pods                []v1.Pod            // TODO: only need name, namespace, serviceaccount, and node, not full object
 	Nodes               []v1.Node           // TODO: only need name, not full object
 	ServiceAccounts     []v1.ServiceAccount // TODO: only need name, namespace, and annotations, not full object
+	Namespaces          []v1.Namespace      // TODO: only need name and labels, not full object
+	Secrets             []v1.Secret         // metadata and type only, never data
 	Roles               []rbac.Role
 	ClusterRoles        []rbac.ClusterRole
 	RoleBindings        []rbac.RoleBinding
@@ -48,12 +61,23 @@ type RbacDb struct {
 }
 
 type ClusterMetadata struct {
-	ClusterName string         `json:"cluster"`
-	Platform    string         `json:"platform"`
-	Version     ClusterVersion `json:"version"`
-	Features    []string       `json:"features"`
+	ClusterName    string            `json:"cluster"`
+	Platform       string            `json:"platform"`
+	Version        ClusterVersion    `json:"version"`
+	Features       []string          `json:"features"`
+	FeatureSources map[string]string `json:"featureSources,omitempty"` // features to whether they were discovered, assumed or inferred
 }
 
+// Sources of the features in ClusterMetadata
+const (
+	FeatureDiscovered = "discovered" // discovered via --discover-protections, possibly when a snapshot was taken
+	FeatureAssumed    = "assumed"    // set via --assume-features
+	FeatureInferred   = "inferred"   // inferred from the cluster's version
+)
+
+// Features built-in policies consider
+var knownFeatures = []string{"LegacyTokenSecretsReducted", "NodeRestriction", "NodeRestriction1.17"}
+
 type ClusterVersion struct {
 	Major      string `json:"major"`
 	Minor      string `json:"minor"`
@@ -67,6 +91,15 @@ type ServiceAccountEntry struct {
 	Nodes       []NodeToPods      `json:"nodes,omitempty"`
 	ProviderIAM map[string]string `json:"providerIAM,omitempty"`
 	Roles       []RoleRef         `json:"roles"`
+	LegacyToken *LegacyToken      `json:"legacyToken,omitempty"` // nil if the serviceAccount has no long-lived token, or if secrets weren't collected
+}
+
+// LegacyToken describes the long-lived token secrets of a serviceAccount
+type LegacyToken struct {
+	Secrets  []string `json:"secrets"`
+	Created  string   `json:"created,omitempty"`  // creation time of the oldest token secret
+	AgeDays  int      `json:"ageDays,omitempty"`  // age of the oldest token secret in days
+	LastUsed string   `json:"lastUsed,omitempty"` // date the tokens were last used on, per the 'kubernetes.io/legacy-token-last-used' label
 }
 
 func (s *ServiceAccountEntry) Equals(name string, namespace string) bool {
@@ -75,9 +108,26 @@ func (s *ServiceAccountEntry) Equals(name string, namespace string) bool {
 
 // NodeEntry holds the RBAC info of a node
 type NodeEntry struct {
-	Name            string    `json:"name"`
-	Roles           []RoleRef `json:"roles"`
-	ServiceAccounts []string  `json:"serviceAccounts"`
+	Name            string                `json:"name"`
+	Roles           []RoleRef             `json:"roles"`
+	ServiceAccounts []string              `json:"serviceAccounts"`
+	NodeAuthorizer  *NodeAuthorizerAccess `json:"nodeAuthorizer,omitempty"` // nil if nodes aren't authorized by the NodeAuthorizer
+}
+
+// NodeAuthorizerAccess lists the objects the NodeAuthorizer permits a node to access, as pods bound to the node reference them
+type NodeAuthorizerAccess struct {
+	Secrets                []NodeObjectRef `json:"secrets"`
+	ConfigMaps             []NodeObjectRef `json:"configMaps"`
+	PersistentVolumeClaims []NodeObjectRef `json:"persistentVolumeClaims"`
+	ServiceAccountTokens   []NodeObjectRef `json:"serviceAccountTokens"` // serviceAccounts the node can issue tokens for
+}
+
+// NodeObjectRef denotes an object a node can access, and the pods on the node that reference it
+type NodeObjectRef struct {
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace"`
+	Pods      []string `json:"pods"`
+	Audiences []string `json:"audiences,omitempty"` // for serviceAccount tokens, the audiences of tokens projected into the pods
 }
 
 // NamedEntry marks an identity with roles denoted by only a name, like a user or a group
@@ -98,6 +148,7 @@ type RoleRef struct {
 	Name               string `json:"name"`
 	Namespace          string `json:"namespace,omitempty"`
 	EffectiveNamespace string `json:"effectiveNamespace,omitempty"`
+	Group              string `json:"group,omitempty"` // the group the role is granted or inherited via, empty if granted directly
 }
 
 // NodeToPods list the pods on a node
@@ -105,3 +156,29 @@ type NodeToPods struct {
 	Name string   `json:"name"`
 	Pods []string `json:"pods"`
 }
+
+// NamespaceEntry holds the context of a namespace that's relevant to policies
+type NamespaceEntry struct {
+	Name               string            `json:"name"`
+	Labels             map[string]string `json:"labels,omitempty"`
+	PodSecurityEnforce string            `json:"podSecurityEnforce,omitempty"`
+}
+
+// GroupMemberships maps users to the groups they're members of
+type GroupMemberships map[string][]string
+
+// Static group membership file
+type staticGroupMemberships struct {
+	Users  map[string][]string `json:"users"`  // users to their groups
+	Groups map[string][]string `json:"groups"` // groups to their members
+}
+
+// SecretEntry holds the metadata of a secret, never its data
+type SecretEntry struct {
+	Name           string `json:"name"`
+	Namespace      string `json:"namespace"`
+	Type           string `json:"type"`
+	ServiceAccount string `json:"serviceAccount,omitempty"` // for serviceAccount token secrets, the serviceAccount the token belongs to
+	Owner          string `json:"owner,omitempty"`          // kind/name of the secret's controller or first owner
+	Highlight      string `json:"highlight,omitempty"`      // privilegedServiceAccountToken, cloudCredentials or tlsCA
+}
@@ -5,9 +5,9 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/open-policy-agent/opa/storage"
 	"github.com/open-policy-agent/opa/storage/inmem"
+	"path/filepath"
 	"strings"
 
 	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
@@ -27,6 +27,7 @@ var (
 	}
 	severityMap     = map[string]int{"Low": 1, "Medium": 2, "High": 3, "Critical": 4, "": 5}
 	builtinsLibPath = "lib/utils/builtins.rego" // TODO: move out of eval.go / make configurable / go-bindata
+	testFileSuffix  = "_test.rego"
 )
 
 // Evaluates RBAC permissions using Rego policies
@@ -36,6 +37,13 @@ func Eval(policyPath string, collectResult collect.CollectResult, evalConfig Eva
 		log.SetLevel(log.DebugLevel)
 	}
 
+	// Highlight the tokens of serviceAccounts that are privileged per the privileged namespaces, before identities are removed
+	privilegedNamespaces, err := ResolvePrivilegedNamespaces(collectResult.Namespaces, evalConfig)
+	if err != nil {
+		return nil
+	}
+	collectResult.Secrets = collect.HighlightTokens(collectResult.Secrets, collectResult.ServiceAccounts, collectResult.Roles, privilegedNamespaces)
+
 	// Remove identities that we're not going to evaluate per the `--violations` flag
 	removedUnneededIdentities(&collectResult, evalConfig)
 
@@ -76,43 +84,57 @@ func Eval(policyPath string, collectResult collect.CollectResult, evalConfig Eva
 	}
 
 	// Prepare configuration for policies
-	policyConfig := fmt.Sprintf(`{
-		"config": {
-			"evalSaViolations": %t,
-			"evalNodeViolations": %t,
-			"evalCombinedViolations": %t,
-			"evalUserViolations": %t,
-			"evalGroupViolations": %t
-		}
-	}`, evalConfig.SaViolations, evalConfig.NodeViolations, evalConfig.CombinedViolations, evalConfig.UserViolations, evalConfig.GroupViolations)
+	policyData, err := loadPolicyData(evalConfig.PolicyDataFiles)
+	if err != nil {
+		return nil
+	}
 
 	// Run policies against input json
 	var policyResults PolicyResults
-	failedPolicies, errorsCounter, belowThresholdPolicies := 0, 0, 0
+	failedPolicies, errorsCounter, belowThresholdPolicies, filteredPolicies := 0, 0, 0, 0
 	for _, policyFile := range policyFiles {
 		log.Debugf("eval: running policy %v...\n", policyFile)
+		outcome := PolicyOutcome{PolicyFile: policyFile, Status: PolicyPassed}
+		policyConfig, err := buildPolicyConfig(evalConfig, privilegedNamespaces, policyData.forPolicy(policyFile))
+		if err != nil {
+			errorsCounter += 1
+			outcome.Status, outcome.Reason = PolicyErrored, err.Error()
+			policyResults.Outcomes = append(policyResults.Outcomes, outcome)
+			continue
+		}
 		currPolicyResult, err := runPolicy(policyFile, rbacJson, policyConfig, evalConfig)
 		if err != nil {
 			switch err.(type) {
 			default:
 				errorsCounter += 1
+				outcome.Status = PolicyErrored
 			case *belowThresholdErr:
-				belowThresholdPolicies += 1 // unused
+				belowThresholdPolicies += 1
+				outcome.Status = PolicySkipped
+			case *filteredErr:
+				filteredPolicies += 1
+				outcome.Status = PolicySkipped
 			}
-			continue
-		}
-		if currPolicyResult != nil {
+			outcome.Reason = err.Error()
+		} else if currPolicyResult != nil {
 			failedPolicies += 1
+			outcome.Status = PolicyFailed
 			policyResults.PolicyResults = append(policyResults.PolicyResults, *currPolicyResult)
 		}
+		policyResults.Outcomes = append(policyResults.Outcomes, outcome)
 	}
 
 	// Summarize
+	evaluatedPolicies := len(policyFiles) - belowThresholdPolicies - filteredPolicies
 	policyResults.Summary = Summary{
-		Evaluated: len(policyFiles),
+		Evaluated: evaluatedPolicies,
 		Failed:    failedPolicies,
-		Passed:    len(policyFiles) - failedPolicies - errorsCounter,
+		Passed:    evaluatedPolicies - failedPolicies - errorsCounter,
 		Errors:    errorsCounter,
+		Skipped: SkippedSummary{
+			BelowThreshold: belowThresholdPolicies,
+			Filtered:       filteredPolicies,
+		},
 	}
 
 	return &policyResults
@@ -122,11 +144,20 @@ func Eval(policyPath string, collectResult collect.CollectResult, evalConfig Eva
 func runPolicy(policyFile string, rbacJson interface{}, policyConfig string, evalConfig EvalConfig) (*PolicyResult, error) {
 	policyResult := PolicyResult{PolicyFile: policyFile}
 
-	// Get policy description & severity
-	desc := describePolicy(policyFile)
+	// Get policy description, severity & metadata
+	desc := describePolicy(policyFile, policyConfig)
 	if desc != nil {
 		policyResult.Severity = desc.Severity
 		policyResult.Description = desc.Description
+		policyResult.PolicyMetadata = desc.PolicyMetadata
+	}
+	if policyResult.ID == "" {
+		policyResult.ID = strings.TrimSuffix(filepath.Base(policyFile), ".rego")
+	}
+
+	// Don't evaluate if excluded by filters
+	if !policyPassesFilters(&policyResult, evalConfig) {
+		return nil, &filteredErr{}
 	}
 
 	// Don't evaluate if severity is under threshold
@@ -145,20 +176,33 @@ func runPolicy(policyFile string, rbacJson interface{}, policyConfig string, eva
 }
 
 // Get policy's description and severity
-func describePolicy(policyFile string) *DescribeRegoResult {
+func describePolicy(policyFile string, policyConfig string) *DescribeRegoResult {
+	var (
+		desc DescribeRegoResult
+		ctx  = context.Background()
+	)
+
+	// Descriptions may depend on the policy config (e.g. privileged namespaces)
+	store, txn, err := newPolicyStore(ctx, policyConfig)
+	if err != nil {
+		log.Debugf("describePolicy: error preparing transaction for %v with %v\n", policyFile, err)
+		return nil
+	}
+
 	// Prepare query
-	var desc DescribeRegoResult
 	describeQuery, err := rego.New(
 		rego.Query("data.policy.describe[_]"),
+		rego.Store(store),
+		rego.Transaction(txn),
 		rego.Load([]string{policyFile, builtinsLibPath}, nil),
-	).PrepareForEval(context.Background())
+	).PrepareForEval(ctx)
 	if err != nil {
 		log.Debugf("describePolicy: error preparing query for %v with %v\n", policyFile, err)
 		return nil
 	}
 
 	// Run describe query
-	rs, err := describeQuery.Eval(context.Background())
+	rs, err := describeQuery.Eval(ctx)
 	if err != nil {
 		log.Debugf("describePolicy: failed to evaluate query for %v with %v\n", policyFile, err)
 		return nil
@@ -183,28 +227,22 @@ func evaluatePolicy(policyFile string, input interface{}, policyConfig string, e
 		foundViolations = false
 		violations      Violations
 		queryStr        string
-		regoFiles       = []string{policyFile, builtinsLibPath}
 		ctx             = context.Background()
 	)
 
-	// Read policy file
-	policyBytes, err := utils.ReadFile(policyFile)
+	// Get the Rego files needed to evaluate the policy
+	regoFiles, wrapped, err := policyRegoFiles(policyFile)
 	if err != nil {
 		return nil, err
 	}
-	policy := string(policyBytes)
-
-	// Wrap policy if needed
-	if policyNeedsWrapping(policy) {
-		regoFiles = append([]string{wrapperFile}, regoFiles...)
+	if wrapped {
 		queryStr = "data.wrapper.main[_]"
 	} else {
 		queryStr = "data.policy.main[_]"
 	}
 
-	// Manually create storage in-memory, write policyConfig into it, and set up a writable transaction for Load()
-	store := inmem.NewFromReader(bytes.NewBufferString(policyConfig))
-	txn, err := store.NewTransaction(ctx, storage.WriteParams)
+	// Prepare storage holding the policy config
+	store, txn, err := newPolicyStore(ctx, policyConfig)
 	if err != nil {
 		log.Errorf("evaluatePolicy: error preparing transaction for %v with %v\n", policyFile, err)
 		return nil, err
@@ -289,6 +327,44 @@ func evaluatePolicy(policyFile string, input interface{}, policyConfig string, e
 	return &violations, nil
 }
 
+// Returns the Rego files needed to evaluate @policyFile, and whether the policy is wrapped
+func policyRegoFiles(policyFile string) ([]string, bool, error) {
+	regoFiles := []string{policyFile, builtinsLibPath}
+
+	// Read policy file
+	policyBytes, err := utils.ReadFile(policyFile)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Wrap policy if needed
+	if policyNeedsWrapping(string(policyBytes)) {
+		return append([]string{wrapperFile}, regoFiles...), true, nil
+	}
+	return regoFiles, false, nil
+}
+
+// BuiltinsFile returns the path of the builtins library policies are evaluated with
+func BuiltinsFile() string {
+	return builtinsLibPath
+}
+
+// Returns the Rego files needed to evaluate @policyFile: the policy, the builtins, and the wrapper if needed
+func RegoFiles(policyFile string) ([]string, error) {
+	regoFiles, _, err := policyRegoFiles(policyFile)
+	return regoFiles, err
+}
+
+// Manually create storage in-memory, write @policyConfig into it, and set up a writable transaction for Load()
+func newPolicyStore(ctx context.Context, policyConfig string) (storage.Store, storage.Transaction, error) {
+	store := inmem.NewFromReader(bytes.NewBufferString(policyConfig))
+	txn, err := store.NewTransaction(ctx, storage.WriteParams)
+	if err != nil {
+		return nil, nil, err
+	}
+	return store, txn, nil
+}
+
 // Remove identities that aren't going to be evaluated based on evalConfig
 func removedUnneededIdentities(collectResult *collect.CollectResult, evalConfig EvalConfig) {
 	if !evalConfig.CombinedViolations {
@@ -423,9 +499,12 @@ func AbbreviateResults(policyResults *PolicyResults) AbbreviatedPolicyResults {
 	}
 	for _, policyResult := range policyResults.PolicyResults {
 		currAbbreviatedPolicyResult := AbbreviatedPolicyResult{
-			PolicyFile:  policyResult.PolicyFile,
-			Description: policyResult.Description,
-			Severity:    policyResult.Severity,
+			PolicyFile:     policyResult.PolicyFile,
+			Description:    policyResult.Description,
+			Severity:       policyResult.Severity,
+			PolicyMetadata: policyResult.PolicyMetadata,
+			Usage:          policyResult.Usage,
+			UserGrants:     policyResult.UserGrants,
 		}
 		currAbbreviatedPolicyResult.Violations.Nodes = policyResult.Violations.Nodes
 		currAbbreviatedPolicyResult.Violations.Combined = policyResult.Violations.Combined
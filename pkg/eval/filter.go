@@ -0,0 +1,71 @@
+package eval
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Prefixes that scope a filter to a single policy attribute
+const (
+	idFilterPrefix       = "id:"
+	tagFilterPrefix      = "tag:"
+	categoryFilterPrefix = "category:"
+	fileFilterPrefix     = "file:"
+)
+
+// Returns whether @policyResult should be evaluated based on the include and exclude filters in @evalConfig
+func policyPassesFilters(policyResult *PolicyResult, evalConfig EvalConfig) bool {
+	if len(evalConfig.IncludeFilters) > 0 && !policyMatchesAnyFilter(policyResult, evalConfig.IncludeFilters) {
+		return false
+	}
+	return !policyMatchesAnyFilter(policyResult, evalConfig.ExcludeFilters)
+}
+
+// Returns whether @policyResult matches one of @filters
+func policyMatchesAnyFilter(policyResult *PolicyResult, filters []string) bool {
+	for _, filter := range filters {
+		if policyMatchesFilter(policyResult, filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns whether @policyResult matches @filter, a policy ID, tag, category or file glob.
+// The filter can be prefixed with 'id:', 'tag:', 'category:' or 'file:' to only match that attribute.
+func policyMatchesFilter(policyResult *PolicyResult, filter string) bool {
+	lowerFilter := strings.ToLower(filter)
+	switch {
+	case strings.HasPrefix(lowerFilter, idFilterPrefix):
+		return strings.EqualFold(policyResult.ID, filter[len(idFilterPrefix):])
+	case strings.HasPrefix(lowerFilter, tagFilterPrefix):
+		return containsFold(policyResult.Tags, filter[len(tagFilterPrefix):])
+	case strings.HasPrefix(lowerFilter, categoryFilterPrefix):
+		return strings.EqualFold(policyResult.Category, filter[len(categoryFilterPrefix):])
+	case strings.HasPrefix(lowerFilter, fileFilterPrefix):
+		return fileMatchesGlob(policyResult.PolicyFile, filter[len(fileFilterPrefix):])
+	}
+	return strings.EqualFold(policyResult.ID, filter) ||
+		containsFold(policyResult.Tags, filter) ||
+		strings.EqualFold(policyResult.Category, filter) ||
+		fileMatchesGlob(policyResult.PolicyFile, filter)
+}
+
+// Returns whether @policyFile or its base name match @glob
+func fileMatchesGlob(policyFile string, glob string) bool {
+	if matched, _ := filepath.Match(glob, policyFile); matched {
+		return true
+	}
+	matched, _ := filepath.Match(glob, filepath.Base(policyFile))
+	return matched
+}
+
+// Returns whether @strs contains @value, ignoring case
+func containsFold(strs []string, value string) bool {
+	for _, str := range strs {
+		if strings.EqualFold(str, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package eval
+
+import (
+	"math"
+	"sort"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+)
+
+var (
+	// Weight of each violated policy in the severity factor of risk scores
+	severityWeights = map[string]float64{"Critical": 10, "High": 6, "Medium": 3, "Low": 1, "": 1}
+	// Exposure factor of groups that include every identity, or every serviceAccount
+	exposureFactors = map[string]float64{"system:unauthenticated": 3, "system:authenticated": 2, "system:serviceaccounts": 1.5}
+)
+
+const (
+	providerIAMFactor = 1.5  // a compromised serviceAccount also compromises its cloud provider IAM entity
+	hostingWeight     = 0.25 // weight of the log2 of the number of nodes and pods hosting a serviceAccount
+)
+
+// ByIdentity inverts @policyResults into one entry per violating identity with all the policies it violated.
+// Each identity is given a composite risk score: the sum of the severity weights of its violated policies, multiplied
+// by factors for the nodes and pods hosting a serviceAccount, a cloud provider IAM entity attached to it, and the exposure
+// of its permissions to every identity. A group's permissions are exposed if it's one of ExposureGroups(), other identities'
+// are if their violations are caused by roles granted via such groups, per @grantGroups (see attribute.AttributeGrantGroups).
+// Identities are sorted by score, and if @top is positive only the top ones are kept.
+func ByIdentity(policyResults *PolicyResults, grantGroups map[string][]string, top int) IdentityResults {
+	results := IdentityResults{Identities: []IdentityResult{}, Summary: policyResults.Summary}
+	indexes := make(map[string]int)
+	getIdentity := func(identityType string, name string, namespace string) *IdentityResult {
+		key := IdentityKey(identityType, name, namespace)
+		if i, ok := indexes[key]; ok {
+			return &results.Identities[i]
+		}
+		indexes[key] = len(results.Identities)
+		results.Identities = append(results.Identities, IdentityResult{Type: identityType, Name: name, Namespace: namespace})
+		return &results.Identities[len(results.Identities)-1]
+	}
+
+	for _, policyResult := range policyResults.PolicyResults {
+		violated := ViolatedPolicy{ID: policyResult.ID, PolicyFile: policyResult.PolicyFile, Title: policyResult.Title, Severity: policyResult.Severity}
+		violations := policyResult.Violations
+		for _, sa := range violations.ServiceAccounts {
+			identity := getIdentity("serviceAccount", sa.Name, sa.Namespace)
+			identity.Policies = append(identity.Policies, violated)
+			if nodes, pods := countHosts(sa.Nodes); pods > identity.Pods {
+				identity.Nodes, identity.Pods = nodes, pods
+			}
+			if len(sa.ProviderIAM) > 0 {
+				identity.ProviderIAM = sa.ProviderIAM
+			}
+		}
+		for _, node := range violations.Nodes {
+			identity := getIdentity("node", node, "")
+			identity.Policies = append(identity.Policies, violated)
+		}
+		for _, combined := range violations.Combined {
+			identity := getIdentity("combined", combined.Node, "")
+			identity.Policies = append(identity.Policies, violated)
+		}
+		for _, user := range violations.Users {
+			identity := getIdentity("user", user, "")
+			identity.Policies = append(identity.Policies, violated)
+		}
+		for _, group := range violations.Groups {
+			identity := getIdentity("group", group, "")
+			identity.Policies = append(identity.Policies, violated)
+			if _, ok := exposureFactors[group]; ok {
+				identity.Exposure = group
+			}
+		}
+	}
+
+	for i := range results.Identities {
+		identity := &results.Identities[i]
+		if identity.Type != "group" {
+			identity.Exposure = broadestGroup(grantGroups[IdentityKey(identity.Type, identity.Name, identity.Namespace)])
+		}
+		scoreIdentity(identity)
+		sort.SliceStable(identity.Policies, func(i, j int) bool {
+			return severityWeights[identity.Policies[i].Severity] > severityWeights[identity.Policies[j].Severity]
+		})
+	}
+	sort.SliceStable(results.Identities, func(i, j int) bool {
+		a, b := results.Identities[i], results.Identities[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Type+"/"+utils.FullName(a.Namespace, a.Name) < b.Type+"/"+utils.FullName(b.Namespace, b.Name)
+	})
+	if top > 0 && len(results.Identities) > top {
+		results.Identities = results.Identities[:top]
+	}
+	return results
+}
+
+// IdentityKey returns a key that identifies the identity of @identityType named @name in @namespace
+func IdentityKey(identityType string, name string, namespace string) string {
+	return identityType + "/" + utils.FullName(namespace, name)
+}
+
+// ExposureGroups returns the groups that include every identity or every serviceAccount, whose permissions are exposed to them
+func ExposureGroups() []string {
+	var groups []string
+	for group := range exposureFactors {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// Returns the group out of @groups with the highest exposure factor, or an empty string if none has one
+func broadestGroup(groups []string) string {
+	broadest := ""
+	for _, group := range groups {
+		if factor, ok := exposureFactors[group]; ok && (broadest == "" || factor > exposureFactors[broadest]) {
+			broadest = group
+		}
+	}
+	return broadest
+}
+
+// Sets the risk score of @identity and its factors
+func scoreIdentity(identity *IdentityResult) {
+	factors := RiskFactors{Hosting: 1, ProviderIAM: 1, Exposure: 1}
+	for _, policy := range identity.Policies {
+		weight, ok := severityWeights[policy.Severity]
+		if !ok {
+			weight = severityWeights[""]
+		}
+		factors.Severity += weight
+	}
+	if identity.Nodes > 0 || identity.Pods > 0 {
+		factors.Hosting = round(1 + hostingWeight*(math.Log2(1+float64(identity.Nodes))+math.Log2(1+float64(identity.Pods))))
+	}
+	if len(identity.ProviderIAM) > 0 {
+		factors.ProviderIAM = providerIAMFactor
+	}
+	if identity.Exposure != "" {
+		factors.Exposure = exposureFactors[identity.Exposure]
+	}
+	identity.Factors = factors
+	identity.Score = round(factors.Severity * factors.Hosting * factors.ProviderIAM * factors.Exposure)
+}
+
+// Returns the number of nodes and pods in @nodesToPods, excluding unscheduled pods from the node count
+func countHosts(nodesToPods []map[string][]string) (int, int) {
+	nodes, pods := 0, 0
+	for _, nodeToPods := range nodesToPods {
+		for node, nodePods := range nodeToPods {
+			if node != "" {
+				nodes += 1
+			}
+			pods += len(nodePods)
+		}
+	}
+	return nodes, pods
+}
+
+// Returns @value rounded to 2 decimal places
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
@@ -0,0 +1,116 @@
+package eval
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// Key in policy data files holding per-policy overrides
+	policyOverridesKey = "policies"
+)
+
+// Reads and merges the policy data files at @paths, later files take precedence
+func loadPolicyData(paths []string) (*PolicyData, error) {
+	policyData := PolicyData{
+		Data:     make(map[string]interface{}),
+		Policies: make(map[string]map[string]interface{}),
+	}
+
+	for _, path := range paths {
+		fileBytes, err := utils.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		// Parse file, YAML is a superset of JSON so both are supported
+		var fileData map[string]interface{}
+		if err = yaml.Unmarshal(fileBytes, &fileData); err != nil {
+			log.Errorf("loadPolicyData: failed to parse %v with %v\n", path, err)
+			return nil, err
+		}
+
+		// Split per-policy overrides from data shared by all policies
+		if overrides, ok := fileData[policyOverridesKey]; ok {
+			delete(fileData, policyOverridesKey)
+			overridesMap, ok := overrides.(map[string]interface{})
+			if !ok {
+				log.Errorf("loadPolicyData: '%v' in %v must map policy file names to parameters\n", policyOverridesKey, path)
+				return nil, errInvalidPolicyData
+			}
+			for policyName, params := range overridesMap {
+				paramsMap, ok := params.(map[string]interface{})
+				if !ok {
+					log.Errorf("loadPolicyData: parameters for policy '%v' in %v must be an object\n", policyName, path)
+					return nil, errInvalidPolicyData
+				}
+				policyName = strings.TrimSuffix(policyName, ".rego")
+				if _, ok := policyData.Policies[policyName]; !ok {
+					policyData.Policies[policyName] = make(map[string]interface{})
+				}
+				mergeMaps(policyData.Policies[policyName], paramsMap)
+			}
+		}
+		mergeMaps(policyData.Data, fileData)
+	}
+	return &policyData, nil
+}
+
+// Returns the data @policyFile receives under data.user, the shared data merged with its overrides
+func (p *PolicyData) forPolicy(policyFile string) map[string]interface{} {
+	userData := make(map[string]interface{})
+	if p == nil {
+		return userData
+	}
+	mergeMaps(userData, p.Data)
+	policyName := strings.TrimSuffix(filepath.Base(policyFile), ".rego")
+	if overrides, ok := p.Policies[policyName]; ok {
+		mergeMaps(userData, overrides)
+	}
+	return userData
+}
+
+// Recursively merges @src into @dst, values from @src take precedence
+func mergeMaps(dst map[string]interface{}, src map[string]interface{}) {
+	for key, srcValue := range src {
+		srcMap, srcIsMap := srcValue.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeMaps(dstMap, srcMap)
+			continue
+		}
+		if srcIsMap {
+			// Copy so later merges into dst don't modify src
+			dstMap = make(map[string]interface{})
+			mergeMaps(dstMap, srcMap)
+			dst[key] = dstMap
+			continue
+		}
+		dst[key] = srcValue
+	}
+}
+
+// Builds the data policies receive, eval settings under data.config and user data under data.user
+func buildPolicyConfig(evalConfig EvalConfig, privilegedNamespaces []string, userData map[string]interface{}) (string, error) {
+	policyConfig := map[string]interface{}{
+		"config": map[string]interface{}{
+			"evalSaViolations":       evalConfig.SaViolations,
+			"evalNodeViolations":     evalConfig.NodeViolations,
+			"evalCombinedViolations": evalConfig.CombinedViolations,
+			"evalUserViolations":     evalConfig.UserViolations,
+			"evalGroupViolations":    evalConfig.GroupViolations,
+			"privilegedNamespaces":   privilegedNamespaces,
+		},
+		"user": userData,
+	}
+	policyConfigBytes, err := json.Marshal(policyConfig)
+	if err != nil {
+		log.Errorln("buildPolicyConfig: failed to marshal policy config with", err)
+		return "", err
+	}
+	return string(policyConfigBytes), nil
+}
@@ -1,24 +1,49 @@
 package eval
 
+import (
+	"errors"
+	"time"
+)
+
 // Configuration for Expand()
 type EvalConfig struct {
-	SeverityThreshold  string
-	OnlySasOnAllNodes  bool
-	IgnoredNamespaces  []string
-	DebugMode          bool
-	SaViolations       bool
-	NodeViolations     bool
-	CombinedViolations bool
-	UserViolations     bool
-	GroupViolations    bool
+	SeverityThreshold           string
+	OnlySasOnAllNodes           bool
+	IgnoredNamespaces           []string
+	PrivilegedNamespaces        []string
+	PrivilegedNamespaceSelector string
+	PolicyDataFiles             []string
+	IncludeFilters              []string
+	ExcludeFilters              []string
+	DebugMode                   bool
+	SaViolations                bool
+	NodeViolations              bool
+	CombinedViolations          bool
+	UserViolations              bool
+	GroupViolations             bool
 }
 
 // Evalaution results for policies
 type PolicyResults struct {
-	PolicyResults []PolicyResult `json:"policyResults"`
-	Summary       Summary        `json:"summary"`
+	PolicyResults []PolicyResult  `json:"policyResults"`
+	Summary       Summary         `json:"summary"`
+	Outcomes      []PolicyOutcome `json:"-"` // outcome of each policy file, for report formats
+}
+
+// Outcome of evaluating a policy file
+type PolicyOutcome struct {
+	PolicyFile string
+	Status     string // passed, failed, error or skipped
+	Reason     string // error or reason for skipping
 }
 
+const (
+	PolicyPassed  = "passed"
+	PolicyFailed  = "failed"
+	PolicyErrored = "error"
+	PolicySkipped = "skipped"
+)
+
 // Abbreviated results for policies
 type AbbreviatedPolicyResults struct {
 	PolicyResults []AbbreviatedPolicyResult `json:"policyResults"`
@@ -27,26 +52,81 @@ type AbbreviatedPolicyResults struct {
 
 // Result of policy evaluation
 type PolicyResult struct {
-	PolicyFile  string     `json:"policy"`
-	Severity    string     `json:"severity,omitempty"`
-	Description string     `json:"description,omitempty"`
-	Violations  Violations `json:"violations"`
+	PolicyFile  string `json:"policy"`
+	Severity    string `json:"severity,omitempty"`
+	Description string `json:"description,omitempty"`
+	PolicyMetadata
+	Violations Violations       `json:"violations"`
+	Usage      []ViolationUsage `json:"usage,omitempty"`      // usage of the permissions that caused violations, per audit logs
+	UserGrants []UserGrant      `json:"userGrants,omitempty"` // how violating users were granted the permissions that caused violations
 }
 
 // Result of policy evaluation, abbreviated
 type AbbreviatedPolicyResult struct {
-	PolicyFile  string                `json:"policy"`
-	Severity    string                `json:"severity,omitempty"`
-	Description string                `json:"description,omitempty"`
-	Violations  AbbreviatedViolations `json:"violations,omitempty"`
+	PolicyFile  string `json:"policy"`
+	Severity    string `json:"severity,omitempty"`
+	Description string `json:"description,omitempty"`
+	PolicyMetadata
+	Violations AbbreviatedViolations `json:"violations,omitempty"`
+	Usage      []ViolationUsage      `json:"usage,omitempty"`
+	UserGrants []UserGrant           `json:"userGrants,omitempty"`
+}
+
+// How a violating user was granted the permissions that caused its violation
+type UserGrant struct {
+	User   string   `json:"user"`
+	Direct bool     `json:"direct"`           // granted by roles bound to the user itself
+	Groups []string `json:"groups,omitempty"` // groups the user inherits granting roles from
+}
+
+// How a violating identity exercised the permissions that caused its violation, per audit logs
+type ViolationUsage struct {
+	Type        string                `json:"type"` // serviceAccount, node, combined, user or group
+	Name        string                `json:"name"`
+	Namespace   string                `json:"namespace,omitempty"`
+	Requests    int                   `json:"requests"`
+	LastUsed    *time.Time            `json:"lastUsed,omitempty"`
+	Permissions []ExercisedPermission `json:"permissions,omitempty"`
+	SourceIPs   []string              `json:"sourceIPs,omitempty"`
+	UserAgents  []string              `json:"userAgents,omitempty"`
+}
+
+// Requests that exercised a permission that caused a violation
+type ExercisedPermission struct {
+	Verb           string     `json:"verb"`
+	Namespace      string     `json:"namespace,omitempty"` // empty for cluster scoped requests
+	APIGroup       string     `json:"apiGroup"`
+	Resource       string     `json:"resource,omitempty"`
+	NonResourceURL string     `json:"nonResourceURL,omitempty"`
+	Requests       int        `json:"requests"`
+	LastUsed       *time.Time `json:"lastUsed,omitempty"`
+}
+
+// Structured metadata of a policy, declared in its describe Rego rule
+type PolicyMetadata struct {
+	ID          string   `json:"id,omitempty"` // stable identifier, defaults to the policy's file name
+	Title       string   `json:"title,omitempty"`
+	Category    string   `json:"category,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Mitre       []string `json:"mitre,omitempty"` // MITRE ATT&CK for Containers technique IDs
+	Remediation string   `json:"remediation,omitempty"`
+	References  []string `json:"references,omitempty"`
+	Version     string   `json:"version,omitempty"`
 }
 
 // Summary of results from all evaluated policies
 type Summary struct {
-	Failed    int `json:"failed"`
-	Passed    int `json:"passed"`
-	Errors    int `json:"errors"`
-	Evaluated int `json:"evaluated"`
+	Failed    int            `json:"failed"`
+	Passed    int            `json:"passed"`
+	Errors    int            `json:"errors"`
+	Evaluated int            `json:"evaluated"`
+	Skipped   SkippedSummary `json:"skipped"`
+}
+
+// Policies that weren't evaluated, by reason
+type SkippedSummary struct {
+	BelowThreshold int `json:"belowThreshold"`
+	Filtered       int `json:"filtered"`
 }
 
 // Policy violations
@@ -83,8 +163,9 @@ type CombinedViolation struct {
 
 // Output from the describe Rego rule
 type DescribeRegoResult struct {
-	Severity    string `json:"severity,omitempty"`
-	Description string `json:"desc,omitempty" mapstructure:"desc"`
+	Severity       string `json:"severity,omitempty"`
+	Description    string `json:"desc,omitempty" mapstructure:"desc"`
+	PolicyMetadata `mapstructure:",squash"`
 }
 
 // Output from the main Rego rule
@@ -96,9 +177,61 @@ type EvalRegoResult struct {
 	Groups          []string                  `json:"groups,omitempty"`
 }
 
+// User supplied data for policies, read from policy data files
+type PolicyData struct {
+	Data     map[string]interface{}            // exposed to all policies under data.user
+	Policies map[string]map[string]interface{} // per-policy overrides keyed by policy file name
+}
+
+// Invalid policy data file error
+var errInvalidPolicyData = errors.New("invalid policy data file")
+
 // Below severity threshold error
 type belowThresholdErr struct{}
 
 func (m *belowThresholdErr) Error() string {
 	return "policy's severity is below the severity threshold"
 }
+
+// Policy excluded by filters error
+type filteredErr struct{}
+
+func (m *filteredErr) Error() string {
+	return "policy is excluded by the include / exclude filters"
+}
+
+// Results inverted per identity, sorted by risk score
+type IdentityResults struct {
+	Identities []IdentityResult `json:"identities"`
+	Summary    Summary          `json:"summary"`
+}
+
+// An identity and all the policies it violated
+type IdentityResult struct {
+	Type        string            `json:"type"` // serviceAccount, node, combined, user or group
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace,omitempty"`
+	Score       float64           `json:"score"` // composite risk score, the product of the factors
+	Factors     RiskFactors       `json:"factors"`
+	Nodes       int               `json:"nodes,omitempty"` // nodes hosting the serviceAccount
+	Pods        int               `json:"pods,omitempty"`  // pods the serviceAccount is assigned to
+	ProviderIAM map[string]string `json:"providerIAM,omitempty"`
+	Exposure    string            `json:"exposure,omitempty"` // the broad group the identity's permissions are exposed to, if any
+	Policies    []ViolatedPolicy  `json:"policies"`
+}
+
+// The factors of an identity's risk score
+type RiskFactors struct {
+	Severity    float64 `json:"severity"`    // sum of the severity weights of the violated policies
+	Hosting     float64 `json:"hosting"`     // grows with the nodes and pods hosting a serviceAccount
+	ProviderIAM float64 `json:"providerIAM"` // whether a serviceAccount is attached to a cloud provider IAM entity
+	Exposure    float64 `json:"exposure"`    // whether the identity's permissions are exposed via system:authenticated or system:unauthenticated
+}
+
+// A policy violated by an identity
+type ViolatedPolicy struct {
+	ID         string `json:"id,omitempty"`
+	PolicyFile string `json:"policy"`
+	Title      string `json:"title,omitempty"`
+	Severity   string `json:"severity,omitempty"`
+}
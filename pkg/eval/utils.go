@@ -5,11 +5,18 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
 	"github.com/open-policy-agent/opa/rego"
 	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
-// Get policy files with a .rego suffix under @path, ignoring directories in @ignoredDirs
+// Returns the policy files under @path that Eval() evaluates
+func PolicyFiles(path string) ([]string, error) {
+	return getPolicyFiles(path, ignoredDirs)
+}
+
+// Get policy files with a .rego suffix under @path, ignoring directories in @ignoredDirs and Rego test files
 func getPolicyFiles(path string, ignoredDirs map[string]struct{}) ([]string, error) {
 	fileInfo, err := os.Stat(path)
 	if err != nil {
@@ -27,7 +34,7 @@ func getPolicyFiles(path string, ignoredDirs map[string]struct{}) ([]string, err
 				if err != nil {
 					return err
 				}
-				if info.Mode().IsRegular() && strings.HasSuffix(path, ".rego") {
+				if info.Mode().IsRegular() && strings.HasSuffix(path, ".rego") && !strings.HasSuffix(path, testFileSuffix) {
 					policyFiles = append(policyFiles, path)
 				}
 				if info.IsDir() {
@@ -60,3 +67,38 @@ func logResults(rs rego.ResultSet) {
 		}
 	}
 }
+
+// ResolvePrivilegedNamespaces returns the privileged namespaces, those listed in evalConfig.PrivilegedNamespaces
+// and those from @namespaces whose labels match evalConfig.PrivilegedNamespaceSelector
+func ResolvePrivilegedNamespaces(namespaces []collect.NamespaceEntry, evalConfig EvalConfig) ([]string, error) {
+	privilegedNamespaces := []string{}
+	privilegedNamespacesSet := make(map[string]struct{})
+	for _, ns := range evalConfig.PrivilegedNamespaces {
+		if _, ok := privilegedNamespacesSet[ns]; !ok {
+			privilegedNamespacesSet[ns] = struct{}{}
+			privilegedNamespaces = append(privilegedNamespaces, ns)
+		}
+	}
+	if evalConfig.PrivilegedNamespaceSelector == "" {
+		return privilegedNamespaces, nil
+	}
+
+	selector, err := labels.Parse(evalConfig.PrivilegedNamespaceSelector)
+	if err != nil {
+		log.Errorf("ResolvePrivilegedNamespaces: failed to parse label selector %q with %v\n", evalConfig.PrivilegedNamespaceSelector, err)
+		return nil, err
+	}
+	if len(namespaces) == 0 {
+		log.Warnln("ResolvePrivilegedNamespaces: no namespaces were collected, cannot match the privileged namespace selector")
+	}
+	for _, ns := range namespaces {
+		if !selector.Matches(labels.Set(ns.Labels)) {
+			continue
+		}
+		if _, ok := privilegedNamespacesSet[ns.Name]; !ok {
+			privilegedNamespacesSet[ns.Name] = struct{}{}
+			privilegedNamespaces = append(privilegedNamespaces, ns.Name)
+		}
+	}
+	return privilegedNamespaces, nil
+}
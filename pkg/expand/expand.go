@@ -5,7 +5,8 @@ import (
 )
 
 // Expands roleRefs in collectResult so that each serviceAccount or
-// node enty directly lists its permissions. For a more readble output
+// node enty directly lists its permissions. For a more readble output.
+// If collectResult lists secrets, each identity also lists the secrets it can read
 func Expand(collectResult collect.CollectResult) *ExpandResult {
 	expandResult := ExpandResult{
 		Metadata: collectResult.Metadata,
@@ -18,8 +19,10 @@ func Expand(collectResult collect.CollectResult) *ExpandResult {
 			Namespace:   serviceAccount.Namespace,
 			Nodes:       serviceAccount.Nodes,
 			ProviderIAM: serviceAccount.ProviderIAM,
+			LegacyToken: serviceAccount.LegacyToken,
 		}
-		expandedSA.Roles = expandRoleRefs(serviceAccount.Roles, collectResult.Roles)
+		expandedSA.Roles = ExpandRoleRefs(serviceAccount.Roles, collectResult.Roles)
+		expandedSA.ReadableSecrets = ReadableSecrets(expandedSA.Roles, collectResult.Secrets)
 		expandResult.ServiceAccounts = append(expandResult.ServiceAccounts, expandedSA)
 	}
 
@@ -28,8 +31,10 @@ func Expand(collectResult collect.CollectResult) *ExpandResult {
 		expandedNode := ExpandedNode{
 			Name:            node.Name,
 			ServiceAccounts: node.ServiceAccounts,
+			NodeAuthorizer:  node.NodeAuthorizer,
 		}
-		expandedNode.Roles = expandRoleRefs(node.Roles, collectResult.Roles)
+		expandedNode.Roles = ExpandRoleRefs(node.Roles, collectResult.Roles)
+		expandedNode.ReadableSecrets = NodeReadableSecrets(node, expandedNode.Roles, collectResult.Secrets)
 		expandResult.Nodes = append(expandResult.Nodes, expandedNode)
 	}
 
@@ -37,8 +42,9 @@ func Expand(collectResult collect.CollectResult) *ExpandResult {
 	for _, user := range collectResult.Users {
 		expandedUser := ExpandedNamedEntry{
 			Name:  user.Name,
-			Roles: expandRoleRefs(user.Roles, collectResult.Roles),
+			Roles: ExpandRoleRefs(user.Roles, collectResult.Roles),
 		}
+		expandedUser.ReadableSecrets = ReadableSecrets(expandedUser.Roles, collectResult.Secrets)
 		expandResult.Users = append(expandResult.Users, expandedUser)
 	}
 
@@ -46,21 +52,23 @@ func Expand(collectResult collect.CollectResult) *ExpandResult {
 	for _, group := range collectResult.Groups {
 		expandedGroup := ExpandedNamedEntry{
 			Name:  group.Name,
-			Roles: expandRoleRefs(group.Roles, collectResult.Roles),
+			Roles: ExpandRoleRefs(group.Roles, collectResult.Roles),
 		}
+		expandedGroup.ReadableSecrets = ReadableSecrets(expandedGroup.Roles, collectResult.Secrets)
 		expandResult.Groups = append(expandResult.Groups, expandedGroup)
 	}
 
 	return &expandResult
 }
 
-// Exapnds @rolesRefs to their full roles from @roleObjs
-func expandRoleRefs(roleRefs []collect.RoleRef, roleObjs []collect.RoleEntry) []ExpandedRole {
+// ExpandRoleRefs expands @rolesRefs to their full roles from @roleObjs
+func ExpandRoleRefs(roleRefs []collect.RoleRef, roleObjs []collect.RoleEntry) []ExpandedRole {
 	var expandedRoles []ExpandedRole
 	for _, roleRef := range roleRefs {
 		expandedRole := ExpandedRole{
 			Name:               roleRef.Name,
 			EffectiveNamespace: roleRef.EffectiveNamespace,
+			Group:              roleRef.Group,
 		}
 		for _, roleObj := range roleObjs {
 			if roleObj.Name == roleRef.Name && roleObj.Namespace == roleRef.Namespace {
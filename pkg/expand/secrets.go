@@ -0,0 +1,91 @@
+package expand
+
+import (
+	"sort"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+)
+
+// Verbs that permit reading secrets
+var secretReadVerbs = []string{"get", "list", "watch"}
+
+// ReadableSecrets returns the secrets out of @secrets that @roles permit reading,
+// honoring the namespace the roles are in effect in and their resourceNames
+func ReadableSecrets(roles []ExpandedRole, secrets []collect.SecretEntry) []ReadableSecret {
+	var readableSecrets []ReadableSecret
+	for _, secret := range secrets {
+		var verbs []string
+		for _, role := range roles {
+			if role.EffectiveNamespace != "" && role.EffectiveNamespace != secret.Namespace {
+				continue
+			}
+			for _, rule := range role.Rules {
+				if !valueOrWildcard(rule.APIGroups, "") || !valueOrWildcard(rule.Resources, "secrets") {
+					continue
+				}
+				if len(rule.ResourceNames) > 0 && !utils.Contains(rule.ResourceNames, secret.Name) {
+					continue
+				}
+				for _, verb := range secretReadVerbs {
+					if valueOrWildcard(rule.Verbs, verb) && !utils.Contains(verbs, verb) {
+						verbs = append(verbs, verb)
+					}
+				}
+			}
+		}
+		if len(verbs) > 0 {
+			sort.Strings(verbs)
+			readableSecrets = append(readableSecrets, readableSecret(secret, verbs))
+		}
+	}
+	return readableSecrets
+}
+
+// NodeReadableSecrets returns the secrets out of @secrets that @node can read,
+// either via its roles @roles, or via the NodeAuthorizer as pods bound to it reference them
+func NodeReadableSecrets(node collect.NodeEntry, roles []ExpandedRole, secrets []collect.SecretEntry) []ReadableSecret {
+	readableSecrets := ReadableSecrets(roles, secrets)
+	if node.NodeAuthorizer == nil {
+		return readableSecrets
+	}
+	for _, ref := range node.NodeAuthorizer.Secrets {
+		for _, secret := range secrets {
+			if secret.Name != ref.Name || secret.Namespace != ref.Namespace {
+				continue
+			}
+			found := false
+			for i := range readableSecrets {
+				if readableSecrets[i].Name == ref.Name && readableSecrets[i].Namespace == ref.Namespace {
+					readableSecrets[i].NodeAuthorizer = true
+					found = true
+					break
+				}
+			}
+			if !found {
+				readable := readableSecret(secret, []string{"get"})
+				readable.NodeAuthorizer = true
+				readableSecrets = append(readableSecrets, readable)
+			}
+			break
+		}
+	}
+	return readableSecrets
+}
+
+// Returns a ReadableSecret for @secret, readable via @verbs
+func readableSecret(secret collect.SecretEntry, verbs []string) ReadableSecret {
+	return ReadableSecret{
+		Name:           secret.Name,
+		Namespace:      secret.Namespace,
+		Type:           secret.Type,
+		ServiceAccount: secret.ServiceAccount,
+		Highlight:      secret.Highlight,
+		Verbs:          verbs,
+	}
+}
+
+// True if @arr contains @value or a wildcard
+func valueOrWildcard(arr []string, value string) bool {
+	return utils.Contains(arr, value) || utils.Contains(arr, "*")
+}
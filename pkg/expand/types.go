@@ -17,29 +17,46 @@ type ExpandResult struct {
 
 // RBAC permissions of a serviceAccount
 type ExpandedServiceAccount struct {
-	Name        string               `json:"name"`
-	Namespace   string               `json:"namespace"`
-	Nodes       []collect.NodeToPods `json:"nodes"`
-	ProviderIAM map[string]string    `json:"providerIAM,omitempty"`
-	Roles       []ExpandedRole       `json:"roles"`
+	Name            string               `json:"name"`
+	Namespace       string               `json:"namespace"`
+	Nodes           []collect.NodeToPods `json:"nodes"`
+	ProviderIAM     map[string]string    `json:"providerIAM,omitempty"`
+	Roles           []ExpandedRole       `json:"roles"`
+	LegacyToken     *collect.LegacyToken `json:"legacyToken,omitempty"`
+	ReadableSecrets []ReadableSecret     `json:"readableSecrets,omitempty"`
 }
 
 // RBAC permissions of a node
 type ExpandedNode struct {
-	Name            string         `json:"name"`
-	Roles           []ExpandedRole `json:"roles"`
-	ServiceAccounts []string       `json:"serviceAccounts"`
+	Name            string                        `json:"name"`
+	Roles           []ExpandedRole                `json:"roles"`
+	ServiceAccounts []string                      `json:"serviceAccounts"`
+	NodeAuthorizer  *collect.NodeAuthorizerAccess `json:"nodeAuthorizer,omitempty"`
+	ReadableSecrets []ReadableSecret              `json:"readableSecrets,omitempty"`
 }
 
 // RBAC permissions of an identity denoted by name, like a user or a group
 type ExpandedNamedEntry struct {
-	Name  string         `json:"name"`
-	Roles []ExpandedRole `json:"roles"`
+	Name            string           `json:"name"`
+	Roles           []ExpandedRole   `json:"roles"`
+	ReadableSecrets []ReadableSecret `json:"readableSecrets,omitempty"`
 }
 
 // A role granted in @EffectiveNamespace
 type ExpandedRole struct {
 	Name               string            `json:"name"`
 	EffectiveNamespace string            `json:"effectiveNamespace,omitempty"`
+	Group              string            `json:"group,omitempty"` // for users, the group the role is inherited from
 	Rules              []rbac.PolicyRule `json:"rules"`
 }
+
+// A secret an identity can read, per the secrets listed by collect
+type ReadableSecret struct {
+	Name           string   `json:"name"`
+	Namespace      string   `json:"namespace"`
+	Type           string   `json:"type"`
+	ServiceAccount string   `json:"serviceAccount,omitempty"` // for serviceAccount token secrets, the serviceAccount the token belongs to
+	Highlight      string   `json:"highlight,omitempty"`
+	Verbs          []string `json:"verbs"`
+	NodeAuthorizer bool     `json:"nodeAuthorizer,omitempty"` // for nodes, readable via the NodeAuthorizer
+}
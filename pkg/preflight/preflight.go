@@ -0,0 +1,135 @@
+package preflight
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+)
+
+// Renders the Helm chart or kustomization of @preflightConfig, merges the rendered manifests into the local cluster
+// at CollectConfig.OfflineDir or into an empty one, and evaluates the policies at @policyPath against the merged cluster.
+// Only reports the violations attributable to the rendered manifests, those not found when evaluating the cluster without them.
+func Preflight(policyPath string, preflightConfig PreflightConfig) *PreflightResult {
+	// Evaluate the cluster as is
+	baseCollectResult := collect.CollectWithManifests(preflightConfig.CollectConfig, nil, preflightConfig.Namespace)
+	if baseCollectResult == nil {
+		return nil // error printed in CollectWithManifests
+	}
+	basePolicyResults := eval.Eval(policyPath, *baseCollectResult, preflightConfig.EvalConfig)
+	if basePolicyResults == nil {
+		return nil // error printed in Eval
+	}
+
+	// Evaluate the cluster with the rendered manifests
+	manifests, err := Render(preflightConfig, baseCollectResult.Metadata.Version.GitVersion)
+	if err != nil {
+		return nil // error printed in Render
+	}
+	collectResult := collect.CollectWithManifests(preflightConfig.CollectConfig, manifests, preflightConfig.Namespace)
+	if collectResult == nil {
+		return nil // error printed in CollectWithManifests
+	}
+	policyResults := eval.Eval(policyPath, *collectResult, preflightConfig.EvalConfig)
+	if policyResults == nil {
+		return nil // error printed in Eval
+	}
+
+	return &PreflightResult{
+		PolicyResults: newViolations(basePolicyResults, policyResults),
+		CollectResult: *collectResult,
+	}
+}
+
+// Returns @policyResults without the violations that are also in @basePolicyResults
+func newViolations(basePolicyResults *eval.PolicyResults, policyResults *eval.PolicyResults) *eval.PolicyResults {
+	baseViolations := make(map[string]map[string]struct{})
+	for _, policyResult := range basePolicyResults.PolicyResults {
+		baseViolations[policyResult.PolicyFile] = violationKeys(policyResult.Violations)
+	}
+
+	filtered := eval.PolicyResults{Summary: policyResults.Summary}
+	failedPolicies := make(map[string]struct{})
+	for _, policyResult := range policyResults.PolicyResults {
+		base := baseViolations[policyResult.PolicyFile]
+		var violations eval.Violations
+		for _, sa := range policyResult.Violations.ServiceAccounts {
+			if !contains(base, "sa/"+utils.FullName(sa.Namespace, sa.Name)) {
+				violations.ServiceAccounts = append(violations.ServiceAccounts, sa)
+			}
+		}
+		for _, node := range policyResult.Violations.Nodes {
+			if !contains(base, "node/"+node) {
+				violations.Nodes = append(violations.Nodes, node)
+			}
+		}
+		for _, combined := range policyResult.Violations.Combined {
+			if !contains(base, combinedKey(combined)) {
+				violations.Combined = append(violations.Combined, combined)
+			}
+		}
+		for _, user := range policyResult.Violations.Users {
+			if !contains(base, "user/"+user) {
+				violations.Users = append(violations.Users, user)
+			}
+		}
+		for _, group := range policyResult.Violations.Groups {
+			if !contains(base, "group/"+group) {
+				violations.Groups = append(violations.Groups, group)
+			}
+		}
+		if violations.ServiceAccounts == nil && violations.Nodes == nil && violations.Combined == nil && violations.Users == nil && violations.Groups == nil {
+			continue
+		}
+		policyResult.Violations = violations
+		filtered.PolicyResults = append(filtered.PolicyResults, policyResult)
+		failedPolicies[policyResult.PolicyFile] = struct{}{}
+	}
+
+	// Policies whose violations all predate the manifests now pass
+	for _, outcome := range policyResults.Outcomes {
+		if _, ok := failedPolicies[outcome.PolicyFile]; !ok && outcome.Status == eval.PolicyFailed {
+			outcome.Status = eval.PolicyPassed
+		}
+		filtered.Outcomes = append(filtered.Outcomes, outcome)
+	}
+	filtered.Summary.Failed = len(filtered.PolicyResults)
+	filtered.Summary.Passed = filtered.Summary.Evaluated - filtered.Summary.Failed - filtered.Summary.Errors
+	return &filtered
+}
+
+// Returns a key for each identity in @violations
+func violationKeys(violations eval.Violations) map[string]struct{} {
+	keys := make(map[string]struct{})
+	for _, sa := range violations.ServiceAccounts {
+		keys["sa/"+utils.FullName(sa.Namespace, sa.Name)] = struct{}{}
+	}
+	for _, node := range violations.Nodes {
+		keys["node/"+node] = struct{}{}
+	}
+	for _, combined := range violations.Combined {
+		keys[combinedKey(combined)] = struct{}{}
+	}
+	for _, user := range violations.Users {
+		keys["user/"+user] = struct{}{}
+	}
+	for _, group := range violations.Groups {
+		keys["group/"+group] = struct{}{}
+	}
+	return keys
+}
+
+// Returns a key for @combined, a node and the serviceAccounts it hosts that violate a policy together
+func combinedKey(combined eval.CombinedViolation) string {
+	serviceAccounts := append([]string{}, combined.ServiceAccounts...)
+	sort.Strings(serviceAccounts)
+	return "combined/" + combined.Node + "/" + strings.Join(serviceAccounts, ",")
+}
+
+// Returns whether @set contains @key
+func contains(set map[string]struct{}, key string) bool {
+	_, ok := set[key]
+	return ok
+}
@@ -0,0 +1,115 @@
+package preflight
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/getter"
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// Renders the Helm chart or kustomization at preflightConfig.Source into manifests.
+// @kubeVersion is reported to charts via .Capabilities.KubeVersion, if set.
+func Render(preflightConfig PreflightConfig, kubeVersion string) ([]byte, error) {
+	sourceType, err := sourceTypeOf(preflightConfig.Source)
+	if err != nil {
+		return nil, err
+	}
+	if sourceType == sourceKustomize {
+		if len(preflightConfig.ValuesFiles) > 0 || len(preflightConfig.SetValues) > 0 {
+			log.Warnf("Render: ignoring Helm values for kustomization %v\n", preflightConfig.Source)
+		}
+		return renderKustomization(preflightConfig.Source)
+	}
+	return renderHelmChart(preflightConfig, kubeVersion)
+}
+
+// Returns whether @source is a Helm chart or a kustomization
+func sourceTypeOf(source string) (string, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		log.Errorf("sourceTypeOf: failed to read %v with %v\n", source, err)
+		return "", err
+	}
+	if !info.IsDir() {
+		return sourceHelm, nil // packaged chart
+	}
+	if _, err := os.Stat(filepath.Join(source, chartutil.ChartfileName)); err == nil {
+		return sourceHelm, nil
+	}
+	for _, fileName := range konfig.RecognizedKustomizationFileNames() {
+		if _, err := os.Stat(filepath.Join(source, fileName)); err == nil {
+			return sourceKustomize, nil
+		}
+	}
+	err = os.ErrNotExist
+	log.Errorf("sourceTypeOf: %v is neither a Helm chart nor a kustomization, expected a %v or a %v file\n", source, chartutil.ChartfileName, konfig.DefaultKustomizationFileName())
+	return "", err
+}
+
+// Renders the Helm chart at preflightConfig.Source with its values, like 'helm template'
+func renderHelmChart(preflightConfig PreflightConfig, kubeVersion string) ([]byte, error) {
+	chart, err := loader.Load(preflightConfig.Source)
+	if err != nil {
+		log.Errorf("renderHelmChart: failed to load chart %v with %v\n", preflightConfig.Source, err)
+		return nil, err
+	}
+	valueOptions := values.Options{ValueFiles: preflightConfig.ValuesFiles, Values: preflightConfig.SetValues}
+	chartValues, err := valueOptions.MergeValues(getter.All(cli.New()))
+	if err != nil {
+		log.Errorf("renderHelmChart: failed to read values with %v\n", err)
+		return nil, err
+	}
+
+	install := action.NewInstall(&action.Configuration{Log: log.Debugf})
+	install.DryRun = true
+	install.ClientOnly = true
+	install.Replace = true
+	install.IncludeCRDs = false
+	install.ReleaseName = preflightConfig.ReleaseName
+	install.Namespace = preflightConfig.Namespace
+	if kubeVersion != "" {
+		if install.KubeVersion, err = chartutil.ParseKubeVersion(kubeVersion); err != nil {
+			log.Warnf("renderHelmChart: ignoring the cluster's version %v, failed to parse it with %v\n", kubeVersion, err)
+		}
+	}
+	release, err := install.Run(chart, chartValues)
+	if err != nil {
+		log.Errorf("renderHelmChart: failed to render chart %v with %v\n", preflightConfig.Source, err)
+		return nil, err
+	}
+
+	// Hooks may create RBAC objects and workloads as well
+	manifests := []string{release.Manifest}
+	sort.Slice(release.Hooks, func(i, j int) bool { return release.Hooks[i].Path < release.Hooks[j].Path })
+	for _, hook := range release.Hooks {
+		manifests = append(manifests, hook.Manifest)
+	}
+	return []byte(strings.Join(manifests, "\n---\n")), nil
+}
+
+// Renders the kustomization at @path, like 'kustomize build'
+func renderKustomization(path string) ([]byte, error) {
+	kustomizer := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+	resources, err := kustomizer.Run(filesys.MakeFsOnDisk(), path)
+	if err != nil {
+		log.Errorf("renderKustomization: failed to build kustomization %v with %v\n", path, err)
+		return nil, err
+	}
+	manifests, err := resources.AsYaml()
+	if err != nil {
+		log.Errorf("renderKustomization: failed to marshal the resources of kustomization %v with %v\n", path, err)
+		return nil, err
+	}
+	return manifests, nil
+}
@@ -0,0 +1,28 @@
+package preflight
+
+import (
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+)
+
+// PreflightConfig holds the options for Preflight()
+type PreflightConfig struct {
+	Source        string   // path to a Helm chart, a packaged chart or a kustomization directory
+	ValuesFiles   []string // Helm values files
+	SetValues     []string // Helm values set on the command line, e.g. 'key=value'
+	ReleaseName   string
+	Namespace     string // release namespace, and namespace of rendered objects that don't specify one
+	CollectConfig collect.CollectConfig
+	EvalConfig    eval.EvalConfig
+}
+
+// Result of Preflight()
+type PreflightResult struct {
+	PolicyResults *eval.PolicyResults   // only the violations attributable to the rendered manifests
+	CollectResult collect.CollectResult // RBAC data of the cluster with the rendered manifests
+}
+
+const (
+	sourceHelm      = "helm"
+	sourceKustomize = "kustomize"
+)
@@ -0,0 +1,198 @@
+package prevent
+
+import (
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// Rego of the template denying assignments of admin-equivalent serviceAccounts to pods,
+// based on docs/prevent/suspicious_assignment_of_controller_service_accounts
+const assignPowerfulSARego = `package rbacpoliceassignpowerfulsa
+
+operations = {"CREATE", "UPDATE"}
+
+violation[{"msg": msg, "details": {"serviceAccount": sa, "namespace": ns}}] {
+  operations[input.review.operation]
+  podSpec := getPodSpec(input.review.object)
+  sa := object.get(podSpec, "serviceAccountName", "default")
+  ns := object.get(input.review.object.metadata, "namespace", input.review.namespace)
+  forbiddenSA := input.parameters.serviceAccounts[_]
+  sa == forbiddenSA.name
+  ns == forbiddenSA.namespace
+  msg := sprintf("Forbidden: cannot assign the admin-equivalent '%v:%v' SA to a pod, it violates %v", [ns, sa, concat(", ", forbiddenSA.policies)])
+}
+
+getPodSpec(obj) = spec {
+  obj.kind == "Pod"
+  spec := obj.spec
+} {
+  obj.kind == "CronJob"
+  spec := obj.spec.jobTemplate.spec.template.spec
+} {
+  podControllers := {"ReplicaSet", "ReplicationController", "Deployment", "StatefulSet", "DaemonSet", "Job"}
+  podControllers[obj.kind]
+  spec := obj.spec.template.spec
+}
+`
+
+// Rego of the template alerting on flagged identities querying their permissions,
+// based on docs/prevent/suspicious_self_subject_review
+const selfSubjectReviewRego = `package rbacpoliceselfsubjectreview
+
+violation[{"msg": msg}] {
+  isSelfReview(input.review.object.kind)
+  username := object.get(input.review.userInfo, "username", "")
+  identity := input.parameters.identities[_]
+  username == identity.username
+  msg := sprintf("'%v', which violates %v, issued a suspicious %v request, querying its permissions", [username, concat(", ", identity.policies), input.review.object.kind])
+}
+
+isSelfReview(kind) {
+  kind == "SelfSubjectAccessReview"
+} {
+  kind == "SelfSubjectRulesReview"
+}
+`
+
+// Kinds of pods and pod controllers
+var podKinds = []map[string]interface{}{{
+	"apiGroups": []string{"", "apps", "batch"},
+	"kinds":     []string{"Pod", "CronJob", "ReplicaSet", "ReplicationController", "Deployment", "StatefulSet", "DaemonSet", "Job"},
+}}
+
+// Returns a ConstraintTemplate named after @kind, running @rego with parameters described by @parametersSchema
+func constraintTemplate(kind string, rego string, parametersSchema apiextensionsv1.JSONSchemaProps) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "templates.gatekeeper.sh/v1",
+		"kind":       "ConstraintTemplate",
+		"metadata":   map[string]interface{}{"name": strings.ToLower(kind)},
+		"spec": map[string]interface{}{
+			"crd": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"names":      map[string]interface{}{"kind": kind},
+					"validation": map[string]interface{}{"openAPIV3Schema": parametersSchema},
+				},
+			},
+			"targets": []map[string]interface{}{{"target": gatekeeperTarget, "rego": rego}},
+		},
+	}
+}
+
+// Returns a constraint of @kind named @name
+func constraint(kind string, name string, action string, match map[string]interface{}, parameters map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "constraints.gatekeeper.sh/v1beta1",
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name},
+		"spec": map[string]interface{}{
+			"enforcementAction": action,
+			"match":             match,
+			"parameters":        parameters,
+		},
+	}
+}
+
+// Returns the schema of a parameter holding a list of objects with string @stringProperties and a string list @listProperty
+func listOfObjectsSchema(stringProperties []string, listProperty string) apiextensionsv1.JSONSchemaProps {
+	properties := map[string]apiextensionsv1.JSONSchemaProps{
+		listProperty: {Type: "array", Items: &apiextensionsv1.JSONSchemaPropsOrArray{Schema: &apiextensionsv1.JSONSchemaProps{Type: "string"}}},
+	}
+	for _, property := range stringProperties {
+		properties[property] = apiextensionsv1.JSONSchemaProps{Type: "string"}
+	}
+	return apiextensionsv1.JSONSchemaProps{
+		Type:  "array",
+		Items: &apiextensionsv1.JSONSchemaPropsOrArray{Schema: &apiextensionsv1.JSONSchemaProps{Type: "object", Properties: properties}},
+	}
+}
+
+// Gatekeeper template and constraints denying assignments of the admin-equivalent serviceAccounts @identities
+func assignPowerfulSAsGatekeeper(identities []TargetedIdentity) ([]manifest, error) {
+	kind := "RbacPoliceAssignPowerfulSA"
+	parametersSchema := apiextensionsv1.JSONSchemaProps{
+		Type: "object",
+		Properties: map[string]apiextensionsv1.JSONSchemaProps{
+			"serviceAccounts": listOfObjectsSchema([]string{"name", "namespace"}, "policies"),
+		},
+	}
+	templateSchema, err := loadSchema(gatekeeperTemplateCRD, "v1")
+	if err != nil {
+		return nil, err // error printed in loadSchema
+	}
+	constraintSchema, err := loadConstraintSchema(parametersSchema)
+	if err != nil {
+		return nil, err // error printed in loadConstraintSchema
+	}
+
+	manifests := []manifest{{
+		Path:     "gatekeeper/templates/" + strings.ToLower(kind) + ".yaml",
+		Manifest: constraintTemplate(kind, assignPowerfulSARego, parametersSchema),
+		Schema:   templateSchema,
+	}}
+	for _, action := range []string{actionDeny, actionWarn} {
+		targeted := withAction(identities, action)
+		if len(targeted) == 0 {
+			continue
+		}
+		var serviceAccounts []map[string]interface{}
+		for _, identity := range targeted {
+			serviceAccounts = append(serviceAccounts, map[string]interface{}{
+				"name":      strings.TrimPrefix(identity.Name, identity.Namespace+":"),
+				"namespace": identity.Namespace,
+				"policies":  identity.Policies,
+			})
+		}
+		name := "rbac-police-assign-powerful-sa-" + action
+		manifests = append(manifests, manifest{
+			Path: "gatekeeper/constraints/" + name + ".yaml",
+			Manifest: constraint(kind, name, action,
+				map[string]interface{}{"kinds": podKinds, "namespaces": namespacesOf(targeted)},
+				map[string]interface{}{"serviceAccounts": serviceAccounts}),
+			Schema: constraintSchema,
+		})
+	}
+	return manifests, nil
+}
+
+// Gatekeeper template and constraint warning on the flagged @identities querying their permissions
+func selfSubjectReviewsGatekeeper(identities []TargetedIdentity) ([]manifest, error) {
+	kind := "RbacPoliceSelfSubjectReview"
+	parametersSchema := apiextensionsv1.JSONSchemaProps{
+		Type: "object",
+		Properties: map[string]apiextensionsv1.JSONSchemaProps{
+			"identities": listOfObjectsSchema([]string{"username"}, "policies"),
+		},
+	}
+	templateSchema, err := loadSchema(gatekeeperTemplateCRD, "v1")
+	if err != nil {
+		return nil, err // error printed in loadSchema
+	}
+	constraintSchema, err := loadConstraintSchema(parametersSchema)
+	if err != nil {
+		return nil, err // error printed in loadConstraintSchema
+	}
+
+	var parameters []map[string]interface{}
+	for _, identity := range identities {
+		parameters = append(parameters, map[string]interface{}{"username": identity.Name, "policies": identity.Policies})
+	}
+	name := "rbac-police-self-subject-review"
+	return []manifest{
+		{
+			Path:     "gatekeeper/templates/" + strings.ToLower(kind) + ".yaml",
+			Manifest: constraintTemplate(kind, selfSubjectReviewRego, parametersSchema),
+			Schema:   templateSchema,
+		},
+		{
+			Path: "gatekeeper/constraints/" + name + ".yaml",
+			Manifest: constraint(kind, name, actionWarn,
+				map[string]interface{}{"kinds": []map[string]interface{}{{
+					"apiGroups": []string{"authorization.k8s.io"},
+					"kinds":     []string{"SelfSubjectAccessReview", "SelfSubjectRulesReview"},
+				}}},
+				map[string]interface{}{"identities": parameters}),
+			Schema: constraintSchema,
+		},
+	}, nil
+}
@@ -0,0 +1,118 @@
+package prevent
+
+import (
+	"strings"
+)
+
+// Returns a ClusterPolicy named @name holding @rule, enforced or audited per @action
+func clusterPolicy(name string, title string, description string, action string, rule map[string]interface{}) map[string]interface{} {
+	validationFailureAction := "enforce"
+	if action != actionDeny {
+		validationFailureAction = "audit"
+	}
+	return map[string]interface{}{
+		"apiVersion": "kyverno.io/v1",
+		"kind":       "ClusterPolicy",
+		"metadata": map[string]interface{}{
+			"name": name,
+			"annotations": map[string]interface{}{
+				"policies.kyverno.io/title":       title,
+				"policies.kyverno.io/description": description,
+			},
+		},
+		"spec": map[string]interface{}{
+			"validationFailureAction": validationFailureAction,
+			"background":              false,
+			"rules":                   []map[string]interface{}{rule},
+		},
+	}
+}
+
+// Kyverno ClusterPolicies denying assignments of the admin-equivalent serviceAccounts @identities.
+// The rules match pods, Kyverno auto-generates the matching rules for pod controllers.
+func assignPowerfulSAsKyverno(identities []TargetedIdentity) ([]manifest, error) {
+	clusterPolicySchema, err := loadSchema(kyvernoPolicyCRD, "v1")
+	if err != nil {
+		return nil, err // error printed in loadSchema
+	}
+
+	var manifests []manifest
+	for _, action := range []string{actionDeny, actionWarn} {
+		targeted := withAction(identities, action)
+		if len(targeted) == 0 {
+			continue
+		}
+		var serviceAccounts []string
+		for _, identity := range targeted {
+			serviceAccounts = append(serviceAccounts, identity.Name)
+		}
+		name := "rbac-police-assign-powerful-sa-" + action
+		rule := map[string]interface{}{
+			"name": "admin-equivalent-serviceaccounts",
+			"match": map[string]interface{}{
+				"any": []map[string]interface{}{{
+					"resources": map[string]interface{}{"kinds": []string{"Pod"}, "namespaces": namespacesOf(targeted)},
+				}},
+			},
+			"preconditions": map[string]interface{}{
+				"any": []map[string]interface{}{{
+					"key":      "{{ request.operation }}",
+					"operator": "AnyIn",
+					"value":    []string{"CREATE", "UPDATE"},
+				}},
+			},
+			"validate": map[string]interface{}{
+				"message": "Assigning the admin-equivalent serviceAccount {{ request.namespace }}:{{ request.object.spec.serviceAccountName || 'default' }} to pods is forbidden, see rbac-police eval results",
+				"deny": map[string]interface{}{
+					"conditions": map[string]interface{}{
+						"any": []map[string]interface{}{{
+							"key":      "{{ request.namespace }}:{{ request.object.spec.serviceAccountName || 'default' }}",
+							"operator": "AnyIn",
+							"value":    serviceAccounts,
+						}},
+					},
+				},
+			},
+		}
+		manifests = append(manifests, manifest{
+			Path: "kyverno/" + name + ".yaml",
+			Manifest: clusterPolicy(name, "Assign admin-equivalent serviceAccounts",
+				"Forbids assigning serviceAccounts that rbac-police flagged as admin-equivalent ("+strings.Join(serviceAccounts, ", ")+") to pods", action, rule),
+			Schema: clusterPolicySchema,
+		})
+	}
+	return manifests, nil
+}
+
+// Kyverno ClusterPolicy auditing the flagged @identities querying their permissions
+func selfSubjectReviewsKyverno(identities []TargetedIdentity) ([]manifest, error) {
+	clusterPolicySchema, err := loadSchema(kyvernoPolicyCRD, "v1")
+	if err != nil {
+		return nil, err // error printed in loadSchema
+	}
+
+	var subjects []map[string]interface{}
+	for _, identity := range identities {
+		subjects = append(subjects, map[string]interface{}{"kind": "User", "name": identity.Name})
+	}
+	name := "rbac-police-self-subject-review"
+	rule := map[string]interface{}{
+		"name": "flagged-identities-querying-permissions",
+		"match": map[string]interface{}{
+			"any": []map[string]interface{}{{
+				"resources": map[string]interface{}{"kinds": []string{"SelfSubjectAccessReview", "SelfSubjectRulesReview"}},
+				"subjects":  subjects,
+			}},
+		},
+		"validate": map[string]interface{}{
+			"message": "{{ request.userInfo.username }}, which rbac-police flagged for risky permissions, issued a suspicious {{ request.kind.kind }} request, querying its permissions",
+			"deny":    map[string]interface{}{},
+		},
+	}
+	return []manifest{{
+		Path: "kyverno/" + name + ".yaml",
+		Manifest: clusterPolicy(name, "Flagged identities querying their permissions",
+			"Alerts when serviceAccounts and nodes that rbac-police flagged for risky permissions query their permissions, which may indicate their credentials were stolen", actionWarn, rule),
+		Schema: clusterPolicySchema,
+	}}, nil
+}
@@ -0,0 +1,278 @@
+package prevent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+const manifestHeader = `# Generated by rbac-police prevent from eval results, review before applying.
+`
+
+// Prevent generates admission policies tailored to the violations in @policyResults, found by evaluating @collectResult,
+// for the admission controllers in preventConfig.Engines. The policies are validated against the schemas of their CRDs
+// and written as YAML files to preventConfig.OutDir.
+func Prevent(policyResults eval.PolicyResults, collectResult collect.CollectResult, preventConfig PreventConfig) *PreventResult {
+	result := PreventResult{Preventions: []Prevention{}}
+	generators := []func(eval.PolicyResults, collect.CollectResult, []string) (*Prevention, []manifest, error){
+		assignPowerfulSAs,
+		selfSubjectReviews,
+	}
+	for _, generate := range generators {
+		prevention, manifests, err := generate(policyResults, collectResult, preventConfig.Engines)
+		if err != nil {
+			return nil // error printed in generate
+		}
+		if prevention == nil {
+			continue // no violations to prevent
+		}
+		for _, m := range manifests {
+			if err = validateManifest(m); err != nil {
+				return nil // error printed in validateManifest
+			}
+		}
+		if err = writeManifests(prevention, manifests, preventConfig.OutDir); err != nil {
+			return nil // error printed in writeManifests
+		}
+		result.Preventions = append(result.Preventions, *prevention)
+	}
+	return &result
+}
+
+// Prevents assigning admin-equivalent serviceAccounts to new pods, if identities can assign serviceAccounts per assign_sa.
+// ServiceAccounts that violate critical policies are considered admin-equivalent. Assignments of those already assigned
+// to pods only raise warnings, as denying them would break their workloads.
+func assignPowerfulSAs(policyResults eval.PolicyResults, collectResult collect.CollectResult, engines []string) (*Prevention, []manifest, error) {
+	var assignSA *eval.PolicyResult
+	for i, policyResult := range policyResults.PolicyResults {
+		if policyID(policyResult) == assignSAPolicyID || filepath.Base(policyResult.PolicyFile) == assignSAPolicyFile {
+			assignSA = &policyResults.PolicyResults[i]
+		}
+	}
+	if assignSA == nil {
+		return nil, nil, nil
+	}
+
+	targets := make(map[string]*TargetedIdentity)
+	for _, policyResult := range policyResults.PolicyResults {
+		if policyResult.Severity != criticalSeverity {
+			continue
+		}
+		for _, sa := range policyResult.Violations.ServiceAccounts {
+			addTarget(targets, utils.FullName(sa.Namespace, sa.Name), sa.Namespace, policyID(policyResult))
+		}
+		for _, combined := range policyResult.Violations.Combined {
+			for _, saFullName := range combined.ServiceAccounts {
+				addTarget(targets, saFullName, strings.SplitN(saFullName, ":", 2)[0], policyID(policyResult))
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return nil, nil, nil
+	}
+	identities := sortedTargets(targets)
+	for i := range identities {
+		identities[i].Action = actionDeny
+		if serviceAccountHostsPods(collectResult, identities[i].Name) {
+			identities[i].Action = actionWarn
+		}
+	}
+
+	prevention := Prevention{
+		Name:        "assign-powerful-sa",
+		Description: fmt.Sprintf("%v identities can assign serviceAccounts to pods in privileged namespaces (%v). Denies assigning the admin-equivalent serviceAccounts, those violating critical policies, to new pods and pod controllers. Only warns on serviceAccounts already assigned to pods, as denying them would break their workloads.", countViolations(assignSA.Violations), assignSAPolicyID),
+		Policies:    []string{policyID(*assignSA)},
+		Identities:  identities,
+	}
+	var manifests []manifest
+	if utils.Contains(engines, EngineGatekeeper) {
+		gatekeeperManifests, err := assignPowerfulSAsGatekeeper(identities)
+		if err != nil {
+			return nil, nil, err
+		}
+		manifests = append(manifests, gatekeeperManifests...)
+	}
+	if utils.Contains(engines, EngineKyverno) {
+		kyvernoManifests, err := assignPowerfulSAsKyverno(identities)
+		if err != nil {
+			return nil, nil, err
+		}
+		manifests = append(manifests, kyvernoManifests...)
+	}
+	return &prevention, manifests, nil
+}
+
+// Alerts when flagged serviceAccounts and nodes query their permissions via SelfSubjectAccessReviews or SelfSubjectRulesReviews.
+// Non-human identities seldom query their own permissions, and attackers often do so after stealing credentials.
+func selfSubjectReviews(policyResults eval.PolicyResults, collectResult collect.CollectResult, engines []string) (*Prevention, []manifest, error) {
+	targets := make(map[string]*TargetedIdentity)
+	var policies []string
+	for _, policyResult := range policyResults.PolicyResults {
+		id := policyID(policyResult)
+		violations := policyResult.Violations
+		for _, sa := range violations.ServiceAccounts {
+			addTarget(targets, "system:serviceaccount:"+utils.FullName(sa.Namespace, sa.Name), "", id)
+		}
+		for _, node := range violations.Nodes {
+			addTarget(targets, "system:node:"+node, "", id)
+		}
+		for _, combined := range violations.Combined {
+			addTarget(targets, "system:node:"+combined.Node, "", id)
+			for _, saFullName := range combined.ServiceAccounts {
+				addTarget(targets, "system:serviceaccount:"+saFullName, "", id)
+			}
+		}
+		if len(violations.ServiceAccounts) > 0 || len(violations.Nodes) > 0 || len(violations.Combined) > 0 {
+			policies = append(policies, id)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, nil, nil
+	}
+	identities := sortedTargets(targets)
+
+	prevention := Prevention{
+		Name:        "self-subject-review",
+		Description: fmt.Sprintf("Alerts when the %d serviceAccounts and nodes flagged by eval query their permissions via SelfSubjectAccessReviews or SelfSubjectRulesReviews, which may indicate their credentials were stolen.", len(identities)),
+		Policies:    policies,
+		Identities:  identities,
+	}
+	var manifests []manifest
+	if utils.Contains(engines, EngineGatekeeper) {
+		gatekeeperManifests, err := selfSubjectReviewsGatekeeper(identities)
+		if err != nil {
+			return nil, nil, err
+		}
+		manifests = append(manifests, gatekeeperManifests...)
+	}
+	if utils.Contains(engines, EngineKyverno) {
+		kyvernoManifests, err := selfSubjectReviewsKyverno(identities)
+		if err != nil {
+			return nil, nil, err
+		}
+		manifests = append(manifests, kyvernoManifests...)
+	}
+	return &prevention, manifests, nil
+}
+
+// Returns the ID of @policyResult, or its file name if it has no ID
+func policyID(policyResult eval.PolicyResult) string {
+	if policyResult.ID != "" {
+		return policyResult.ID
+	}
+	return strings.TrimSuffix(filepath.Base(policyResult.PolicyFile), ".rego")
+}
+
+// Adds the identity @name in @namespace to @targets, as violating the policy @id
+func addTarget(targets map[string]*TargetedIdentity, name string, namespace string, id string) {
+	target, ok := targets[name]
+	if !ok {
+		target = &TargetedIdentity{Name: name, Namespace: namespace}
+		targets[name] = target
+	}
+	if !utils.Contains(target.Policies, id) {
+		target.Policies = append(target.Policies, id)
+	}
+}
+
+// Returns the identities in @targets sorted by name
+func sortedTargets(targets map[string]*TargetedIdentity) []TargetedIdentity {
+	var identities []TargetedIdentity
+	for _, target := range targets {
+		identities = append(identities, *target)
+	}
+	sort.Slice(identities, func(i, j int) bool { return identities[i].Name < identities[j].Name })
+	return identities
+}
+
+// Returns whether the serviceAccount @saFullName is assigned to pods per @collectResult
+func serviceAccountHostsPods(collectResult collect.CollectResult, saFullName string) bool {
+	for _, sa := range collectResult.ServiceAccounts {
+		if utils.FullName(sa.Namespace, sa.Name) == saFullName {
+			return len(sa.Nodes) > 0
+		}
+	}
+	return false
+}
+
+// Returns the number of identities in @violations
+func countViolations(violations eval.Violations) int {
+	return len(violations.ServiceAccounts) + len(violations.Nodes) + len(violations.Combined) + len(violations.Users) + len(violations.Groups)
+}
+
+// Returns the sorted namespaces of @identities
+func namespacesOf(identities []TargetedIdentity) []string {
+	var namespaces []string
+	for _, identity := range identities {
+		if !utils.Contains(namespaces, identity.Namespace) {
+			namespaces = append(namespaces, identity.Namespace)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// Returns the @identities whose action is @action
+func withAction(identities []TargetedIdentity, action string) []TargetedIdentity {
+	var filtered []TargetedIdentity
+	for _, identity := range identities {
+		if identity.Action == action {
+			filtered = append(filtered, identity)
+		}
+	}
+	return filtered
+}
+
+// Writes @manifests to their files under @outDir, and records the files in @prevention
+func writeManifests(prevention *Prevention, manifests []manifest, outDir string) error {
+	for _, m := range manifests {
+		filePath := filepath.Join(outDir, m.Path)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			log.Errorf("writeManifests: failed to create %v with %v\n", filepath.Dir(filePath), err)
+			return err
+		}
+		manifestBytes, err := yaml.Marshal(m.Manifest)
+		if err != nil {
+			log.Errorf("writeManifests: failed to marshal %v with %v\n", m.Path, err)
+			return err
+		}
+		if err = os.WriteFile(filePath, append([]byte(manifestHeader), manifestBytes...), 0644); err != nil {
+			log.Errorf("writeManifests: failed to write %v with %v\n", filePath, err)
+			return err
+		}
+		prevention.Files = append(prevention.Files, filePath)
+	}
+	return nil
+}
+
+// FormatResult formats @result as human-readable text
+func FormatResult(result *PreventResult) string {
+	var out strings.Builder
+	if len(result.Preventions) == 0 {
+		return "No violations that admission policies can prevent\n"
+	}
+	for i, prevention := range result.Preventions {
+		out.WriteString(fmt.Sprintf("[%d] %v (%v)\n", i+1, prevention.Name, strings.Join(prevention.Policies, ", ")))
+		out.WriteString(fmt.Sprintf("  %v\n", prevention.Description))
+		for _, identity := range prevention.Identities {
+			action := ""
+			if identity.Action != "" {
+				action = fmt.Sprintf(" [%v]", identity.Action)
+			}
+			out.WriteString(fmt.Sprintf("  - %v%v: %v\n", identity.Name, action, strings.Join(identity.Policies, ", ")))
+		}
+		for _, file := range prevention.Files {
+			out.WriteString(fmt.Sprintf("  Policy: %v\n", file))
+		}
+		out.WriteString("\n")
+	}
+	return out.String()
+}
@@ -0,0 +1,55 @@
+package prevent
+
+// PreventConfig holds the options for Prevent()
+type PreventConfig struct {
+	OutDir  string
+	Engines []string // admission controllers to generate policies for, 'gatekeeper' and / or 'kyverno'
+}
+
+// Result of Prevent()
+type PreventResult struct {
+	Preventions []Prevention `json:"preventions"`
+}
+
+// Admission policies generated against an attack path found by eval
+type Prevention struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Policies    []string           `json:"policies"` // IDs of the eval policies whose violations the admission policies are tailored to
+	Identities  []TargetedIdentity `json:"identities"`
+	Files       []string           `json:"files,omitempty"` // files holding the admission policies
+}
+
+// An identity the admission policies of a prevention target
+type TargetedIdentity struct {
+	Name      string   `json:"name"` // full name for serviceAccounts, username for identities that make requests
+	Namespace string   `json:"namespace,omitempty"`
+	Policies  []string `json:"policies"`         // IDs of the eval policies the identity violates
+	Action    string   `json:"action,omitempty"` // enforcement action, if it differs between identities
+}
+
+// A generated admission policy
+type manifest struct {
+	Path     string // file path under the output directory
+	Manifest map[string]interface{}
+	Schema   *schema // schema the manifest is validated against
+}
+
+const (
+	EngineGatekeeper = "gatekeeper"
+	EngineKyverno    = "kyverno"
+
+	actionDeny = "deny"
+	actionWarn = "warn"
+
+	assignSAPolicyID   = "RP-002"
+	assignSAPolicyFile = "assign_sa.rego"
+	criticalSeverity   = "Critical"
+
+	gatekeeperTarget = "admission.k8s.gatekeeper.sh"
+
+	// Upstream CRDs under schemas/, pinned to the Gatekeeper and Kyverno releases policies are validated against
+	gatekeeperTemplateCRD = "gatekeeper-v3.17.0/constrainttemplate-customresourcedefinition.yaml"
+	gatekeeperMatchCRD    = "gatekeeper-v3.17.0/match.gatekeeper.sh_matchcrd.yaml"
+	kyvernoPolicyCRD      = "kyverno-v1.13.4/kyverno.io_clusterpolicies.yaml"
+)
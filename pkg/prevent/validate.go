@@ -0,0 +1,193 @@
+package prevent
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/open-policy-agent/opa/ast"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	structuralschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/schema/pruning"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// The upstream CRDs of the generated admission policies, for validating them offline.
+// See schemas/README.md for their sources
+//
+//go:embed schemas
+var schemaFiles embed.FS
+
+// The OpenAPI schema of a CRD version
+type schema struct {
+	Name       string
+	APIVersion string // group/version of the objects the schema describes
+	Props      apiextensionsv1.JSONSchemaProps
+}
+
+// Loads the openAPIV3Schema of @version out of the embedded CRD @fileName
+func loadSchema(fileName string, version string) (*schema, error) {
+	crdBytes, err := schemaFiles.ReadFile("schemas/" + fileName)
+	if err != nil {
+		log.Errorf("loadSchema: failed to read CRD %v with %v\n", fileName, err)
+		return nil, err
+	}
+	var crd apiextensionsv1.CustomResourceDefinition
+	if err = yaml.Unmarshal(crdBytes, &crd); err != nil {
+		log.Errorf("loadSchema: failed to unmarshal CRD %v with %v\n", fileName, err)
+		return nil, err
+	}
+	for _, crdVersion := range crd.Spec.Versions {
+		if crdVersion.Name != version || !crdVersion.Served {
+			continue
+		}
+		if crdVersion.Schema == nil || crdVersion.Schema.OpenAPIV3Schema == nil {
+			break
+		}
+		return &schema{
+			Name:       fileName + " " + version,
+			APIVersion: crd.Spec.Group + "/" + version,
+			Props:      *crdVersion.Schema.OpenAPIV3Schema,
+		}, nil
+	}
+	err = fmt.Errorf("no schema for version %v", version)
+	log.Errorf("loadSchema: failed to load CRD %v with %v\n", fileName, err)
+	return nil, err
+}
+
+// Loads the schema of constraints whose template describes their parameters with @parametersSchema.
+// Constraint CRDs are generated by Gatekeeper from templates, so the schema is assembled like Gatekeeper
+// does, out of the match schema of its embedded match CRD
+func loadConstraintSchema(parametersSchema apiextensionsv1.JSONSchemaProps) (*schema, error) {
+	matchCRDSchema, err := loadSchema(gatekeeperMatchCRD, "match")
+	if err != nil {
+		return nil, err // error printed in loadSchema
+	}
+	defaultEnforcementAction := apiextensionsv1.JSON{Raw: []byte(`"deny"`)}
+	metadataNameMaxLength := int64(63)
+	preserveUnknownFields := true
+	stringSchema := apiextensionsv1.JSONSchemaProps{Type: "string"}
+	return &schema{
+		Name:       "constraint generated from " + gatekeeperMatchCRD,
+		APIVersion: "constraints.gatekeeper.sh/v1beta1",
+		Props: apiextensionsv1.JSONSchemaProps{
+			Type: "object",
+			Properties: map[string]apiextensionsv1.JSONSchemaProps{
+				"metadata": {
+					Type:       "object",
+					Properties: map[string]apiextensionsv1.JSONSchemaProps{"name": {Type: "string", MaxLength: &metadataNameMaxLength}},
+				},
+				"spec": {
+					Type: "object",
+					Properties: map[string]apiextensionsv1.JSONSchemaProps{
+						"match":             matchCRDSchema.Props.Properties["embeddedMatch"],
+						"enforcementAction": {Type: "string", Default: &defaultEnforcementAction},
+						"scopedEnforcementActions": {
+							Type: "array",
+							Items: &apiextensionsv1.JSONSchemaPropsOrArray{Schema: &apiextensionsv1.JSONSchemaProps{
+								Type: "object",
+								Properties: map[string]apiextensionsv1.JSONSchemaProps{
+									"action": stringSchema,
+									"enforcementPoints": {
+										Type: "array",
+										Items: &apiextensionsv1.JSONSchemaPropsOrArray{Schema: &apiextensionsv1.JSONSchemaProps{
+											Type:       "object",
+											Properties: map[string]apiextensionsv1.JSONSchemaProps{"name": stringSchema},
+										}},
+									},
+								},
+							}},
+						},
+						"parameters": parametersSchema,
+					},
+				},
+				"status": {XPreserveUnknownFields: &preserveUnknownFields},
+			},
+		},
+	}, nil
+}
+
+// Validates @m against its schema as the API server would, rejecting unknown fields.
+// The Rego of ConstraintTemplates is compiled as well.
+func validateManifest(m manifest) error {
+	if apiVersion := m.Manifest["apiVersion"]; apiVersion != m.Schema.APIVersion {
+		err := fmt.Errorf("apiVersion %v isn't served by the CRD, expected %v", apiVersion, m.Schema.APIVersion)
+		log.Errorf("validateManifest: %v is invalid per schema %v: %v\n", m.Path, m.Schema.Name, err)
+		return err
+	}
+	var internalSchema apiextensions.JSONSchemaProps
+	if err := apiextensionsv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(&m.Schema.Props, &internalSchema, nil); err != nil {
+		log.Errorf("validateManifest: failed to convert schema %v with %v\n", m.Schema.Name, err)
+		return err
+	}
+	validator, _, err := validation.NewSchemaValidator(&apiextensions.CustomResourceValidation{OpenAPIV3Schema: &internalSchema})
+	if err != nil {
+		log.Errorf("validateManifest: failed to create a validator for schema %v with %v\n", m.Schema.Name, err)
+		return err
+	}
+	structural, err := structuralschema.NewStructural(&internalSchema)
+	if err != nil {
+		log.Errorf("validateManifest: failed to create a structural schema from %v with %v\n", m.Schema.Name, err)
+		return err
+	}
+
+	// Validate the manifest as unmarshaled from JSON, like the API server does
+	manifestBytes, err := json.Marshal(m.Manifest)
+	if err != nil {
+		log.Errorf("validateManifest: failed to marshal %v with %v\n", m.Path, err)
+		return err
+	}
+	var obj interface{}
+	if err = json.Unmarshal(manifestBytes, &obj); err != nil {
+		log.Errorf("validateManifest: failed to unmarshal %v with %v\n", m.Path, err)
+		return err
+	}
+	if errs := validation.ValidateCustomResource(nil, obj, validator); len(errs) > 0 {
+		err = errs.ToAggregate()
+		log.Errorf("validateManifest: %v is invalid per schema %v: %v\n", m.Path, m.Schema.Name, err)
+		return err
+	}
+	if pruned := pruning.PruneWithOptions(obj, structural, true, pruning.PruneOptions{ReturnPruned: true}); len(pruned) > 0 {
+		err = fmt.Errorf("unknown fields %v", strings.Join(pruned, ", "))
+		log.Errorf("validateManifest: %v is invalid per schema %v: %v\n", m.Path, m.Schema.Name, err)
+		return err
+	}
+
+	if m.Manifest["kind"] == "ConstraintTemplate" {
+		return validateTemplateRego(m)
+	}
+	return nil
+}
+
+// Compiles the Rego of the ConstraintTemplate @m, and verifies it defines violations
+func validateTemplateRego(m manifest) error {
+	targets := m.Manifest["spec"].(map[string]interface{})["targets"].([]map[string]interface{})
+	for _, target := range targets {
+		module, err := ast.ParseModule(m.Path, target["rego"].(string))
+		if err != nil {
+			log.Errorf("validateTemplateRego: failed to parse the Rego of %v with %v\n", m.Path, err)
+			return err
+		}
+		compiler := ast.NewCompiler()
+		if compiler.Compile(map[string]*ast.Module{m.Path: module}); compiler.Failed() {
+			log.Errorf("validateTemplateRego: failed to compile the Rego of %v with %v\n", m.Path, compiler.Errors)
+			return compiler.Errors
+		}
+		definesViolation := false
+		for _, rule := range module.Rules {
+			if rule.Head.Name.String() == "violation" {
+				definesViolation = true
+			}
+		}
+		if !definesViolation {
+			err = fmt.Errorf("no violation rule")
+			log.Errorf("validateTemplateRego: the Rego of %v is invalid, %v\n", m.Path, err)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package remediate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/attribute"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	log "github.com/sirupsen/logrus"
+	rbac "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const manifestHeader = `# Generated by rbac-police remediate, review before applying.
+# Patched roles replace the entire rule list, and roles aggregated via an aggregationRule are
+# overwritten by the controller, patch the aggregated roles instead.
+`
+
+// Returns a manifest of @role with its current rules
+func roleManifest(role collect.RoleEntry) string {
+	kind := "ClusterRole"
+	metadata := map[string]interface{}{"name": role.Name}
+	if role.Namespace != "" {
+		kind = "Role"
+		metadata["namespace"] = role.Namespace
+	}
+	return marshalManifest(map[string]interface{}{
+		"apiVersion": rbac.SchemeGroupVersion.String(),
+		"kind":       kind,
+		"metadata":   metadata,
+		"rules":      role.Rules,
+	})
+}
+
+// Returns a manifest of a roleBinding granting @clusterRole to serviceAccount @saName in @namespace,
+// preceded by instructions for removing the serviceAccount from the clusterRoleBindings of @clusterRole
+func roleBindingManifest(clusterRole string, namespace string, saName string) string {
+	instructions := fmt.Sprintf("# Remove serviceAccount %v:%v from the subjects of the ClusterRoleBindings that bind ClusterRole %v,\n# and grant it in namespace %v instead:\n", namespace, saName, clusterRole, namespace)
+	return instructions + marshalManifest(map[string]interface{}{
+		"apiVersion": rbac.SchemeGroupVersion.String(),
+		"kind":       "RoleBinding",
+		"metadata": map[string]interface{}{
+			"name":      strings.ReplaceAll(clusterRole, ":", "-") + "-" + saName,
+			"namespace": namespace,
+		},
+		"roleRef": map[string]interface{}{
+			"apiGroup": rbac.GroupName,
+			"kind":     "ClusterRole",
+			"name":     clusterRole,
+		},
+		"subjects": []map[string]interface{}{{
+			"kind":      "ServiceAccount",
+			"name":      saName,
+			"namespace": namespace,
+		}},
+	})
+}
+
+// Returns instructions for removing @group from the bindings granting the role of @roleRef.
+// CollectResult doesn't record bindings, so there's no manifest to patch.
+func dropSubjectManifest(group string, roleRef collect.RoleRef) string {
+	bindingKind := "ClusterRoleBindings"
+	if roleRef.EffectiveNamespace != "" {
+		bindingKind = "RoleBindings in namespace " + roleRef.EffectiveNamespace
+	}
+	return fmt.Sprintf("# Remove the following subject from the %v that bind %v:\n#   - apiGroup: %v\n#     kind: Group\n#     name: %v\n",
+		bindingKind, describeRole(collect.RoleEntry{Name: roleRef.Name, Namespace: roleRef.Namespace}), rbac.GroupName, group)
+}
+
+// Marshals @manifest into YAML
+func marshalManifest(manifest map[string]interface{}) string {
+	manifestBytes, err := yaml.Marshal(manifest)
+	if err != nil {
+		log.Errorf("marshalManifest: failed to marshal manifest with %v\n", err)
+		return ""
+	}
+	return string(manifestBytes)
+}
+
+// Returns a line diff between the YAML of @oldRules and @newRules
+func rulesDiff(oldRules []rbac.PolicyRule, newRules []rbac.PolicyRule) string {
+	oldBytes, _ := yaml.Marshal(oldRules)
+	newBytes, _ := yaml.Marshal(newRules)
+	return lineDiff(strings.Split(strings.TrimSuffix(string(oldBytes), "\n"), "\n"), strings.Split(strings.TrimSuffix(string(newBytes), "\n"), "\n"))
+}
+
+// Returns a diff of @oldLines and @newLines based on their longest common subsequence,
+// removed lines are prefixed with '- ', added lines with '+ ' and unchanged lines with '  '
+func lineDiff(oldLines []string, newLines []string) string {
+	lcs := make([][]int, len(oldLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff strings.Builder
+	i, j := 0, 0
+	for i < len(oldLines) || j < len(newLines) {
+		if i < len(oldLines) && j < len(newLines) && oldLines[i] == newLines[j] {
+			diff.WriteString("  " + oldLines[i] + "\n")
+			i++
+			j++
+		} else if j < len(newLines) && (i == len(oldLines) || lcs[i][j+1] >= lcs[i+1][j]) {
+			diff.WriteString("+ " + newLines[j] + "\n")
+			j++
+		} else {
+			diff.WriteString("- " + oldLines[i] + "\n")
+			i++
+		}
+	}
+	return diff.String()
+}
+
+// Writes the manifests of each remediation into a file under @outDir
+func writeManifests(result *RemediateResult, outDir string) error {
+	err := os.MkdirAll(outDir, 0755)
+	if err != nil {
+		log.Errorf("writeManifests: failed to create %v with %v\n", outDir, err)
+		return err
+	}
+	for i := range result.Remediations {
+		remediation := &result.Remediations[i]
+		if len(remediation.Patches) == 0 {
+			continue
+		}
+		var manifests []string
+		for _, patch := range remediation.Patches {
+			manifests = append(manifests, fmt.Sprintf("# %v\n%v", patch.Description, patch.Manifest))
+		}
+		fileName := fmt.Sprintf("%02d-%v-%v.yaml", i+1, remediation.PolicyID, fileNameSafe(remediation.Identity.Type+"-"+attribute.IdentityName(remediation.Identity)))
+		filePath := filepath.Join(outDir, fileName)
+		err = os.WriteFile(filePath, []byte(manifestHeader+"---\n"+strings.Join(manifests, "---\n")), 0644)
+		if err != nil {
+			log.Errorf("writeManifests: failed to write %v with %v\n", filePath, err)
+			return err
+		}
+		remediation.File = filePath
+	}
+	return nil
+}
+
+// Returns @name with characters that are unsafe in file names replaced
+func fileNameSafe(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '-'
+	}, name)
+}
+
+// Formats @result as a human-readable report, listing the patches and role diffs of each remediation
+func FormatResult(result *RemediateResult) string {
+	var out strings.Builder
+	for i, remediation := range result.Remediations {
+		out.WriteString(fmt.Sprintf("[%d] %v (%v): %v %v\n", i+1, remediation.PolicyID, remediation.PolicyFile, remediation.Identity.Type, attribute.IdentityName(remediation.Identity)))
+		for _, patch := range remediation.Patches {
+			out.WriteString(fmt.Sprintf("  - %v\n", patch.Description))
+			for _, line := range strings.Split(strings.TrimSuffix(patch.Diff, "\n"), "\n") {
+				if line != "" {
+					out.WriteString("      " + line + "\n")
+				}
+			}
+		}
+		for _, note := range remediation.Notes {
+			out.WriteString(fmt.Sprintf("  Note: %v\n", note))
+		}
+		if remediation.Resolved {
+			out.WriteString("  Verified: the patched cluster no longer violates the policy\n")
+		} else if remediation.Conditional {
+			out.WriteString(fmt.Sprintf("  Conditional: the patched cluster no longer violates the policy only if the names replacing '%v' don't, re-run eval once they're set\n", resourceNamePlaceholder))
+		} else {
+			out.WriteString("  Unresolved: the patched cluster still violates the policy\n")
+		}
+		if remediation.File != "" {
+			out.WriteString(fmt.Sprintf("  Patches: %v\n", remediation.File))
+		}
+		out.WriteString("\n")
+	}
+	summary := result.Summary
+	out.WriteString(fmt.Sprintf("Summary: %d violations, %d resolved, %d conditional, %d unresolved. Re-evaluating the violated policies against the patched cluster flags %d of the original violations and %d new ones.\n",
+		summary.Violations, summary.Resolved, summary.Conditional, summary.Unresolved, summary.Remaining, summary.Introduced))
+	return out.String()
+}
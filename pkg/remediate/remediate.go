@@ -0,0 +1,226 @@
+package remediate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/attribute"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// Max number of patches proposed for a single violation
+const maxPatchesPerViolation = 10
+
+// Holds the cluster's RBAC data, patched as remediations are proposed
+type remediator struct {
+	attribute.Attributor
+}
+
+// Proposes patches that resolve the violations in @policyResults, and proves them by
+// re-evaluating the violated policies against a patched copy of @collectResult.
+// Nothing is applied to the cluster, patches are only written as manifests to remediateConfig.OutDir.
+func Remediate(policyResults eval.PolicyResults, collectResult collect.CollectResult, remediateConfig RemediateConfig) *RemediateResult {
+	var result RemediateResult
+
+	evalConfig := remediateConfig.EvalConfig
+	evalConfig.SaViolations, evalConfig.NodeViolations, evalConfig.CombinedViolations = true, true, true
+	evalConfig.UserViolations, evalConfig.GroupViolations = true, true
+	evalConfig.SeverityThreshold = "Low"
+
+	patchedCollectResult, err := copyCollectResult(collectResult)
+	if err != nil {
+		return nil
+	}
+	r := remediator{attribute.Attributor{CollectResult: patchedCollectResult, EvalConfig: evalConfig}}
+
+	// Remediate violations of broad groups first, as dropping them from bindings
+	// is preferable to patching the roles they share with other identities
+	var violations, broadGroupViolations []violation
+	for _, policyResult := range policyResults.PolicyResults {
+		if _, err := os.Stat(policyResult.PolicyFile); err != nil {
+			log.Errorf("Remediate: cannot re-evaluate policy %v with %v, run remediate from the directory eval ran in\n", policyResult.PolicyFile, err)
+			return nil
+		}
+		for _, identity := range attribute.ViolatingIdentities(policyResult.Violations) {
+			if _, ok := broadGroups[identity.Name]; ok && identity.Type == "group" {
+				broadGroupViolations = append(broadGroupViolations, violation{policyResult, identity})
+			} else {
+				violations = append(violations, violation{policyResult, identity})
+			}
+		}
+	}
+
+	for _, v := range append(broadGroupViolations, violations...) {
+		remediation := r.remediate(v.PolicyResult, v.Identity)
+		result.Remediations = append(result.Remediations, remediation)
+		result.Summary.Violations += 1
+		if remediation.Resolved {
+			result.Summary.Resolved += 1
+		} else if remediation.Conditional {
+			result.Summary.Conditional += 1
+		} else {
+			result.Summary.Unresolved += 1
+		}
+	}
+
+	// Re-evaluate all violated policies against the patched cluster
+	result.Summary.Remaining, result.Summary.Introduced = r.compareViolations(policyResults, collectResult)
+
+	if remediateConfig.OutDir != "" {
+		if err := writeManifests(&result, remediateConfig.OutDir); err != nil {
+			return nil // error printed in writeManifests
+		}
+	}
+	return &result
+}
+
+// Proposes patches for the violation of @policyResult by @identity, applying them to r.CollectResult
+func (r *remediator) remediate(policyResult eval.PolicyResult, identity attribute.Identity) Remediation {
+	remediation := Remediation{
+		PolicyFile: policyResult.PolicyFile,
+		PolicyID:   policyResult.ID,
+		Identity:   identity,
+	}
+	log.Debugf("remediate: remediating %v for %v %v\n", policyResult.ID, identity.Type, attribute.IdentityName(identity))
+
+	for len(remediation.Patches) < maxPatchesPerViolation {
+		if !r.Violates(policyResult.PolicyFile, identity, r.Narrowed(identity)) {
+			break
+		}
+		slots := r.RuleSlots(identity)
+		culprits := r.MinimalViolatingSlots(policyResult.PolicyFile, identity, slots)
+		if len(culprits) == 0 {
+			remediation.Notes = append(remediation.Notes, "couldn't attribute the violation to specific rules, it may depend on more than the identity's permissions")
+			break
+		}
+		patch := r.fixSlot(policyResult.PolicyFile, identity, culprits, 0)
+		if patch == nil {
+			remediation.Notes = append(remediation.Notes, "couldn't find a patch that resolves the violation")
+			break
+		}
+		if note := r.sharedRoleNote(*patch, identity); note != "" {
+			remediation.Notes = append(remediation.Notes, note)
+		}
+		remediation.Patches = append(remediation.Patches, patch.Patch)
+	}
+
+	// Prove the violation is gone by re-evaluating the policy against the entire patched cluster
+	remediation.Resolved = !r.Violates(policyResult.PolicyFile, identity, r.CollectResult)
+	if remediation.Resolved && len(remediation.Patches) == 0 {
+		remediation.Notes = append(remediation.Notes, "resolved by the patches proposed for previous violations")
+	}
+	// The policy was re-evaluated with the resourceNames placeholder, which it can't tell apart from
+	// the names that'll replace it, so whether the violation is gone depends on the names chosen
+	if remediation.Resolved && r.grantsPlaceholder(identity) {
+		remediation.Resolved = false
+		remediation.Conditional = true
+	}
+	return remediation
+}
+
+// Returns whether any rule granted to @identity in r.CollectResult is restricted to the resourceNames placeholder
+func (r *remediator) grantsPlaceholder(identity attribute.Identity) bool {
+	for _, slot := range r.RuleSlots(identity) {
+		if utils.Contains(slot.Rule.ResourceNames, resourceNamePlaceholder) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the number of violations of @policyResults that re-evaluating against the patched cluster still flags,
+// and the number of new violations it flags. Compared to a re-evaluation against the original @collectResult.
+func (r *remediator) compareViolations(policyResults eval.PolicyResults, collectResult collect.CollectResult) (int, int) {
+	remaining, introduced := 0, 0
+	for _, policyResult := range policyResults.PolicyResults {
+		before := r.violationKeys(policyResult.PolicyFile, collectResult)
+		after := r.violationKeys(policyResult.PolicyFile, r.CollectResult)
+		for key := range after {
+			if _, ok := before[key]; ok {
+				remaining += 1
+			} else {
+				introduced += 1
+			}
+		}
+	}
+	return remaining, introduced
+}
+
+// Returns the set of identities that violate the policy at @policyFile when evaluated against @collectResult
+func (r *remediator) violationKeys(policyFile string, collectResult collect.CollectResult) map[string]struct{} {
+	keys := make(map[string]struct{})
+	policyResults := eval.Eval(policyFile, collectResult, r.EvalConfig)
+	if policyResults == nil {
+		return keys
+	}
+	for _, policyResult := range policyResults.PolicyResults {
+		for _, identity := range attribute.ViolatingIdentities(policyResult.Violations) {
+			keys[identity.Type+"/"+attribute.IdentityName(identity)] = struct{}{}
+		}
+	}
+	return keys
+}
+
+// Parses the output of eval, either full or abbreviated, from @policyResultsBytes
+func ParsePolicyResults(policyResultsBytes []byte) (*eval.PolicyResults, error) {
+	var policyResults eval.PolicyResults
+	err := json.Unmarshal(policyResultsBytes, &policyResults)
+	if err == nil {
+		return &policyResults, nil
+	}
+
+	// Try abbreviated results, where serviceAccounts are denoted by their full name
+	var abbreviatedResults eval.AbbreviatedPolicyResults
+	if abbreviatedErr := json.Unmarshal(policyResultsBytes, &abbreviatedResults); abbreviatedErr != nil {
+		log.Errorf("ParsePolicyResults: failed to unmarshal eval results with %v\n", err)
+		return nil, err
+	}
+	policyResults = eval.PolicyResults{Summary: abbreviatedResults.Summary}
+	for _, abbreviatedResult := range abbreviatedResults.PolicyResults {
+		policyResult := eval.PolicyResult{
+			PolicyFile:     abbreviatedResult.PolicyFile,
+			Severity:       abbreviatedResult.Severity,
+			Description:    abbreviatedResult.Description,
+			PolicyMetadata: abbreviatedResult.PolicyMetadata,
+			Usage:          abbreviatedResult.Usage,
+			UserGrants:     abbreviatedResult.UserGrants,
+			Violations: eval.Violations{
+				Nodes:    abbreviatedResult.Violations.Nodes,
+				Combined: abbreviatedResult.Violations.Combined,
+				Users:    abbreviatedResult.Violations.Users,
+				Groups:   abbreviatedResult.Violations.Groups,
+			},
+		}
+		for _, saFullName := range abbreviatedResult.Violations.ServiceAccounts {
+			parts := strings.SplitN(saFullName, ":", 2)
+			if len(parts) != 2 {
+				err = fmt.Errorf("invalid serviceAccount '%v'", saFullName)
+				log.Errorf("ParsePolicyResults: failed to parse abbreviated eval results with %v\n", err)
+				return nil, err
+			}
+			policyResult.Violations.ServiceAccounts = append(policyResult.Violations.ServiceAccounts, eval.ServiceAccountViolation{Namespace: parts[0], Name: parts[1]})
+		}
+		policyResults.PolicyResults = append(policyResults.PolicyResults, policyResult)
+	}
+	return &policyResults, nil
+}
+
+// Returns a deep copy of @collectResult
+func copyCollectResult(collectResult collect.CollectResult) (collect.CollectResult, error) {
+	var copied collect.CollectResult
+	collectResultBytes, err := json.Marshal(collectResult)
+	if err != nil {
+		log.Errorf("copyCollectResult: failed to marshal CollectResult with %v\n", err)
+		return copied, err
+	}
+	err = json.Unmarshal(collectResultBytes, &copied)
+	if err != nil {
+		log.Errorf("copyCollectResult: failed to unmarshal CollectResult with %v\n", err)
+	}
+	return copied, err
+}
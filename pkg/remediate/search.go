@@ -0,0 +1,275 @@
+package remediate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/attribute"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+	rbac "k8s.io/api/rbac/v1"
+)
+
+var (
+	// Verbs a wildcard is expanded to when removing verbs from a rule
+	standardVerbs = []string{"get", "list", "watch", "create", "update", "patch", "delete", "deletecollection"}
+	// Verbs that resourceNames can restrict
+	nameableVerbs = map[string]struct{}{"get": {}, "update": {}, "patch": {}, "delete": {}, "bind": {}, "escalate": {}, "impersonate": {}, "use": {}, "approve": {}}
+	// Groups that include every identity, which shouldn't be granted sensitive permissions
+	broadGroups = map[string]struct{}{"system:authenticated": {}, "system:unauthenticated": {}}
+)
+
+// A patch that was applied to the remediator's CollectResult
+type appliedPatch struct {
+	Patch
+	modifiedRole *collect.RoleEntry // set for patches that modify a role's rules
+}
+
+// Finds the least disruptive patch to culprits[@index] that resolves @identity's violation, and applies it to r.CollectResult.
+// Options are tried in order: granting the role in the serviceAccount's namespace instead of cluster-wide, dropping a broad
+// group from the role's bindings, restricting the rule via resourceNames, removing verbs, removing resources and removing the rule.
+func (r *remediator) fixSlot(policyFile string, identity attribute.Identity, culprits []attribute.RuleSlot, index int) *appliedPatch {
+	slot := culprits[index]
+	others := append(append([]attribute.RuleSlot{}, culprits[:index]...), culprits[index+1:]...)
+	resolvedWithRule := func(rule rbac.PolicyRule) bool {
+		patchedSlot := slot
+		patchedSlot.Rule = rule
+		return !r.ViolatesWithSlots(policyFile, identity, append(append([]attribute.RuleSlot{}, others...), patchedSlot))
+	}
+
+	// Grant the clusterRole only in the serviceAccount's namespace
+	if slot.Grant.OwnerType == "serviceAccount" && slot.Ref.EffectiveNamespace == "" {
+		namespace := strings.SplitN(slot.Grant.OwnerName, ":", 2)[0]
+		var candidate []attribute.RuleSlot
+		for _, culprit := range culprits {
+			if culprit.Grant == slot.Grant {
+				culprit.Ref.EffectiveNamespace = namespace
+			}
+			candidate = append(candidate, culprit)
+		}
+		if !r.ViolatesWithSlots(policyFile, identity, candidate) {
+			return r.applyBindInNamespace(slot, namespace)
+		}
+	}
+
+	// Drop broad groups from the role's bindings
+	if _, ok := broadGroups[slot.Grant.OwnerName]; ok && slot.Grant.OwnerType == "group" {
+		var candidate []attribute.RuleSlot
+		for _, culprit := range culprits {
+			if culprit.Grant != slot.Grant {
+				candidate = append(candidate, culprit)
+			}
+		}
+		if !r.ViolatesWithSlots(policyFile, identity, candidate) {
+			return r.applyDropSubject(slot)
+		}
+	}
+
+	// Restrict the rule to certain objects
+	if canRestrictByName(slot.Rule) {
+		rule := *slot.Rule.DeepCopy()
+		rule.ResourceNames = []string{resourceNamePlaceholder}
+		if resolvedWithRule(rule) {
+			patch := r.applyRulePatch(slot, &rule, PatchAddResourceNames, fmt.Sprintf("Restrict the rule granting %v to specific objects via resourceNames", describeRule(slot.Rule)))
+			patch.Description += fmt.Sprintf(", replace '%v' with the names of the objects the identity needs", resourceNamePlaceholder)
+			return patch
+		}
+	}
+
+	// Remove verbs
+	verbs := slot.Rule.Verbs
+	if utils.Contains(verbs, "*") {
+		verbs = standardVerbs
+	}
+	removedVerbs, remainingVerbs := minimalRemoval(verbs, func(remaining []string) bool {
+		rule := *slot.Rule.DeepCopy()
+		rule.Verbs = remaining
+		return resolvedWithRule(rule)
+	})
+	if len(removedVerbs) > 0 && len(remainingVerbs) > 0 {
+		rule := *slot.Rule.DeepCopy()
+		rule.Verbs = remainingVerbs
+		description := fmt.Sprintf("Remove the verbs %v from the rule granting %v", strings.Join(removedVerbs, ", "), describeRule(slot.Rule))
+		if utils.Contains(slot.Rule.Verbs, "*") {
+			description = fmt.Sprintf("Replace the wildcard verb with %v in the rule granting %v", strings.Join(remainingVerbs, ", "), describeRule(slot.Rule))
+		}
+		return r.applyRulePatch(slot, &rule, PatchRemoveVerbs, description)
+	}
+
+	// Remove resources
+	if len(slot.Rule.Resources) > 1 && !utils.Contains(slot.Rule.Resources, "*") {
+		removedResources, remainingResources := minimalRemoval(slot.Rule.Resources, func(remaining []string) bool {
+			rule := *slot.Rule.DeepCopy()
+			rule.Resources = remaining
+			return resolvedWithRule(rule)
+		})
+		if len(removedResources) > 0 && len(remainingResources) > 0 {
+			rule := *slot.Rule.DeepCopy()
+			rule.Resources = remainingResources
+			return r.applyRulePatch(slot, &rule, PatchRemoveResources,
+				fmt.Sprintf("Remove the resources %v from the rule granting %v", strings.Join(removedResources, ", "), describeRule(slot.Rule)))
+		}
+	}
+
+	// Remove the rule
+	if !r.ViolatesWithSlots(policyFile, identity, others) {
+		return r.applyRulePatch(slot, nil, PatchRemoveRule, fmt.Sprintf("Remove the rule granting %v", describeRule(slot.Rule)))
+	}
+	return nil
+}
+
+// Returns the items to remove from @items so that @resolved holds, trying to keep as many items as possible.
+// Returns nil if removing all items doesn't resolve the violation.
+func minimalRemoval(items []string, resolved func(remaining []string) bool) ([]string, []string) {
+	if len(items) == 0 || !resolved([]string{}) {
+		return nil, nil
+	}
+	var remaining []string
+	for _, item := range items {
+		if resolved(append(append([]string{}, remaining...), item)) {
+			remaining = append(remaining, item)
+		}
+	}
+	var removed []string
+	for _, item := range items {
+		if !utils.Contains(remaining, item) {
+			removed = append(removed, item)
+		}
+	}
+	return removed, remaining
+}
+
+// Returns whether resourceNames can restrict all the verbs of @rule
+func canRestrictByName(rule rbac.PolicyRule) bool {
+	if len(rule.ResourceNames) > 0 || len(rule.NonResourceURLs) > 0 || len(rule.Verbs) == 0 {
+		return false
+	}
+	for _, verb := range rule.Verbs {
+		if _, ok := nameableVerbs[verb]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Grants the clusterRole of @slot's grant in @namespace instead of cluster-wide
+func (r *remediator) applyBindInNamespace(slot attribute.RuleSlot, namespace string) *appliedPatch {
+	owner := attribute.GrantOwner{Type: slot.Grant.OwnerType, Name: slot.Grant.OwnerName}
+	roleRefs := attribute.OwnerRoleRefs(&r.CollectResult, owner)
+	(*roleRefs)[slot.Grant.RefIndex].EffectiveNamespace = namespace
+
+	saName := strings.SplitN(owner.Name, ":", 2)[1]
+	return &appliedPatch{Patch: Patch{
+		Type:        PatchBindInNamespace,
+		Description: fmt.Sprintf("Grant ClusterRole %v to serviceAccount %v only in namespace %v via a RoleBinding, instead of cluster-wide via a ClusterRoleBinding", slot.Ref.Name, owner.Name, namespace),
+		Diff:        fmt.Sprintf("- ClusterRoleBinding: ClusterRole %v cluster-wide\n+ RoleBinding: ClusterRole %v in namespace %v\n", slot.Ref.Name, slot.Ref.Name, namespace),
+		Manifest:    roleBindingManifest(slot.Ref.Name, namespace, saName),
+	}}
+}
+
+// Drops the group of @slot's grant from the bindings of its role
+func (r *remediator) applyDropSubject(slot attribute.RuleSlot) *appliedPatch {
+	owner := attribute.GrantOwner{Type: slot.Grant.OwnerType, Name: slot.Grant.OwnerName}
+	roleRefs := attribute.OwnerRoleRefs(&r.CollectResult, owner)
+	*roleRefs = append((*roleRefs)[:slot.Grant.RefIndex:slot.Grant.RefIndex], (*roleRefs)[slot.Grant.RefIndex+1:]...)
+
+	return &appliedPatch{Patch: Patch{
+		Type:        PatchDropSubject,
+		Description: fmt.Sprintf("Remove group %v from the subjects of the bindings granting %v", owner.Name, describeRoleRef(slot.Ref)),
+		Diff:        fmt.Sprintf("- Group %v: %v\n", owner.Name, describeRoleRef(slot.Ref)),
+		Manifest:    dropSubjectManifest(owner.Name, slot.Ref),
+	}}
+}
+
+// Replaces the rule of @slot with @rule in its role, or removes it if @rule is nil
+func (r *remediator) applyRulePatch(slot attribute.RuleSlot, rule *rbac.PolicyRule, patchType string, description string) *appliedPatch {
+	roleIndex := attribute.FindRoleIndex(r.CollectResult.Roles, slot.Ref)
+	role := &r.CollectResult.Roles[roleIndex]
+	oldRules := role.Rules
+
+	var newRules []rbac.PolicyRule
+	for i, oldRule := range oldRules {
+		if i != slot.RuleIndex {
+			newRules = append(newRules, oldRule)
+		} else if rule != nil {
+			newRules = append(newRules, *rule)
+		}
+	}
+	if newRules == nil {
+		newRules = []rbac.PolicyRule{}
+	}
+	role.Rules = newRules
+
+	return &appliedPatch{
+		Patch: Patch{
+			Type:        patchType,
+			Description: fmt.Sprintf("%v in %v", description, describeRole(*role)),
+			Diff:        rulesDiff(oldRules, newRules),
+			Manifest:    roleManifest(*role),
+		},
+		modifiedRole: role,
+	}
+}
+
+// Returns a note if @patch modified a role that's also granted to identities other than @identity's
+func (r *remediator) sharedRoleNote(patch appliedPatch, identity attribute.Identity) string {
+	if patch.modifiedRole == nil {
+		return ""
+	}
+	owners := attribute.GrantOwners(identity)
+	sharedWith := 0
+	countOwner := func(ownerType string, ownerName string, roleRefs []collect.RoleRef) {
+		for _, owner := range owners {
+			if owner.Type == ownerType && owner.Name == ownerName {
+				return
+			}
+		}
+		for _, roleRef := range roleRefs {
+			if roleRef.Name == patch.modifiedRole.Name && roleRef.Namespace == patch.modifiedRole.Namespace {
+				sharedWith += 1
+				return
+			}
+		}
+	}
+	for _, sa := range r.CollectResult.ServiceAccounts {
+		countOwner("serviceAccount", utils.FullName(sa.Namespace, sa.Name), sa.Roles)
+	}
+	for _, node := range r.CollectResult.Nodes {
+		countOwner("node", node.Name, node.Roles)
+	}
+	for _, user := range r.CollectResult.Users {
+		countOwner("user", user.Name, user.Roles)
+	}
+	for _, group := range r.CollectResult.Groups {
+		countOwner("group", group.Name, group.Roles)
+	}
+	if sharedWith == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%v is also granted to %d other identities, which are affected by the patch as well", describeRole(*patch.modifiedRole), sharedWith)
+}
+
+// Returns a short description of the permissions @rule grants
+func describeRule(rule rbac.PolicyRule) string {
+	if len(rule.NonResourceURLs) > 0 {
+		return fmt.Sprintf("%v on %v", strings.Join(rule.Verbs, ", "), strings.Join(rule.NonResourceURLs, ", "))
+	}
+	return fmt.Sprintf("%v on %v", strings.Join(rule.Verbs, ", "), strings.Join(rule.Resources, ", "))
+}
+
+// Returns a short description of @role
+func describeRole(role collect.RoleEntry) string {
+	if role.Namespace == "" {
+		return "ClusterRole " + role.Name
+	}
+	return fmt.Sprintf("Role %v in namespace %v", role.Name, role.Namespace)
+}
+
+// Returns a short description of the role @roleRef grants
+func describeRoleRef(roleRef collect.RoleRef) string {
+	description := describeRole(collect.RoleEntry{Name: roleRef.Name, Namespace: roleRef.Namespace})
+	if roleRef.EffectiveNamespace != "" && roleRef.Namespace == "" {
+		description += " in namespace " + roleRef.EffectiveNamespace
+	}
+	return description
+}
@@ -0,0 +1,64 @@
+package remediate
+
+import (
+	"github.com/PaloAltoNetworks/rbac-police/pkg/attribute"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+)
+
+// RemediateConfig holds the options for Remediate()
+type RemediateConfig struct {
+	EvalConfig eval.EvalConfig
+	OutDir     string
+}
+
+// Result of Remediate()
+type RemediateResult struct {
+	Remediations []Remediation `json:"remediations"`
+	Summary      Summary       `json:"summary"`
+}
+
+// Patches proposed for a violation of a policy by an identity
+type Remediation struct {
+	PolicyFile  string             `json:"policy"`
+	PolicyID    string             `json:"id"`
+	Identity    attribute.Identity `json:"identity"`
+	Patches     []Patch            `json:"patches"`
+	Resolved    bool               `json:"resolved"`              // whether re-evaluating the policy against the patched cluster no longer flags the identity
+	Conditional bool               `json:"conditional,omitempty"` // resolved only once the resourceNames placeholder is replaced with names the policy permits
+	Notes       []string           `json:"notes,omitempty"`
+	File        string             `json:"file,omitempty"` // file holding the patches' manifests
+}
+
+// A proposed change to a role or to how a role is granted
+type Patch struct {
+	Type        string `json:"type"` // one of the patch type constants
+	Description string `json:"description"`
+	Diff        string `json:"diff,omitempty"`     // diff of the role's rules
+	Manifest    string `json:"manifest,omitempty"` // YAML manifest to apply, or instructions as YAML comments
+}
+
+const (
+	PatchBindInNamespace    = "bindInNamespace"
+	PatchDropSubject        = "dropSubject"
+	PatchAddResourceNames   = "addResourceNames"
+	PatchRemoveVerbs        = "removeVerbs"
+	PatchRemoveResources    = "removeResources"
+	PatchRemoveRule         = "removeRule"
+	resourceNamePlaceholder = "REPLACE-WITH-ALLOWED-NAME"
+)
+
+// Summary of Remediate()
+type Summary struct {
+	Violations  int `json:"violations"`
+	Resolved    int `json:"resolved"`
+	Conditional int `json:"conditional"` // resolved only once the resourceNames placeholder is replaced, see Remediation.Conditional
+	Unresolved  int `json:"unresolved"`
+	Remaining   int `json:"remaining"`  // violations still flagged when re-evaluating all policies against the patched cluster
+	Introduced  int `json:"introduced"` // new violations flagged when re-evaluating all policies against the patched cluster
+}
+
+// A violation of a policy by an identity
+type violation struct {
+	PolicyResult eval.PolicyResult
+	Identity     attribute.Identity
+}
@@ -0,0 +1,235 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/attribute"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/apis/audit"
+	auditv1 "k8s.io/apiserver/pkg/apis/audit/v1"
+	auditvalidation "k8s.io/apiserver/pkg/apis/audit/validation"
+	"sigs.k8s.io/yaml"
+)
+
+const auditPolicyHeader = `# Generated by rbac-police from eval results. Logs the requests of flagged identities that exercise
+# the permissions that made them violate policies at the RequestResponse level.
+`
+
+// Converts @policyResults into an audit.k8s.io/v1 Policy. Each violation is attributed to the rules causing it by
+// re-evaluating the violated policy against @collectResult, and the identity's requests matching those rules are logged
+// at the RequestResponse level. Other requests are logged at the Metadata level, unless config.BasePolicyFile is set,
+// in which case the generated rules are prepended to the rules of the existing policy.
+func AuditPolicy(policyResults *eval.PolicyResults, collectResult collect.CollectResult, config AuditPolicyConfig) (string, error) {
+	policy := auditv1.Policy{
+		TypeMeta:   metav1.TypeMeta{APIVersion: auditv1.SchemeGroupVersion.String(), Kind: "Policy"},
+		OmitStages: []auditv1.Stage{auditv1.StageRequestReceived},
+	}
+	if config.BasePolicyFile != "" {
+		basePolicy, err := readAuditPolicy(config.BasePolicyFile)
+		if err != nil {
+			return "", err // error printed in readAuditPolicy
+		}
+		policy = *basePolicy
+	}
+
+	attributions, err := attribute.AttributeViolations(*policyResults, collectResult, config.EvalConfig)
+	if err != nil {
+		return "", err // error printed in AttributeViolations
+	}
+	flaggedRules := flaggedAuditRules(attributions, config.NodeUser)
+
+	// Audit rules are matched in order, so the generated rules precede the existing ones.
+	// Existing rules equal to generated ones are dropped, as are left by merging into a previously generated policy.
+	rules := flaggedRules
+	hasCatchAll := false
+	for _, rule := range policy.Rules {
+		if !containsAuditRule(flaggedRules, rule) {
+			rules = append(rules, rule)
+		}
+		if isCatchAllAuditRule(rule) {
+			hasCatchAll = true
+		}
+	}
+	if !hasCatchAll {
+		rules = append(rules, auditv1.PolicyRule{Level: auditv1.LevelMetadata})
+	}
+	policy.Rules = rules
+
+	if err = validateAuditPolicy(&policy); err != nil {
+		return "", err // error printed in validateAuditPolicy
+	}
+	// Drop the empty metadata, which only holds a null creationTimestamp
+	var policyMap map[string]interface{}
+	policyBytes, err := json.Marshal(policy)
+	if err == nil {
+		err = json.Unmarshal(policyBytes, &policyMap)
+	}
+	if err != nil {
+		log.Errorf("AuditPolicy: failed to convert audit policy with %v\n", err)
+		return "", err
+	}
+	if metadata, ok := policyMap["metadata"].(map[string]interface{}); ok && len(metadata) == 1 && metadata["creationTimestamp"] == nil {
+		delete(policyMap, "metadata")
+	}
+	policyYaml, err := yaml.Marshal(policyMap)
+	if err != nil {
+		log.Errorf("AuditPolicy: failed to marshal audit policy with %v\n", err)
+		return "", err
+	}
+	return auditPolicyHeader + string(policyYaml), nil
+}
+
+// Returns RequestResponse audit rules matching the requests of violating identities that exercise the rules in @attributions.
+// Rules that only differ in the identities they match are merged.
+func flaggedAuditRules(attributions []attribute.Attribution, nodeUser string) []auditv1.PolicyRule {
+	var rules []auditv1.PolicyRule
+	ruleIndexes := make(map[string]int)
+	for _, attribution := range attributions {
+		for _, attributedRule := range attribution.Rules {
+			for _, rule := range auditRulesFor(attributedRule) {
+				key := auditRuleKey(rule)
+				index, ok := ruleIndexes[key]
+				if !ok {
+					rules = append(rules, rule)
+					index = len(rules) - 1
+					ruleIndexes[key] = index
+				}
+				if attributedRule.OwnerType == "group" {
+					rules[index].UserGroups = appendUnique(rules[index].UserGroups, attributedRule.OwnerName)
+				} else {
+					rules[index].Users = appendUnique(rules[index].Users, username(attributedRule.OwnerType, attributedRule.OwnerName, nodeUser))
+				}
+			}
+		}
+	}
+	for i := range rules {
+		sort.Strings(rules[i].Users)
+		sort.Strings(rules[i].UserGroups)
+	}
+	return rules
+}
+
+// Returns the audit rules, without users or groups, matching the requests @attributedRule permits
+func auditRulesFor(attributedRule attribute.AttributedRule) []auditv1.PolicyRule {
+	var rules []auditv1.PolicyRule
+	rule := attributedRule.Rule
+	var verbs []string
+	if !utils.Contains(rule.Verbs, "*") {
+		verbs = rule.Verbs
+	}
+
+	if len(rule.NonResourceURLs) > 0 {
+		rules = append(rules, auditv1.PolicyRule{
+			Level:           auditv1.LevelRequestResponse,
+			Verbs:           verbs,
+			NonResourceURLs: rule.NonResourceURLs,
+		})
+	}
+	if len(rule.Resources) > 0 {
+		auditRule := auditv1.PolicyRule{Level: auditv1.LevelRequestResponse, Verbs: verbs}
+		if attributedRule.Namespace != "" {
+			auditRule.Namespaces = []string{attributedRule.Namespace}
+		}
+		// An empty list of resources matches all resources, and an empty list of groups matches all groups
+		if !utils.Contains(rule.APIGroups, "*") {
+			var resources, resourceNames []string
+			if !utils.Contains(rule.Resources, "*") {
+				resources = rule.Resources
+				resourceNames = rule.ResourceNames
+			}
+			for _, group := range rule.APIGroups {
+				auditRule.Resources = append(auditRule.Resources, auditv1.GroupResources{Group: group, Resources: resources, ResourceNames: resourceNames})
+			}
+		}
+		rules = append(rules, auditRule)
+	}
+	return rules
+}
+
+// Returns the username the API server authenticates the identity @name of @ownerType as
+func username(ownerType string, name string, nodeUser string) string {
+	switch ownerType {
+	case "serviceAccount":
+		return "system:serviceaccount:" + name
+	case "node":
+		if nodeUser != "" {
+			return nodeUser
+		}
+		return "system:node:" + name
+	default:
+		return name
+	}
+}
+
+// Returns a key identifying @rule regardless of the users and groups it matches
+func auditRuleKey(rule auditv1.PolicyRule) string {
+	rule.Users, rule.UserGroups = nil, nil
+	ruleBytes, _ := json.Marshal(rule)
+	return string(ruleBytes)
+}
+
+// Returns whether @rules contains a rule equal to @rule
+func containsAuditRule(rules []auditv1.PolicyRule, rule auditv1.PolicyRule) bool {
+	ruleBytes, _ := json.Marshal(rule)
+	for _, r := range rules {
+		rBytes, _ := json.Marshal(r)
+		if string(rBytes) == string(ruleBytes) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns whether @rule matches all requests
+func isCatchAllAuditRule(rule auditv1.PolicyRule) bool {
+	return len(rule.Users) == 0 && len(rule.UserGroups) == 0 && len(rule.Verbs) == 0 && len(rule.Resources) == 0 &&
+		len(rule.Namespaces) == 0 && len(rule.NonResourceURLs) == 0
+}
+
+// Returns @items with @item appended, unless it's already there
+func appendUnique(items []string, item string) []string {
+	if utils.Contains(items, item) {
+		return items
+	}
+	return append(items, item)
+}
+
+// Reads the audit policy at @path
+func readAuditPolicy(path string) (*auditv1.Policy, error) {
+	policyBytes, err := utils.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var policy auditv1.Policy
+	if err = yaml.UnmarshalStrict(policyBytes, &policy); err != nil {
+		log.Errorf("readAuditPolicy: failed to unmarshal %v into an audit policy with %v\n", path, err)
+		return nil, err
+	}
+	if policy.APIVersion != auditv1.SchemeGroupVersion.String() || policy.Kind != "Policy" {
+		err = fmt.Errorf("expected kind Policy of %v, got kind %v of %v", auditv1.SchemeGroupVersion, policy.Kind, policy.APIVersion)
+		log.Errorf("readAuditPolicy: %v isn't an audit policy, %v\n", path, err)
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Validates @policy as the API server would when loading it
+func validateAuditPolicy(policy *auditv1.Policy) error {
+	var internalPolicy audit.Policy
+	if err := auditv1.Convert_v1_Policy_To_audit_Policy(policy, &internalPolicy, nil); err != nil {
+		log.Errorf("validateAuditPolicy: failed to convert audit policy with %v\n", err)
+		return err
+	}
+	if errs := auditvalidation.ValidatePolicy(&internalPolicy); len(errs) > 0 {
+		err := errs.ToAggregate()
+		log.Errorf("validateAuditPolicy: generated audit policy is invalid, %v\n", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/blastradius"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/expand"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+)
+
+// Renders @result as tables of the reachable identities, the cloud provider IAM entities and secrets they expose, the highlighted secrets they can read,
+// and the union of their permissions, followed by the policies they violate. @color enables colorized severities.
+func BlastRadiusTable(result *blastradius.BlastRadiusResult, color bool) string {
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("Compromised: %v\n\n", blastradius.FormatIdentity(result.Compromised)))
+
+	out.WriteString(renderTable("DEPTH\tIDENTITY\tFROM\tVIA", len(result.Identities), func(i int) string {
+		identity := result.Identities[i]
+		return fmt.Sprintf("%d\t%v\t%v\t%v", identity.Depth, blastradius.FormatIdentity(identity.Identity), cellOrDash(identity.From), cellOrDash(identity.Via))
+	}))
+	if len(result.ProviderIAM) > 0 {
+		out.WriteString("\n" + renderTable("PROVIDER\tIAM ENTITY\tSERVICEACCOUNT", len(result.ProviderIAM), func(i int) string {
+			cloudIdentity := result.ProviderIAM[i]
+			return fmt.Sprintf("%v\t%v\t%v", cloudIdentity.Provider, cloudIdentity.Entity, cloudIdentity.ServiceAccount)
+		}))
+	}
+	if len(result.Secrets) > 0 {
+		out.WriteString("\n" + renderTable("SECRETS OF\tNAMESPACE\tVERBS\tRESOURCE NAMES", len(result.Secrets), func(i int) string {
+			secrets := result.Secrets[i]
+			namespace := secrets.Namespace
+			if namespace == "" {
+				namespace = "*"
+			}
+			resourceNames := strings.Join(secrets.ResourceNames, ", ")
+			if resourceNames == "" {
+				resourceNames = "*"
+			}
+			return fmt.Sprintf("%v\t%v\t%v\t%v", secrets.Identity, namespace, strings.Join(secrets.Verbs, ", "), resourceNames)
+		}))
+	}
+
+	if len(result.ReadableSecrets) > 0 {
+		var highlighted []expand.ReadableSecret
+		for _, secret := range result.ReadableSecrets {
+			if secret.Highlight != "" {
+				highlighted = append(highlighted, secret)
+			}
+		}
+		out.WriteString(fmt.Sprintf("\nReadable secrets: %d, %d highlighted\n", len(result.ReadableSecrets), len(highlighted)))
+		if len(highlighted) > 0 {
+			out.WriteString(renderTable("SECRET\tTYPE\tHIGHLIGHT\tVERBS", len(highlighted), func(i int) string {
+				secret := highlighted[i]
+				return fmt.Sprintf("%v\t%v\t%v\t%v", utils.FullName(secret.Namespace, secret.Name), secret.Type, secret.Highlight, strings.Join(secret.Verbs, ", "))
+			}))
+		}
+	}
+
+	rules := 0
+	for _, role := range result.Permissions {
+		rules += len(role.Rules)
+	}
+	out.WriteString(fmt.Sprintf("\nPermissions: %d roles with %d rules\n", len(result.Permissions), rules))
+	for _, role := range result.Permissions {
+		scope := "cluster-wide"
+		if role.EffectiveNamespace != "" {
+			scope = "in " + role.EffectiveNamespace
+		}
+		out.WriteString(fmt.Sprintf("  %v (%v)\n", role.Name, scope))
+	}
+
+	out.WriteString("\nViolations:\n")
+	for _, policyResult := range sortedResults(result.PolicyResults) {
+		line := fmt.Sprintf("  [%v] %v %v", severityCell(policyResult.Severity), policyResult.ID, policyName(policyResult.PolicyFile))
+		if color {
+			line = colorizeSeverityWord(line, policyResult.Severity)
+		}
+		if policyResult.Title != "" {
+			line += " - " + policyResult.Title
+		}
+		out.WriteString(line + "\n")
+		for _, group := range violationGroups(policyResult.Violations, policyResult.UserGrants, true) {
+			out.WriteString(fmt.Sprintf("    %v: %v\n", group.Type, strings.Join(group.Identities, ", ")))
+		}
+	}
+	out.WriteString("\nSummary: " + formatSummary(result.PolicyResults.Summary) + "\n")
+	return out.String()
+}
+
+// Renders a table with @header and @count rows rendered by @row
+func renderTable(header string, count int, row func(int) string) string {
+	var table bytes.Buffer
+	writer := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, header)
+	for i := 0; i < count; i++ {
+		fmt.Fprintln(writer, row(i))
+	}
+	writer.Flush()
+	return table.String()
+}
+
+// Returns @cell, or a dash if it's empty
+func cellOrDash(cell string) string {
+	if cell == "" {
+		return "-"
+	}
+	return cell
+}
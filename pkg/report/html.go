@@ -0,0 +1,222 @@
+package report
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/expand"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+//go:embed html.tmpl
+var htmlTemplateText string
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"join":          strings.Join,
+	"severityClass": severityClass,
+	"features":      featuresWithSources,
+}).Parse(htmlTemplateText))
+
+// Renders @policyResults as a self-contained HTML report that works offline.
+// If @expandResult isn't nil, the permissions of identities are embedded to allow drilling down from violations.
+// In @short mode serviceAccounts are listed by name only.
+func HTML(policyResults *eval.PolicyResults, metadata collect.ClusterMetadata, expandResult *expand.ExpandResult, short bool) (string, error) {
+	report := buildHtmlReport(policyResults, metadata, expandResult, short)
+
+	var out strings.Builder
+	err := htmlTemplate.Execute(&out, report)
+	if err != nil {
+		log.Errorf("HTML: failed to render report with %v\n", err)
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// Builds the data rendered into the HTML report
+func buildHtmlReport(policyResults *eval.PolicyResults, metadata collect.ClusterMetadata, expandResult *expand.ExpandResult, short bool) htmlReport {
+	report := htmlReport{
+		Metadata: metadata,
+		Summary:  policyResults.Summary,
+		Expanded: expandResult != nil,
+	}
+	identities := make(map[string]*htmlIdentity)
+
+	// Policies, and the identities that violated them
+	// Anchors include the result's index, as policies in different files may share an ID
+	for i, result := range sortedResults(policyResults) {
+		policy := htmlPolicy{
+			Anchor:        fmt.Sprintf("policy-%d-%v", i+1, anchorSafe(result.ID)),
+			Name:          policyName(result.PolicyFile),
+			PolicyResult:  result,
+			IdentityCount: identityCounts(result.Violations),
+		}
+		addViolation := func(groupType string, text string, keys ...string) {
+			violation := htmlViolation{Text: text}
+			for _, key := range keys {
+				identity := getHtmlIdentity(identities, key)
+				identity.addPolicy(htmlLink{Name: policy.ID, Anchor: policy.Anchor}, result.Severity)
+				violation.Links = append(violation.Links, htmlLink{Name: identity.Name, Anchor: identity.Anchor})
+			}
+			policy.addViolation(groupType, violation)
+		}
+
+		for _, sa := range result.Violations.ServiceAccounts {
+			addViolation("serviceAccounts", formatServiceAccount(sa, short), identityKey("serviceAccount", utils.FullName(sa.Namespace, sa.Name)))
+		}
+		for _, node := range result.Violations.Nodes {
+			addViolation("nodes", node, identityKey("node", node))
+		}
+		for _, combined := range result.Violations.Combined {
+			keys := []string{identityKey("node", combined.Node)}
+			for _, sa := range combined.ServiceAccounts {
+				keys = append(keys, identityKey("serviceAccount", sa))
+			}
+			addViolation("combined", formatCombined(combined), keys...)
+		}
+		for _, user := range result.Violations.Users {
+			addViolation("users", user, identityKey("user", user))
+		}
+		for _, group := range result.Violations.Groups {
+			addViolation("groups", group, identityKey("group", group))
+		}
+		report.Policies = append(report.Policies, policy)
+	}
+
+	// Attach the expanded permissions of identities, also listing identities without violations
+	if expandResult != nil {
+		for _, sa := range expandResult.ServiceAccounts {
+			getHtmlIdentity(identities, identityKey("serviceAccount", utils.FullName(sa.Namespace, sa.Name))).Roles = sa.Roles
+		}
+		for _, node := range expandResult.Nodes {
+			getHtmlIdentity(identities, identityKey("node", node.Name)).Roles = node.Roles
+		}
+		for _, user := range expandResult.Users {
+			getHtmlIdentity(identities, identityKey("user", user.Name)).Roles = user.Roles
+		}
+		for _, group := range expandResult.Groups {
+			getHtmlIdentity(identities, identityKey("group", group.Name)).Roles = group.Roles
+		}
+	}
+
+	// Identities, most severe first
+	for _, identity := range identities {
+		report.Identities = append(report.Identities, *identity)
+	}
+	sort.Slice(report.Identities, func(i, j int) bool {
+		a, b := report.Identities[i], report.Identities[j]
+		if len(a.Policies) > 0 != (len(b.Policies) > 0) {
+			return len(a.Policies) > 0
+		}
+		if rank(a.Severity) != rank(b.Severity) {
+			return rank(a.Severity) < rank(b.Severity)
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Name < b.Name
+	})
+
+	// Severity breakdown, the number of failed policies and violating identities per severity
+	for _, severity := range severityOrder[:len(severityOrder)-1] {
+		count := htmlSeverityCount{Severity: severity}
+		for _, policy := range report.Policies {
+			if policy.Severity == severity {
+				count.Policies++
+			}
+		}
+		for _, identity := range report.Identities {
+			if identity.Severity == severity && len(identity.Policies) > 0 {
+				count.Identities++
+			}
+		}
+		report.Severities = append(report.Severities, count)
+	}
+	return report
+}
+
+// Returns the identity denoted by @key from @identities, creating it if needed
+func getHtmlIdentity(identities map[string]*htmlIdentity, key string) *htmlIdentity {
+	if identity, ok := identities[key]; ok {
+		return identity
+	}
+	identityType, name := splitIdentityKey(key)
+	identity := &htmlIdentity{
+		Anchor:   fmt.Sprintf("identity-%d", len(identities)),
+		Type:     identityType,
+		Name:     name,
+		Severity: "None",
+	}
+	identities[key] = identity
+	return identity
+}
+
+// Records that the identity violated the policy linked by @policy, of @severity
+func (identity *htmlIdentity) addPolicy(policy htmlLink, severity string) {
+	for _, violatedPolicy := range identity.Policies {
+		if violatedPolicy.Anchor == policy.Anchor {
+			return
+		}
+	}
+	identity.Policies = append(identity.Policies, policy)
+	if identity.Severity == "None" || rank(severity) < rank(identity.Severity) {
+		identity.Severity = severityCell(severity)
+	}
+}
+
+// Adds @violation to the group of @groupType
+func (policy *htmlPolicy) addViolation(groupType string, violation htmlViolation) {
+	for i := range policy.Groups {
+		if policy.Groups[i].Type == groupType {
+			policy.Groups[i].Violations = append(policy.Groups[i].Violations, violation)
+			return
+		}
+	}
+	policy.Groups = append(policy.Groups, htmlViolationGroup{Type: groupType, Violations: []htmlViolation{violation}})
+}
+
+// Returns a key uniquely identifying an identity of @identityType
+func identityKey(identityType string, name string) string {
+	return identityType + "/" + name
+}
+
+// Splits an identity key into its type and name
+func splitIdentityKey(key string) (string, string) {
+	parts := strings.SplitN(key, "/", 2)
+	return parts[0], parts[1]
+}
+
+// Returns @text with characters that aren't valid in an HTML id replaced
+func anchorSafe(text string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, text)
+}
+
+// Returns the CSS class of @severity
+func severityClass(severity string) string {
+	if severity == "" {
+		return "sev-none"
+	}
+	return "sev-" + strings.ToLower(severity)
+}
+
+// Returns the features of @metadata, noting those that weren't discovered, e.g. 'NodeRestriction (assumed)'
+func featuresWithSources(metadata collect.ClusterMetadata) []string {
+	var features []string
+	for _, feature := range metadata.Features {
+		if source, ok := metadata.FeatureSources[feature]; ok && source != collect.FeatureDiscovered {
+			feature += " (" + source + ")"
+		}
+		features = append(features, feature)
+	}
+	return features
+}
@@ -0,0 +1,83 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+)
+
+// Renders @identityResults as a table of identities ranked by risk score, followed by the policies each violated.
+// @color enables colorized severities.
+func IdentityTable(identityResults eval.IdentityResults, color bool) string {
+	var out strings.Builder
+
+	var overview bytes.Buffer
+	writer := tabwriter.NewWriter(&overview, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "RANK\tSCORE\tTYPE\tIDENTITY\tPOLICIES\tNODES\tPODS\tPROVIDER IAM\tEXPOSURE")
+	for i, identity := range identityResults.Identities {
+		fmt.Fprintf(writer, "%d\t%v\t%v\t%v\t%d\t%d\t%d\t%v\t%v\n", i+1, identity.Score, identity.Type, identityName(identity),
+			len(identity.Policies), identity.Nodes, identity.Pods, len(identity.ProviderIAM) > 0, exposureCell(identity))
+	}
+	writer.Flush()
+	out.WriteString(overview.String())
+
+	for i, identity := range identityResults.Identities {
+		out.WriteString(fmt.Sprintf("\n[%d] %v %v (score %v = %v)\n", i+1, identity.Type, identityName(identity), identity.Score, formatFactors(identity.Factors)))
+		for _, policy := range identity.Policies {
+			line := fmt.Sprintf("  [%v] %v %v", severityCell(policy.Severity), policy.ID, policyName(policy.PolicyFile))
+			if color {
+				line = colorizeSeverityWord(line, policy.Severity)
+			}
+			if policy.Title != "" {
+				line += " - " + policy.Title
+			}
+			out.WriteString(line + "\n")
+		}
+	}
+
+	out.WriteString("\nSummary: " + formatSummary(identityResults.Summary) + "\n")
+	return out.String()
+}
+
+// Renders @identityResults as a Markdown report of identities ranked by risk score
+func IdentityMarkdown(identityResults eval.IdentityResults) string {
+	var out strings.Builder
+	out.WriteString("# rbac-police identity report\n\n")
+	out.WriteString("| Rank | Score | Type | Identity | Policies | Nodes | Pods | Provider IAM | Exposure |\n")
+	out.WriteString("|---|---|---|---|---|---|---|---|---|\n")
+	for i, identity := range identityResults.Identities {
+		var policies []string
+		for _, policy := range identity.Policies {
+			policies = append(policies, fmt.Sprintf("%v (%v)", policy.ID, severityCell(policy.Severity)))
+		}
+		out.WriteString(fmt.Sprintf("| %d | %v | %v | `%v` | %v | %d | %d | %v | %v |\n", i+1, identity.Score, identity.Type, identityName(identity),
+			escapeMarkdown(strings.Join(policies, ", ")), identity.Nodes, identity.Pods, len(identity.ProviderIAM) > 0, exposureCell(identity)))
+	}
+	out.WriteString("\nSummary: " + formatSummary(identityResults.Summary) + "\n")
+	return out.String()
+}
+
+// Returns the full name of @identity
+func identityName(identity eval.IdentityResult) string {
+	if identity.Type == "serviceAccount" {
+		return utils.FullName(identity.Namespace, identity.Name)
+	}
+	return identity.Name
+}
+
+// Returns the text shown for the exposure of @identity
+func exposureCell(identity eval.IdentityResult) string {
+	if identity.Exposure == "" {
+		return "-"
+	}
+	return identity.Exposure
+}
+
+// Formats the factors of a risk score as their product
+func formatFactors(factors eval.RiskFactors) string {
+	return fmt.Sprintf("severity %v x hosting %v x providerIAM %v x exposure %v", factors.Severity, factors.Hosting, factors.ProviderIAM, factors.Exposure)
+}
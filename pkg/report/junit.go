@@ -0,0 +1,105 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+	log "github.com/sirupsen/logrus"
+)
+
+// Renders @policyResults as a JUnit XML report, where each policy file is a testcase that
+// passed, failed due to violations, errored or was skipped. Testsuite properties are taken from @metadata.
+// In @short mode serviceAccounts are listed by name only.
+func JUnit(policyResults *eval.PolicyResults, metadata collect.ClusterMetadata, short bool) (string, error) {
+	summary := policyResults.Summary
+	suite := junitTestSuite{
+		Name:       "rbac-police",
+		Tests:      summary.Evaluated + summary.Skipped.BelowThreshold + summary.Skipped.Filtered,
+		Failures:   summary.Failed,
+		Errors:     summary.Errors,
+		Skipped:    summary.Skipped.BelowThreshold + summary.Skipped.Filtered,
+		Properties: junitProperties(metadata),
+	}
+
+	failedResults := make(map[string]eval.PolicyResult)
+	for _, result := range policyResults.PolicyResults {
+		failedResults[result.PolicyFile] = result
+	}
+	for _, outcome := range policyResults.Outcomes {
+		testCase := junitTestCase{
+			Name:      outcome.PolicyFile,
+			ClassName: "rbac-police." + policyName(outcome.PolicyFile),
+		}
+		switch outcome.Status {
+		case eval.PolicyFailed:
+			result := failedResults[outcome.PolicyFile]
+			testCase.Failure = &junitMessage{
+				Message:  junitFailureMessage(result),
+				Type:     severityCell(result.Severity),
+				Contents: junitFailureDetails(result, short),
+			}
+		case eval.PolicyErrored:
+			testCase.Error = &junitMessage{Message: outcome.Reason, Type: "error"}
+		case eval.PolicySkipped:
+			testCase.Skipped = &junitMessage{Message: outcome.Reason}
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	suites := junitTestSuites{
+		Name:       suite.Name,
+		Tests:      suite.Tests,
+		Failures:   suite.Failures,
+		Errors:     suite.Errors,
+		Skipped:    suite.Skipped,
+		TestSuites: []junitTestSuite{suite},
+	}
+	output, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		log.Errorf("JUnit: failed to marshal report with %v\n", err)
+		return "", err
+	}
+	return xml.Header + string(output), nil
+}
+
+// Returns testsuite properties describing the cluster
+func junitProperties(metadata collect.ClusterMetadata) []junitProperty {
+	return []junitProperty{
+		{Name: "cluster", Value: metadata.ClusterName},
+		{Name: "platform", Value: metadata.Platform},
+		{Name: "version", Value: metadata.Version.GitVersion},
+		{Name: "features", Value: strings.Join(metadata.Features, ",")},
+	}
+}
+
+// Returns a one line description of the violations of a failed policy
+func junitFailureMessage(result eval.PolicyResult) string {
+	counts := identityCounts(result.Violations)
+	total := counts.ServiceAccounts + counts.Nodes + counts.Combined + counts.Users + counts.Groups
+	title := result.Title
+	if title == "" {
+		title = policyName(result.PolicyFile)
+	}
+	return fmt.Sprintf("%v %v: %d violations (%v)", result.ID, title, total, severityCell(result.Severity))
+}
+
+// Returns the description of a failed policy and the identities that violated it
+func junitFailureDetails(result eval.PolicyResult, short bool) string {
+	var details strings.Builder
+	if result.Description != "" {
+		details.WriteString(result.Description + "\n")
+	}
+	if result.Remediation != "" {
+		details.WriteString("Remediation: " + result.Remediation + "\n")
+	}
+	for _, group := range violationGroups(result.Violations, result.UserGrants, short) {
+		details.WriteString(fmt.Sprintf("%v (%d):\n", group.Type, len(group.Identities)))
+		for _, identity := range group.Identities {
+			details.WriteString("  " + identity + "\n")
+		}
+	}
+	return details.String()
+}
@@ -0,0 +1,82 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+)
+
+// Renders @policyResults as a Markdown report with a section per severity,
+// suitable for PR comments and wikis. In @short mode serviceAccounts are listed by name only.
+func Markdown(policyResults *eval.PolicyResults, short bool) string {
+	var out strings.Builder
+	results := sortedResults(policyResults)
+	summary := policyResults.Summary
+
+	// Summary header
+	out.WriteString("# rbac-police report\n\n")
+	out.WriteString("| Failed | Passed | Errors | Evaluated | Skipped (threshold) | Skipped (filtered) |\n")
+	out.WriteString("|---|---|---|---|---|---|\n")
+	out.WriteString(fmt.Sprintf("| %d | %d | %d | %d | %d | %d |\n", summary.Failed, summary.Passed, summary.Errors,
+		summary.Evaluated, summary.Skipped.BelowThreshold, summary.Skipped.Filtered))
+
+	// Section per severity
+	for _, severity := range severityOrder {
+		var severityResults []eval.PolicyResult
+		for _, result := range results {
+			if result.Severity == severity {
+				severityResults = append(severityResults, result)
+			}
+		}
+		if len(severityResults) == 0 {
+			continue
+		}
+		out.WriteString(fmt.Sprintf("\n## %v (%d)\n", severityCell(severity), len(severityResults)))
+		for _, result := range severityResults {
+			writeMarkdownPolicy(&out, result, short)
+		}
+	}
+	return out.String()
+}
+
+// Writes the section of a single policy result into @out
+func writeMarkdownPolicy(out *strings.Builder, result eval.PolicyResult, short bool) {
+	title := policyName(result.PolicyFile)
+	if result.Title != "" {
+		title = result.Title
+	}
+	out.WriteString(fmt.Sprintf("\n### %v: %v\n", result.ID, escapeMarkdown(title)))
+	out.WriteString(fmt.Sprintf("- Policy: `%v`\n", result.PolicyFile))
+	if result.Category != "" {
+		out.WriteString(fmt.Sprintf("- Category: %v\n", escapeMarkdown(result.Category)))
+	}
+	if len(result.Mitre) > 0 {
+		out.WriteString(fmt.Sprintf("- MITRE ATT&CK: %v\n", strings.Join(result.Mitre, ", ")))
+	}
+	if result.Description != "" {
+		out.WriteString(fmt.Sprintf("- Description: %v\n", escapeMarkdown(result.Description)))
+	}
+	if result.Remediation != "" {
+		out.WriteString(fmt.Sprintf("- Remediation: %v\n", escapeMarkdown(result.Remediation)))
+	}
+	for _, group := range violationGroups(result.Violations, result.UserGrants, short) {
+		out.WriteString(fmt.Sprintf("\n<details><summary>%v (%d)</summary>\n\n", group.Type, len(group.Identities)))
+		for _, identity := range group.Identities {
+			out.WriteString(fmt.Sprintf("- `%v`\n", identity))
+		}
+		out.WriteString("\n</details>\n")
+	}
+	if len(result.Usage) > 0 {
+		out.WriteString(fmt.Sprintf("\n<details><summary>usage (%d)</summary>\n\n", len(result.Usage)))
+		for _, usage := range result.Usage {
+			out.WriteString(fmt.Sprintf("- %v\n", escapeMarkdown(formatUsage(usage))))
+		}
+		out.WriteString("\n</details>\n")
+	}
+}
+
+// Escapes characters that Markdown would otherwise interpret in free text
+func escapeMarkdown(text string) string {
+	return strings.NewReplacer("|", "\\|", "<", "&lt;", ">", "&gt;", "*", "\\*", "_", "\\_").Replace(text)
+}
@@ -0,0 +1,248 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	policyReportApiVersion = "wgpolicyk8s.io/v1alpha2"
+	policyReportName       = "rbac-police"
+	policyReportSource     = "rbac-police"
+	managedByLabel         = "app.kubernetes.io/managed-by"
+	rbacApiVersion         = "rbac.authorization.k8s.io/v1"
+)
+
+var (
+	policyReportGvr        = schema.GroupVersionResource{Group: "wgpolicyk8s.io", Version: "v1alpha2", Resource: "policyreports"}
+	clusterPolicyReportGvr = schema.GroupVersionResource{Group: "wgpolicyk8s.io", Version: "v1alpha2", Resource: "clusterpolicyreports"}
+)
+
+// Converts @policyResults into wg-policy PolicyReports, one per namespace for serviceAccount violations,
+// and a ClusterPolicyReport for violations of cluster-scoped identities (nodes, users, groups and combined).
+// Each violation becomes a failed result stamped with @timestamp.
+func PolicyReports(policyResults *eval.PolicyResults, timestamp time.Time) []PolicyReport {
+	namespacedReports := make(map[string]*PolicyReport)
+	clusterReport := newPolicyReport("ClusterPolicyReport", "")
+	reportTimestamp := policyReportTimestamp{Seconds: timestamp.Unix(), Nanos: int32(timestamp.Nanosecond())}
+
+	for _, policyResult := range sortedResults(policyResults) {
+		newResult := func(violationType string, resources ...v1.ObjectReference) PolicyReportResult {
+			return PolicyReportResult{
+				Policy:     policyResult.ID,
+				Rule:       policyName(policyResult.PolicyFile),
+				Category:   policyResult.Category,
+				Severity:   policyReportSeverity(policyResult.Severity),
+				Result:     "fail",
+				Scored:     true,
+				Source:     policyReportSource,
+				Message:    policyResult.Description,
+				Timestamp:  reportTimestamp,
+				Resources:  resources,
+				Properties: map[string]string{"violationType": violationType},
+			}
+		}
+
+		for _, sa := range policyResult.Violations.ServiceAccounts {
+			result := newResult("serviceAccount", serviceAccountReference(sa.Namespace, sa.Name))
+			for provider, iam := range sa.ProviderIAM {
+				result.Properties["providerIAM."+provider] = iam
+			}
+			if pods := saPods(sa); pods != "" {
+				result.Properties["pods"] = pods
+			}
+			report, ok := namespacedReports[sa.Namespace]
+			if !ok {
+				report = newPolicyReport("PolicyReport", sa.Namespace)
+				namespacedReports[sa.Namespace] = report
+			}
+			report.addResult(result)
+		}
+		for _, node := range policyResult.Violations.Nodes {
+			clusterReport.addResult(newResult("node", nodeReference(node)))
+		}
+		for _, combined := range policyResult.Violations.Combined {
+			resources := []v1.ObjectReference{nodeReference(combined.Node)}
+			for _, sa := range combined.ServiceAccounts {
+				if parts := strings.SplitN(sa, ":", 2); len(parts) == 2 {
+					resources = append(resources, serviceAccountReference(parts[0], parts[1]))
+				}
+			}
+			clusterReport.addResult(newResult("combined", resources...))
+		}
+		for _, user := range policyResult.Violations.Users {
+			clusterReport.addResult(newResult("user", v1.ObjectReference{APIVersion: rbacApiVersion, Kind: "User", Name: user}))
+		}
+		for _, group := range policyResult.Violations.Groups {
+			clusterReport.addResult(newResult("group", v1.ObjectReference{APIVersion: rbacApiVersion, Kind: "Group", Name: group}))
+		}
+	}
+
+	// Namespaced reports sorted by namespace, followed by the cluster report
+	var namespaces []string
+	for namespace := range namespacedReports {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	var reports []PolicyReport
+	for _, namespace := range namespaces {
+		reports = append(reports, *namespacedReports[namespace])
+	}
+	return append(reports, *clusterReport)
+}
+
+// Renders @reports as a multi-document YAML
+func PolicyReportsYaml(reports []PolicyReport) (string, error) {
+	var documents []string
+	for _, report := range reports {
+		document, err := yaml.Marshal(report)
+		if err != nil {
+			log.Errorf("PolicyReportsYaml: failed to marshal %v %v with %v\n", report.Kind, report.Metadata.Name, err)
+			return "", err
+		}
+		documents = append(documents, string(document))
+	}
+	return strings.Join(documents, "---\n"), nil
+}
+
+// Server-side applies @reports to the cluster of the current kubeconfig context.
+// Deletes rbac-police reports that @reports doesn't include first, e.g. of namespaces whose violations were fixed,
+// only in @namespace if collection was scoped to it. Requires the wg-policy PolicyReport CRDs to be installed.
+func ApplyPolicyReports(reports []PolicyReport, namespace string) error {
+	config, err := collect.RestConfig()
+	if err != nil {
+		return err // error printed in RestConfig
+	}
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		log.Errorln("ApplyPolicyReports: failed creating dynamic client with", err)
+		return err
+	}
+
+	if err = deleteStalePolicyReports(client, reports, namespace); err != nil {
+		return err // error printed in deleteStalePolicyReports
+	}
+
+	force := true
+	patchOptions := metav1.PatchOptions{FieldManager: policyReportSource, Force: &force}
+	for _, report := range reports {
+		reportBytes, err := json.Marshal(report)
+		if err != nil {
+			log.Errorf("ApplyPolicyReports: failed to marshal %v %v with %v\n", report.Kind, report.Metadata.Name, err)
+			return err
+		}
+		var resource dynamic.ResourceInterface = client.Resource(clusterPolicyReportGvr)
+		if report.Kind == "PolicyReport" {
+			resource = client.Resource(policyReportGvr).Namespace(report.Metadata.Namespace)
+		}
+		_, err = resource.Patch(context.Background(), report.Metadata.Name, types.ApplyPatchType, reportBytes, patchOptions)
+		if err != nil {
+			log.Errorf("ApplyPolicyReports: failed to apply %v with %v\n", reportDisplayName(report), err)
+			return err
+		}
+		log.Infof("ApplyPolicyReports: applied %v with %d results\n", reportDisplayName(report), len(report.Results))
+	}
+	return nil
+}
+
+// Deletes the PolicyReports managed by rbac-police in @namespace, or in all namespaces if it's empty, that aren't in @reports.
+// The ClusterPolicyReport isn't pruned, as every run writes it, and applying it replaces the previous run's results
+func deleteStalePolicyReports(client dynamic.Interface, reports []PolicyReport, namespace string) error {
+	current := make(map[string]struct{})
+	for _, report := range reports {
+		if report.Kind == "PolicyReport" {
+			current[report.Metadata.Namespace+"/"+report.Metadata.Name] = struct{}{}
+		}
+	}
+	listOptions := metav1.ListOptions{LabelSelector: managedByLabel + "=" + policyReportSource}
+	existing, err := client.Resource(policyReportGvr).Namespace(namespace).List(context.Background(), listOptions)
+	if err != nil {
+		log.Errorf("deleteStalePolicyReports: failed to list PolicyReports with %v\n", err)
+		return err
+	}
+	for _, item := range existing.Items {
+		if _, ok := current[item.GetNamespace()+"/"+item.GetName()]; ok {
+			continue
+		}
+		stale := PolicyReport{Kind: "PolicyReport", Metadata: policyReportMetadata{Name: item.GetName(), Namespace: item.GetNamespace()}}
+		err = client.Resource(policyReportGvr).Namespace(item.GetNamespace()).Delete(context.Background(), item.GetName(), metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			log.Errorf("deleteStalePolicyReports: failed to delete stale %v with %v\n", reportDisplayName(stale), err)
+			return err
+		}
+		log.Infof("deleteStalePolicyReports: deleted stale %v\n", reportDisplayName(stale))
+	}
+	return nil
+}
+
+// Creates an empty report of @kind in @namespace
+func newPolicyReport(kind string, namespace string) *PolicyReport {
+	return &PolicyReport{
+		ApiVersion: policyReportApiVersion,
+		Kind:       kind,
+		Metadata: policyReportMetadata{
+			Name:      policyReportName,
+			Namespace: namespace,
+			Labels:    map[string]string{managedByLabel: policyReportSource},
+		},
+		Results: []PolicyReportResult{},
+	}
+}
+
+// Adds a failed @result to the report
+func (report *PolicyReport) addResult(result PolicyReportResult) {
+	report.Results = append(report.Results, result)
+	report.Summary.Fail += 1
+}
+
+// Returns the wg-policy severity of @severity
+func policyReportSeverity(severity string) string {
+	if severity == "" {
+		return "info"
+	}
+	return strings.ToLower(severity)
+}
+
+// Returns a reference to a serviceAccount
+func serviceAccountReference(namespace string, name string) v1.ObjectReference {
+	return v1.ObjectReference{APIVersion: "v1", Kind: "ServiceAccount", Namespace: namespace, Name: name}
+}
+
+// Returns a reference to a node
+func nodeReference(name string) v1.ObjectReference {
+	return v1.ObjectReference{APIVersion: "v1", Kind: "Node", Name: name}
+}
+
+// Returns the pods hosting @sa, formatted as 'node: pod1, pod2; node2: pod3'
+func saPods(sa eval.ServiceAccountViolation) string {
+	var nodes []string
+	for _, nodeToPods := range sa.Nodes {
+		for node, pods := range nodeToPods {
+			nodes = append(nodes, node+": "+strings.Join(pods, ", "))
+		}
+	}
+	sort.Strings(nodes)
+	return strings.Join(nodes, "; ")
+}
+
+// Returns a human-readable name for @report
+func reportDisplayName(report PolicyReport) string {
+	if report.Metadata.Namespace == "" {
+		return report.Kind + " " + report.Metadata.Name
+	}
+	return report.Kind + " " + report.Metadata.Namespace + "/" + report.Metadata.Name
+}
@@ -0,0 +1,185 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+)
+
+var (
+	// Severities from highest to lowest, policies without a severity come last
+	severityOrder = []string{"Critical", "High", "Medium", "Low", ""}
+	severityRank  = map[string]int{"Critical": 0, "High": 1, "Medium": 2, "Low": 3, "": 4}
+)
+
+// Returns the results in @policyResults sorted by severity, and then by policy file
+func sortedResults(policyResults *eval.PolicyResults) []eval.PolicyResult {
+	results := append([]eval.PolicyResult{}, policyResults.PolicyResults...)
+	sort.SliceStable(results, func(i, j int) bool {
+		if rank(results[i].Severity) != rank(results[j].Severity) {
+			return rank(results[i].Severity) < rank(results[j].Severity)
+		}
+		return results[i].PolicyFile < results[j].PolicyFile
+	})
+	return results
+}
+
+// Returns the rank of @severity, lower is more severe
+func rank(severity string) int {
+	if r, ok := severityRank[severity]; ok {
+		return r
+	}
+	return len(severityOrder) // unknown severities come last
+}
+
+// Returns the identities that violated a policy, grouped by violation type.
+// In @short mode, serviceAccounts are listed by their full name only, and users without the groups they inherit violations from.
+func violationGroups(violations eval.Violations, userGrants []eval.UserGrant, short bool) []violationGroup {
+	var groups []violationGroup
+
+	if len(violations.ServiceAccounts) > 0 {
+		group := violationGroup{Type: "serviceAccounts"}
+		for _, sa := range violations.ServiceAccounts {
+			group.Identities = append(group.Identities, formatServiceAccount(sa, short))
+		}
+		groups = append(groups, group)
+	}
+	if len(violations.Nodes) > 0 {
+		groups = append(groups, violationGroup{Type: "nodes", Identities: violations.Nodes})
+	}
+	if len(violations.Combined) > 0 {
+		group := violationGroup{Type: "combined"}
+		for _, combined := range violations.Combined {
+			group.Identities = append(group.Identities, formatCombined(combined))
+		}
+		groups = append(groups, group)
+	}
+	if len(violations.Users) > 0 {
+		group := violationGroup{Type: "users"}
+		for _, user := range violations.Users {
+			group.Identities = append(group.Identities, formatUser(user, userGrants, short))
+		}
+		groups = append(groups, group)
+	}
+	if len(violations.Groups) > 0 {
+		groups = append(groups, violationGroup{Type: "groups", Identities: violations.Groups})
+	}
+	return groups
+}
+
+// Formats a serviceAccount violation, in full mode includes the pods hosting it and its provider IAM roles
+func formatServiceAccount(sa eval.ServiceAccountViolation, short bool) string {
+	fullName := utils.FullName(sa.Namespace, sa.Name)
+	if short {
+		return fullName
+	}
+
+	var details []string
+	for _, nodeToPods := range sa.Nodes {
+		for node, pods := range nodeToPods {
+			if node == "" {
+				node = "unscheduled"
+			}
+			details = append(details, fmt.Sprintf("%v: %v", node, strings.Join(pods, ", ")))
+		}
+	}
+	for provider, iam := range sa.ProviderIAM {
+		details = append(details, fmt.Sprintf("%v: %v", provider, iam))
+	}
+	if len(details) == 0 {
+		return fullName
+	}
+	sort.Strings(details)
+	return fmt.Sprintf("%v (%v)", fullName, strings.Join(details, "; "))
+}
+
+// Formats a user violation, in full mode includes the groups the user inherits the violation from
+func formatUser(user string, userGrants []eval.UserGrant, short bool) string {
+	if short {
+		return user
+	}
+	for _, grant := range userGrants {
+		if grant.User != user || len(grant.Groups) == 0 {
+			continue
+		}
+		via := "via " + strings.Join(grant.Groups, ", ")
+		if grant.Direct {
+			via = "directly and " + via
+		}
+		return fmt.Sprintf("%v (%v)", user, via)
+	}
+	return user
+}
+
+// Formats a combined violation
+func formatCombined(combined eval.CombinedViolation) string {
+	if len(combined.ServiceAccounts) == 0 {
+		return combined.Node
+	}
+	return fmt.Sprintf("%v + %v", combined.Node, strings.Join(combined.ServiceAccounts, ", "))
+}
+
+// Formats how a violating identity exercised the permissions that caused its violation, per audit logs
+func formatUsage(usage eval.ViolationUsage) string {
+	name := usage.Name
+	if usage.Type == "serviceAccount" {
+		name = utils.FullName(usage.Namespace, usage.Name)
+	}
+	if usage.Requests == 0 {
+		return fmt.Sprintf("%v: never exercised", name)
+	}
+
+	var permissions []string
+	for _, permission := range usage.Permissions {
+		target := permission.NonResourceURL
+		if target == "" {
+			target = permission.Resource
+			if permission.APIGroup != "" {
+				target += "." + permission.APIGroup
+			}
+			if permission.Namespace != "" {
+				target += " in " + permission.Namespace
+			}
+		}
+		permissions = append(permissions, fmt.Sprintf("%v %v (%d)", permission.Verb, target, permission.Requests))
+	}
+	formatted := fmt.Sprintf("%v: %d requests", name, usage.Requests)
+	if usage.LastUsed != nil {
+		formatted += ", last used " + usage.LastUsed.Format(time.RFC3339)
+	}
+	formatted += ", " + strings.Join(permissions, ", ")
+	if len(usage.SourceIPs) > 0 {
+		formatted += "; from " + strings.Join(usage.SourceIPs, ", ")
+	}
+	if len(usage.UserAgents) > 0 {
+		formatted += "; via " + strings.Join(usage.UserAgents, ", ")
+	}
+	return formatted
+}
+
+// Returns the number of identities per violation type in @violations
+func identityCounts(violations eval.Violations) identityCount {
+	return identityCount{
+		ServiceAccounts: len(violations.ServiceAccounts),
+		Nodes:           len(violations.Nodes),
+		Combined:        len(violations.Combined),
+		Users:           len(violations.Users),
+		Groups:          len(violations.Groups),
+	}
+}
+
+// Returns the name of a policy, its file name without the .rego suffix
+func policyName(policyFile string) string {
+	name := policyFile[strings.LastIndex(policyFile, "/")+1:]
+	return strings.TrimSuffix(name, ".rego")
+}
+
+// Returns a one line description of the summary
+func formatSummary(summary eval.Summary) string {
+	return fmt.Sprintf("%d failed, %d passed, %d errors, %d evaluated (skipped %d below severity threshold, %d filtered)",
+		summary.Failed, summary.Passed, summary.Errors, summary.Evaluated, summary.Skipped.BelowThreshold, summary.Skipped.Filtered)
+}
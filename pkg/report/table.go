@@ -0,0 +1,105 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+)
+
+// ANSI colors for severities
+var severityColors = map[string]string{
+	"Critical": "\033[1;31m", // bold red
+	"High":     "\033[31m",   // red
+	"Medium":   "\033[33m",   // yellow
+	"Low":      "\033[36m",   // cyan
+}
+
+const colorReset = "\033[0m"
+
+// Renders @policyResults as human-readable tables, grouped by policy.
+// In @short mode serviceAccounts are listed by name only, @color enables colorized severities.
+func Table(policyResults *eval.PolicyResults, short bool, color bool) string {
+	var out strings.Builder
+	results := sortedResults(policyResults)
+
+	// Overview table, one row per policy with identity counts
+	var overview bytes.Buffer
+	writer := tabwriter.NewWriter(&overview, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "SEVERITY\tID\tPOLICY\tSAS\tNODES\tCOMBINED\tUSERS\tGROUPS")
+	for _, result := range results {
+		counts := identityCounts(result.Violations)
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%d\t%d\t%d\t%d\t%d\n", severityCell(result.Severity), result.ID, policyName(result.PolicyFile),
+			counts.ServiceAccounts, counts.Nodes, counts.Combined, counts.Users, counts.Groups)
+	}
+	writer.Flush()
+	for i, line := range strings.Split(strings.TrimSuffix(overview.String(), "\n"), "\n") {
+		if i > 0 && color {
+			line = colorizeSeverityPrefix(line)
+		}
+		out.WriteString(line + "\n")
+	}
+
+	// Violations of each policy
+	for _, result := range results {
+		out.WriteString("\n")
+		header := fmt.Sprintf("[%v] %v %v", severityCell(result.Severity), result.ID, policyName(result.PolicyFile))
+		if color {
+			header = colorizeSeverityWord(header, result.Severity)
+		}
+		if result.Title != "" {
+			header += " - " + result.Title
+		}
+		out.WriteString(header + "\n")
+		if result.Description != "" {
+			out.WriteString("  " + result.Description + "\n")
+		}
+		if result.Remediation != "" {
+			out.WriteString("  Remediation: " + result.Remediation + "\n")
+		}
+		for _, group := range violationGroups(result.Violations, result.UserGrants, short) {
+			out.WriteString(fmt.Sprintf("  %v (%d):\n", group.Type, len(group.Identities)))
+			for _, identity := range group.Identities {
+				out.WriteString("    " + identity + "\n")
+			}
+		}
+		if len(result.Usage) > 0 {
+			out.WriteString(fmt.Sprintf("  usage (%d):\n", len(result.Usage)))
+			for _, usage := range result.Usage {
+				out.WriteString("    " + formatUsage(usage) + "\n")
+			}
+		}
+	}
+
+	out.WriteString("\nSummary: " + formatSummary(policyResults.Summary) + "\n")
+	return out.String()
+}
+
+// Returns the text shown for @severity
+func severityCell(severity string) string {
+	if severity == "" {
+		return "None"
+	}
+	return severity
+}
+
+// Colorizes the severity that begins @line
+func colorizeSeverityPrefix(line string) string {
+	for severity, color := range severityColors {
+		if strings.HasPrefix(line, severity) {
+			return color + severity + colorReset + line[len(severity):]
+		}
+	}
+	return line
+}
+
+// Colorizes the first occurrence of @severity in @text
+func colorizeSeverityWord(text string, severity string) string {
+	color, ok := severityColors[severity]
+	if !ok {
+		return text
+	}
+	return strings.Replace(text, severity, color+severity+colorReset, 1)
+}
@@ -0,0 +1,169 @@
+package report
+
+import (
+	"encoding/xml"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/expand"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Identities that violated a policy, of a certain violation type
+type violationGroup struct {
+	Type       string
+	Identities []string
+}
+
+// Number of identities that violated a policy, per violation type
+type identityCount struct {
+	ServiceAccounts int
+	Nodes           int
+	Combined        int
+	Users           int
+	Groups          int
+}
+
+// Data rendered into the HTML report
+type htmlReport struct {
+	Metadata   collect.ClusterMetadata
+	Summary    eval.Summary
+	Expanded   bool
+	Severities []htmlSeverityCount
+	Policies   []htmlPolicy
+	Identities []htmlIdentity
+}
+
+// Number of failed policies and violating identities of a severity
+type htmlSeverityCount struct {
+	Severity   string
+	Policies   int
+	Identities int
+}
+
+// A failed policy in the HTML report
+type htmlPolicy struct {
+	eval.PolicyResult
+	Anchor        string
+	Name          string
+	IdentityCount identityCount
+	Groups        []htmlViolationGroup
+}
+
+// Violations of a policy, of a certain violation type
+type htmlViolationGroup struct {
+	Type       string
+	Violations []htmlViolation
+}
+
+// A violation, linking to the identities involved in it
+type htmlViolation struct {
+	Text  string
+	Links []htmlLink
+}
+
+// A link to an identity in the HTML report
+type htmlLink struct {
+	Name   string
+	Anchor string
+}
+
+// An identity in the HTML report, its violated policies and, if available, its permissions
+type htmlIdentity struct {
+	Anchor   string
+	Type     string
+	Name     string
+	Severity string // highest severity of violated policies
+	Policies []htmlLink
+	Roles    []expand.ExpandedRole
+}
+
+// JUnit XML report
+type junitTestSuites struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Errors     int              `xml:"errors,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message  string `xml:"message,attr,omitempty"`
+	Type     string `xml:"type,attr,omitempty"`
+	Contents string `xml:",chardata"`
+}
+
+// A wg-policy PolicyReport or ClusterPolicyReport (wgpolicyk8s.io/v1alpha2)
+type PolicyReport struct {
+	ApiVersion string               `json:"apiVersion"`
+	Kind       string               `json:"kind"`
+	Metadata   policyReportMetadata `json:"metadata"`
+	Summary    policyReportSummary  `json:"summary"`
+	Results    []PolicyReportResult `json:"results"`
+}
+
+type policyReportMetadata struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+type policyReportSummary struct {
+	Pass  int `json:"pass"`
+	Fail  int `json:"fail"`
+	Warn  int `json:"warn"`
+	Error int `json:"error"`
+	Skip  int `json:"skip"`
+}
+
+// A violation of a policy by the identities in @Resources
+type PolicyReportResult struct {
+	Policy     string                `json:"policy"`
+	Rule       string                `json:"rule,omitempty"`
+	Category   string                `json:"category,omitempty"`
+	Severity   string                `json:"severity,omitempty"`
+	Result     string                `json:"result"`
+	Scored     bool                  `json:"scored"`
+	Source     string                `json:"source"`
+	Message    string                `json:"message,omitempty"`
+	Timestamp  policyReportTimestamp `json:"timestamp"`
+	Resources  []v1.ObjectReference  `json:"resources,omitempty"`
+	Properties map[string]string     `json:"properties,omitempty"`
+}
+
+type policyReportTimestamp struct {
+	Seconds int64 `json:"seconds"`
+	Nanos   int32 `json:"nanos"`
+}
+
+// AuditPolicyConfig holds the options for AuditPolicy()
+type AuditPolicyConfig struct {
+	EvalConfig     eval.EvalConfig // should match the eval run, to re-evaluate violated policies
+	BasePolicyFile string          // existing audit policy to merge the generated rules into
+	NodeUser       string          // user assigned to all nodes, nodes are identified as system:node:<name> if empty
+}
@@ -0,0 +1,366 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/attribute"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+	"github.com/open-policy-agent/opa/cover"
+	"github.com/open-policy-agent/opa/storage"
+	"github.com/open-policy-agent/opa/tester"
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	regoTestSuffix      = "_test.rego"
+	builtinsTestFile    = "builtins" + regoTestSuffix
+	fixtureFileName     = "expected.yaml"
+	defaultFixtureDir   = "cluster"
+	defaultNodeGroup    = "system:nodes"
+	lowestSeverity      = "Low"
+	defaultPrivilegedNs = "kube-system"
+)
+
+var (
+	// Hash set of dirs to ignore when looking for tests, matches eval
+	ignoredDirs = map[string]struct{}{
+		"ignore": {},
+		"utils":  {},
+	}
+)
+
+// Tests the policies under @policyPath using Rego tests (*_test.rego) and fixtures (expected.yaml)
+func Test(policyPath string) *TestResults {
+	policyFiles, err := eval.PolicyFiles(policyPath)
+	if err != nil {
+		return nil // error printed in PolicyFiles
+	}
+	if len(policyFiles) == 0 {
+		log.Errorln("Test: couldn't find policy files with '.rego' suffix under", policyPath)
+		return nil
+	}
+
+	// Look for tests next to the policies
+	testsDir := policyPath
+	if fileInfo, err := os.Stat(policyPath); err == nil && fileInfo.Mode().IsRegular() {
+		testsDir = filepath.Dir(policyPath)
+	}
+	regoTestFiles, fixtureFiles, err := findTests(testsDir)
+	if err != nil {
+		return nil
+	}
+
+	// Index policies by name, the file name without the .rego suffix
+	policiesByName := make(map[string]*PolicyTestResult)
+	var testResults TestResults
+	for _, policyFile := range policyFiles {
+		testResults.PolicyResults = append(testResults.PolicyResults, PolicyTestResult{PolicyFile: policyFile, Tests: []TestCaseResult{}})
+	}
+	for i := range testResults.PolicyResults {
+		policiesByName[policyName(testResults.PolicyResults[i].PolicyFile)] = &testResults.PolicyResults[i]
+	}
+
+	// Run Rego tests, builtins_test.rego tests the builtins library
+	var builtinsResult *PolicyTestResult
+	for _, regoTestFile := range regoTestFiles {
+		if filepath.Base(regoTestFile) == builtinsTestFile {
+			if builtinsResult == nil {
+				builtinsResult = &PolicyTestResult{PolicyFile: eval.BuiltinsFile(), Tests: []TestCaseResult{}}
+			}
+			runRegoTests(builtinsResult, []string{eval.BuiltinsFile()}, regoTestFile)
+			continue
+		}
+		policyResult, ok := policiesByName[strings.TrimSuffix(filepath.Base(regoTestFile), regoTestSuffix)]
+		if !ok {
+			log.Debugf("Test: ignoring %v, no matching policy under %v\n", regoTestFile, policyPath)
+			continue
+		}
+		regoFiles, err := eval.RegoFiles(policyResult.PolicyFile)
+		if err != nil {
+			policyResult.Tests = append(policyResult.Tests, TestCaseResult{Name: regoTestFile, Type: regoTestType, Error: err.Error()})
+			continue
+		}
+		runRegoTests(policyResult, regoFiles, regoTestFile)
+	}
+
+	// Run fixtures, errors in fixtures that can't be parsed aren't attributed to a policy
+	for _, fixtureFile := range fixtureFiles {
+		if err := runFixture(fixtureFile, policiesByName); err != nil {
+			testResults.Summary.Errors += 1
+		}
+	}
+
+	if builtinsResult != nil {
+		testResults.PolicyResults = append(testResults.PolicyResults, *builtinsResult)
+	}
+
+	// Summarize
+	for _, policyResult := range testResults.PolicyResults {
+		if len(policyResult.Tests) == 0 {
+			testResults.Summary.PoliciesUntested += 1
+			continue
+		}
+		testResults.Summary.PoliciesTested += 1
+		for _, testCase := range policyResult.Tests {
+			if testCase.Error != "" {
+				testResults.Summary.Errors += 1
+			} else if testCase.Passed {
+				testResults.Summary.Passed += 1
+			} else {
+				testResults.Summary.Failed += 1
+			}
+		}
+	}
+	return &testResults
+}
+
+// Find Rego test files and fixture files under @path
+func findTests(path string) ([]string, []string, error) {
+	var regoTestFiles, fixtureFiles []string
+	err := filepath.Walk(path+"/", // if main path is symlink, make Walk follow it
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if _, ok := ignoredDirs[info.Name()]; ok {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasSuffix(path, regoTestSuffix) {
+				regoTestFiles = append(regoTestFiles, path)
+			} else if info.Name() == fixtureFileName {
+				fixtureFiles = append(fixtureFiles, path)
+			}
+			return nil
+		})
+	if err != nil {
+		log.Errorf("findTests: failed to walk '%v' with %v\n", path, err)
+		return nil, nil, err
+	}
+	return regoTestFiles, fixtureFiles, nil
+}
+
+// Runs the test rules in @regoTestFile against the policy of @policyResult, loaded from @regoFiles, and records its coverage
+func runRegoTests(policyResult *PolicyTestResult, regoFiles []string, regoTestFile string) {
+	ctx := context.Background()
+
+	modules, store, err := tester.Load(append(regoFiles, regoTestFile), nil)
+	if err != nil {
+		log.Errorf("runRegoTests: failed to load %v with %v\n", regoTestFile, err)
+		policyResult.Tests = append(policyResult.Tests, TestCaseResult{Name: regoTestFile, Type: regoTestType, Error: err.Error()})
+		return
+	}
+
+	coverage := cover.New()
+	runner := tester.NewRunner().SetStore(store).SetModules(modules).SetCoverageQueryTracer(coverage)
+	err = storage.Txn(ctx, store, storage.TransactionParams{}, func(txn storage.Transaction) error {
+		resultsChan, err := runner.RunTests(ctx, txn)
+		if err != nil {
+			return err
+		}
+		for result := range resultsChan {
+			if result.Skip {
+				continue
+			}
+			testCase := TestCaseResult{
+				Name:   fmt.Sprintf("%v:%v", regoTestFile, result.Name),
+				Type:   regoTestType,
+				Passed: result.Pass(),
+			}
+			if result.Error != nil {
+				testCase.Error = result.Error.Error()
+			}
+			if len(result.Output) > 0 {
+				log.Debugf("runRegoTests: output from %v:\n%s", testCase.Name, result.Output)
+			}
+			policyResult.Tests = append(policyResult.Tests, testCase)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorf("runRegoTests: failed to run %v with %v\n", regoTestFile, err)
+		policyResult.Tests = append(policyResult.Tests, TestCaseResult{Name: regoTestFile, Type: regoTestType, Error: err.Error()})
+		return
+	}
+
+	// Record the coverage of the policy file itself
+	report := coverage.Report(modules)
+	if fileReport, ok := report.Files[policyResult.PolicyFile]; ok {
+		policyCoverage := fileReport.Coverage
+		policyResult.Coverage = &policyCoverage
+	}
+}
+
+// Evaluates the policies referenced by @fixtureFile against its offline cluster,
+// and compares the produced violations to the expected ones
+func runFixture(fixtureFile string, policiesByName map[string]*PolicyTestResult) error {
+	var fixture Fixture
+	fixtureBytes, err := utils.ReadFile(fixtureFile)
+	if err != nil {
+		return err
+	}
+	if err = yaml.Unmarshal(fixtureBytes, &fixture); err != nil {
+		log.Errorf("runFixture: failed to parse %v with %v\n", fixtureFile, err)
+		return err
+	}
+
+	// Only run if the fixture covers one of the tested policies
+	relevant := false
+	for name := range fixture.Expected {
+		if _, ok := policiesByName[name]; ok {
+			relevant = true
+			break
+		}
+	}
+	if !relevant {
+		return nil
+	}
+
+	// Collect from the fixture's offline cluster
+	if fixture.Cluster == "" {
+		fixture.Cluster = defaultFixtureDir
+	}
+	collectResult := collect.Collect(collect.CollectConfig{
+		OfflineDir:         filepath.Join(filepath.Dir(fixtureFile), fixture.Cluster),
+		AllServiceAccounts: fixture.AllServiceAccounts,
+		NodeGroups:         []string{defaultNodeGroup},
+	})
+	if collectResult == nil {
+		for name := range fixture.Expected {
+			if policyResult, ok := policiesByName[name]; ok {
+				policyResult.Tests = append(policyResult.Tests, TestCaseResult{Name: fixtureFile, Type: fixtureTestType, Error: errFixtureCollect.Error()})
+			}
+		}
+		return nil
+	}
+	collectResult.Metadata.Features = append(collectResult.Metadata.Features, fixture.Features...)
+	if len(fixture.PrivilegedNamespaces) == 0 {
+		fixture.PrivilegedNamespaces = []string{defaultPrivilegedNs}
+	}
+	evalConfig := eval.EvalConfig{
+		SeverityThreshold:    lowestSeverity,
+		PrivilegedNamespaces: fixture.PrivilegedNamespaces,
+		SaViolations:         true,
+		NodeViolations:       true,
+		CombinedViolations:   true,
+		UserViolations:       true,
+		GroupViolations:      true,
+	}
+
+	// Go over expected policies in a stable order
+	var names []string
+	for name := range fixture.Expected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		expected := fixture.Expected[name]
+		policyResult, ok := policiesByName[name]
+		if !ok {
+			continue
+		}
+		testCase := TestCaseResult{Name: fixtureFile, Type: fixtureTestType}
+		policyResults := eval.Eval(policyResult.PolicyFile, *collectResult, evalConfig)
+		if policyResults == nil || policyResults.Summary.Errors > 0 {
+			testCase.Error = errFixtureEval.Error()
+			policyResult.Tests = append(policyResult.Tests, testCase)
+			continue
+		}
+
+		var actual eval.AbbreviatedViolations
+		abbreviatedResults := eval.AbbreviateResults(policyResults)
+		if len(abbreviatedResults.PolicyResults) > 0 {
+			actual = abbreviatedResults.PolicyResults[0].Violations
+		}
+		testCase.Missing = diffViolations(expected, actual)
+		testCase.Unexpected = diffViolations(actual, expected)
+		if fixture.Attributed {
+			testCase.Unattributed, err = unattributedIdentities(*policyResults, *collectResult, evalConfig)
+			if err != nil {
+				testCase.Error = err.Error()
+				policyResult.Tests = append(policyResult.Tests, testCase)
+				continue
+			}
+		}
+		testCase.Passed = testCase.Missing == nil && testCase.Unexpected == nil && testCase.Unattributed == nil
+		policyResult.Tests = append(policyResult.Tests, testCase)
+	}
+	return nil
+}
+
+// Returns the identities whose violations in @policyResults can't be attributed to the rules that cause them,
+// as remediate, audit policies and audit log correlation would fall back to all of their rules
+func unattributedIdentities(policyResults eval.PolicyResults, collectResult collect.CollectResult, evalConfig eval.EvalConfig) ([]string, error) {
+	attributions, err := attribute.AttributeViolations(policyResults, collectResult, evalConfig)
+	if err != nil {
+		return nil, err // error printed in AttributeViolations
+	}
+	var unattributed []string
+	for _, attribution := range attributions {
+		if !attribution.Attributed {
+			unattributed = append(unattributed, attribution.Identity.Type+" "+attribute.IdentityName(attribution.Identity))
+		}
+	}
+	return unattributed, nil
+}
+
+// Returns the violations in @violations that aren't in @other, nil if there are none
+func diffViolations(violations eval.AbbreviatedViolations, other eval.AbbreviatedViolations) *eval.AbbreviatedViolations {
+	var diff eval.AbbreviatedViolations
+	diff.ServiceAccounts = diffStrings(violations.ServiceAccounts, other.ServiceAccounts)
+	diff.Nodes = diffStrings(violations.Nodes, other.Nodes)
+	diff.Users = diffStrings(violations.Users, other.Users)
+	diff.Groups = diffStrings(violations.Groups, other.Groups)
+
+	otherCombined := make(map[string]struct{})
+	for _, combinedViolation := range other.Combined {
+		otherCombined[combinedKey(combinedViolation)] = struct{}{}
+	}
+	for _, combinedViolation := range violations.Combined {
+		if _, ok := otherCombined[combinedKey(combinedViolation)]; !ok {
+			diff.Combined = append(diff.Combined, combinedViolation)
+		}
+	}
+
+	if diff.ServiceAccounts == nil && diff.Nodes == nil && diff.Users == nil && diff.Groups == nil && diff.Combined == nil {
+		return nil
+	}
+	return &diff
+}
+
+// Returns the strings in @strs that aren't in @other
+func diffStrings(strs []string, other []string) []string {
+	var diff []string
+	otherSet := make(map[string]struct{})
+	for _, str := range other {
+		otherSet[str] = struct{}{}
+	}
+	for _, str := range strs {
+		if _, ok := otherSet[str]; !ok {
+			diff = append(diff, str)
+		}
+	}
+	return diff
+}
+
+// Returns a key identifying @combinedViolation regardless of the order of its serviceAccounts
+func combinedKey(combinedViolation eval.CombinedViolation) string {
+	serviceAccounts := append([]string{}, combinedViolation.ServiceAccounts...)
+	sort.Strings(serviceAccounts)
+	return combinedViolation.Node + "/" + strings.Join(serviceAccounts, ",")
+}
+
+// Returns the name of a policy, its file name without the .rego suffix
+func policyName(policyFile string) string {
+	return strings.TrimSuffix(filepath.Base(policyFile), ".rego")
+}
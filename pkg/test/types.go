@@ -0,0 +1,60 @@
+package test
+
+import (
+	"errors"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+)
+
+// Results of testing policies, result of Test()
+type TestResults struct {
+	PolicyResults []PolicyTestResult `json:"policyResults"`
+	Summary       TestSummary        `json:"summary"`
+}
+
+// Test results of a policy
+type PolicyTestResult struct {
+	PolicyFile string           `json:"policy"`
+	Coverage   *float64         `json:"coverage,omitempty"` // percent of policy lines covered by Rego tests
+	Tests      []TestCaseResult `json:"tests"`
+}
+
+// Result of a single Rego test rule or fixture
+type TestCaseResult struct {
+	Name         string                      `json:"name"`
+	Type         string                      `json:"type"`
+	Passed       bool                        `json:"passed"`
+	Error        string                      `json:"error,omitempty"`
+	Missing      *eval.AbbreviatedViolations `json:"missing,omitempty"`      // expected violations the policy didn't produce
+	Unexpected   *eval.AbbreviatedViolations `json:"unexpected,omitempty"`   // violations the policy produced that weren't expected
+	Unattributed []string                    `json:"unattributed,omitempty"` // identities whose violations couldn't be attributed to specific rules
+}
+
+// Summary of all tests
+type TestSummary struct {
+	Passed           int `json:"passed"`
+	Failed           int `json:"failed"`
+	Errors           int `json:"errors"`
+	PoliciesTested   int `json:"policiesTested"`
+	PoliciesUntested int `json:"policiesUntested"`
+}
+
+// A fixture, an offline cluster and the violations policies are expected to produce for it
+type Fixture struct {
+	Cluster              string                                `json:"cluster"` // offline cluster dir, relative to the fixture file
+	AllServiceAccounts   bool                                  `json:"allServiceAccounts"`
+	Features             []string                              `json:"features"` // added to the cluster's metadata
+	PrivilegedNamespaces []string                              `json:"privilegedNamespaces"`
+	Attributed           bool                                  `json:"attributed"` // check violations can be attributed to the rules that cause them
+	Expected             map[string]eval.AbbreviatedViolations `json:"expected"`   // keyed by policy file name
+}
+
+const (
+	regoTestType    = "rego"
+	fixtureTestType = "fixture"
+)
+
+var (
+	errFixtureCollect = errors.New("failed to collect from the fixture's cluster")
+	errFixtureEval    = errors.New("failed to evaluate the policy on the fixture's cluster")
+)
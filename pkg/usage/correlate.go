@@ -0,0 +1,167 @@
+package usage
+
+import (
+	"sort"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/attribute"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/audit"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+	log "github.com/sirupsen/logrus"
+)
+
+// How a violating identity exercised the rules that cause its violation
+type violationActivity struct {
+	Attribution attribute.Attribution
+	Usage       eval.ViolationUsage
+	Permissions map[usageKey]*eval.ExercisedPermission
+	SourceIPs   map[string]struct{}
+	UserAgents  map[string]struct{}
+}
+
+// Correlate annotates each violation in @policyResults with how the violating identity exercised the permissions that
+// cause it, per the audit logs at @auditLogs: when they were last used, by how many requests, and from which source IPs
+// and user agents. Violations are attributed to the rules that cause them by re-evaluating the violated policies against
+// @collectResult, so policies must be at the paths recorded in @policyResults.
+func Correlate(policyResults *eval.PolicyResults, collectResult collect.CollectResult, auditLogs []string, evalConfig eval.EvalConfig) error {
+	attributions, err := attribute.AttributeViolations(*policyResults, collectResult, evalConfig)
+	if err != nil {
+		return err // error printed in AttributeViolations
+	}
+
+	// Index violations by the identities whose rules cause them, users or groups that requests are made as
+	var activities []*violationActivity
+	byOwner := make(map[string][]*violationActivity)
+	for _, attribution := range attributions {
+		activity := &violationActivity{
+			Attribution: attribution,
+			Usage: eval.ViolationUsage{
+				Type:      attribution.Identity.Type,
+				Name:      attribution.Identity.Name,
+				Namespace: attribution.Identity.Namespace,
+			},
+			Permissions: make(map[usageKey]*eval.ExercisedPermission),
+			SourceIPs:   make(map[string]struct{}),
+			UserAgents:  make(map[string]struct{}),
+		}
+		activities = append(activities, activity)
+		indexed := make(map[string]struct{})
+		for _, rule := range attribution.Rules {
+			key := rule.OwnerType + "/" + rule.OwnerName
+			if _, ok := indexed[key]; !ok {
+				indexed[key] = struct{}{}
+				byOwner[key] = append(byOwner[key], activity)
+			}
+		}
+	}
+
+	err = audit.ReadEvents(auditLogs, func(event audit.Event) {
+		owners := []string{identityKey(audit.EventIdentity(event))}
+		groups := event.User.Groups
+		if event.ImpersonatedUser != nil && event.ImpersonatedUser.Username != "" {
+			groups = event.ImpersonatedUser.Groups
+		}
+		for _, group := range groups {
+			owners = append(owners, "group/"+group)
+		}
+		for _, owner := range owners {
+			for _, activity := range byOwner[owner] {
+				activity.record(owner, event)
+			}
+		}
+	})
+	if err != nil {
+		return err // error printed in ReadEvents
+	}
+
+	// Annotate policy results, in the order of their violations
+	for i := range policyResults.PolicyResults {
+		policyResult := &policyResults.PolicyResults[i]
+		policyResult.Usage = nil
+		for _, activity := range activities {
+			if activity.Attribution.PolicyFile == policyResult.PolicyFile {
+				policyResult.Usage = append(policyResult.Usage, activity.result())
+			}
+		}
+	}
+	log.Debugf("Correlate: correlated %d violations with audit logs\n", len(activities))
+	return nil
+}
+
+// Records @event, made as @owner, if it exercised one of the rules of @owner that cause the violation
+func (activity *violationActivity) record(owner string, event audit.Event) {
+	uKey := eventUsageKey(event)
+	entry := usageEntry{Names: make(map[string]struct{})}
+	if event.ObjectRef != nil && event.ObjectRef.Name != "" {
+		entry.Names[event.ObjectRef.Name] = struct{}{}
+	} else {
+		entry.Unnamed = true
+	}
+
+	exercised := false
+	for _, rule := range activity.Attribution.Rules {
+		if rule.OwnerType+"/"+rule.OwnerName == owner && ruleCovers(rule.Rule, rule.Namespace, uKey, &entry) {
+			exercised = true
+			break
+		}
+	}
+	if !exercised {
+		return
+	}
+
+	permission, ok := activity.Permissions[uKey]
+	if !ok {
+		permission = &eval.ExercisedPermission{
+			Verb:           uKey.Verb,
+			Namespace:      uKey.Namespace,
+			APIGroup:       uKey.APIGroup,
+			Resource:       uKey.Resource,
+			NonResourceURL: uKey.NonResourceURL,
+		}
+		activity.Permissions[uKey] = permission
+	}
+	permission.Requests += 1
+	activity.Usage.Requests += 1
+	if eventTime := event.Time(); !eventTime.IsZero() {
+		if permission.LastUsed == nil || eventTime.After(*permission.LastUsed) {
+			permission.LastUsed = timePtr(eventTime)
+		}
+		if activity.Usage.LastUsed == nil || eventTime.After(*activity.Usage.LastUsed) {
+			activity.Usage.LastUsed = timePtr(eventTime)
+		}
+	}
+	for _, sourceIP := range event.SourceIPs {
+		activity.SourceIPs[sourceIP] = struct{}{}
+	}
+	if event.UserAgent != "" {
+		activity.UserAgents[event.UserAgent] = struct{}{}
+	}
+}
+
+// Returns the usage recorded in @activity, with permissions sorted by their number of requests
+func (activity *violationActivity) result() eval.ViolationUsage {
+	usage := activity.Usage
+	for _, permission := range activity.Permissions {
+		usage.Permissions = append(usage.Permissions, *permission)
+	}
+	sort.Slice(usage.Permissions, func(i, j int) bool {
+		a, b := usage.Permissions[i], usage.Permissions[j]
+		if a.Requests != b.Requests {
+			return a.Requests > b.Requests
+		}
+		return a.Namespace+"/"+a.APIGroup+"/"+a.Resource+a.NonResourceURL+"/"+a.Verb < b.Namespace+"/"+b.APIGroup+"/"+b.Resource+b.NonResourceURL+"/"+b.Verb
+	})
+	usage.SourceIPs = sortedKeys(activity.SourceIPs)
+	usage.UserAgents = sortedKeys(activity.UserAgents)
+	return usage
+}
+
+// Returns the sorted keys of @set
+func sortedKeys(set map[string]struct{}) []string {
+	var keys []string
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,273 @@
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/audit"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	rbac "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	proposedRolePrefix = "least-privilege:"
+	manifestHeader     = `# Generated by rbac-police least-privilege, review before applying.
+# Covers only the requests seen in the audit logs that were granted by the identity's own roles,
+# remove the identity from its current bindings once the proposed roles are in place.
+`
+)
+
+// Proposes minimal roles for @identity that grant the requests of @usage that were granted by the identity's own roles.
+// Requests in a namespace are granted by a Role in that namespace, cluster-wide requests by a ClusterRole.
+func proposeRoles(identity audit.Identity, usage map[usageKey]*usageEntry, grantedBy map[usageKey]string) []collect.RoleEntry {
+	// Map each namespace to the verbs used on each resource, the empty namespace denotes cluster-wide requests
+	type resourceKey struct {
+		APIGroup       string
+		Resource       string
+		NonResourceURL string
+	}
+	scopes := make(map[string]map[resourceKey][]string)
+	for uKey := range usage {
+		if grantedBy[uKey] != GrantedByIdentity {
+			continue
+		}
+		if _, ok := scopes[uKey.Namespace]; !ok {
+			scopes[uKey.Namespace] = make(map[resourceKey][]string)
+		}
+		rKey := resourceKey{uKey.APIGroup, uKey.Resource, uKey.NonResourceURL}
+		scopes[uKey.Namespace][rKey] = append(scopes[uKey.Namespace][rKey], uKey.Verb)
+	}
+
+	var roles []collect.RoleEntry
+	for namespace, resources := range scopes {
+		// Merge resources of the same API group that are used with the same verbs into one rule
+		ruleIndexes := make(map[string]int)
+		var rules []rbac.PolicyRule
+		for rKey, verbs := range resources {
+			sort.Strings(verbs)
+			groupKey := strings.Join(verbs, ",") + "|" + rKey.APIGroup
+			if rKey.NonResourceURL != "" {
+				groupKey = strings.Join(verbs, ",") + "|nonResource"
+			}
+			index, ok := ruleIndexes[groupKey]
+			if !ok {
+				index = len(rules)
+				ruleIndexes[groupKey] = index
+				rule := rbac.PolicyRule{Verbs: verbs}
+				if rKey.NonResourceURL == "" {
+					rule.APIGroups = []string{rKey.APIGroup}
+				}
+				rules = append(rules, rule)
+			}
+			if rKey.NonResourceURL != "" {
+				rules[index].NonResourceURLs = append(rules[index].NonResourceURLs, rKey.NonResourceURL)
+			} else {
+				rules[index].Resources = append(rules[index].Resources, rKey.Resource)
+			}
+		}
+		for i := range rules {
+			sort.Strings(rules[i].Resources)
+			sort.Strings(rules[i].NonResourceURLs)
+		}
+		sort.Slice(rules, func(i, j int) bool { return ruleSortKey(rules[i]) < ruleSortKey(rules[j]) })
+		roles = append(roles, collect.RoleEntry{Name: proposedRolePrefix + identityName(identity), Namespace: namespace, Rules: rules})
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Namespace < roles[j].Namespace })
+	return roles
+}
+
+// Returns a key for sorting rules deterministically
+func ruleSortKey(rule rbac.PolicyRule) string {
+	return strings.Join(rule.APIGroups, ",") + "|" + strings.Join(rule.Resources, ",") + "|" + strings.Join(rule.NonResourceURLs, ",") + "|" + strings.Join(rule.Verbs, ",")
+}
+
+// Re-evaluates the policies at usageConfig.PolicyPath against a copy of @collectResult where each analyzed identity
+// is only granted its proposed roles, and records which violations disappear. Returns false on error.
+func compareViolations(result *UsageResult, collectResult collect.CollectResult, usageConfig UsageConfig) bool {
+	evalConfig := usageConfig.EvalConfig
+	evalConfig.SaViolations, evalConfig.UserViolations = true, true
+	evalConfig.SeverityThreshold = "Low"
+
+	before := eval.Eval(usageConfig.PolicyPath, collectResult, evalConfig)
+	if before == nil {
+		return false // error printed in Eval
+	}
+	minimalCollectResult, err := withProposedRoles(collectResult, result.Identities)
+	if err != nil {
+		return false
+	}
+	after := eval.Eval(usageConfig.PolicyPath, minimalCollectResult, evalConfig)
+	if after == nil {
+		return false // error printed in Eval
+	}
+
+	violationsBefore, violationsAfter := violatedPolicies(before), violatedPolicies(after)
+	for i := range result.Identities {
+		identityUsage := &result.Identities[i]
+		key := identityKey(audit.Identity{Type: identityUsage.Type, Name: identityUsage.Name, Namespace: identityUsage.Namespace})
+		for _, policyID := range violationsBefore[key] {
+			if utils.Contains(violationsAfter[key], policyID) {
+				identityUsage.RemainingViolations = append(identityUsage.RemainingViolations, policyID)
+			} else {
+				identityUsage.ResolvedViolations = append(identityUsage.ResolvedViolations, policyID)
+			}
+		}
+		result.Summary.ResolvedViolations += len(identityUsage.ResolvedViolations)
+		result.Summary.RemainingViolations += len(identityUsage.RemainingViolations)
+	}
+	return true
+}
+
+// Returns a copy of @collectResult where each of @identities is only granted its proposed roles
+func withProposedRoles(collectResult collect.CollectResult, identities []IdentityUsage) (collect.CollectResult, error) {
+	var minimalCollectResult collect.CollectResult
+	collectResultBytes, err := json.Marshal(collectResult)
+	if err == nil {
+		err = json.Unmarshal(collectResultBytes, &minimalCollectResult)
+	}
+	if err != nil {
+		log.Errorf("withProposedRoles: failed to copy CollectResult with %v\n", err)
+		return minimalCollectResult, err
+	}
+
+	proposed := make(map[string][]collect.RoleRef)
+	for _, identityUsage := range identities {
+		var roleRefs []collect.RoleRef
+		for _, role := range identityUsage.ProposedRoles {
+			roleRefs = append(roleRefs, collect.RoleRef{Name: role.Name, Namespace: role.Namespace, EffectiveNamespace: role.Namespace})
+			minimalCollectResult.Roles = append(minimalCollectResult.Roles, role)
+		}
+		proposed[identityKey(audit.Identity{Type: identityUsage.Type, Name: identityUsage.Name, Namespace: identityUsage.Namespace})] = roleRefs
+	}
+	for i, sa := range minimalCollectResult.ServiceAccounts {
+		if roleRefs, ok := proposed[identityKey(audit.Identity{Type: "serviceAccount", Name: sa.Name, Namespace: sa.Namespace})]; ok {
+			minimalCollectResult.ServiceAccounts[i].Roles = roleRefs
+		}
+	}
+	for i, user := range minimalCollectResult.Users {
+		if roleRefs, ok := proposed[identityKey(audit.Identity{Type: "user", Name: user.Name})]; ok {
+			for _, roleRef := range user.Roles {
+				if roleRef.Group != "" {
+					roleRefs = append(roleRefs, roleRef) // inherited from groups, whose roles aren't proposed
+				}
+			}
+			minimalCollectResult.Users[i].Roles = roleRefs
+		}
+	}
+	return minimalCollectResult, nil
+}
+
+// Maps each serviceAccount and user that violates @policyResults to the IDs of the policies it violates
+func violatedPolicies(policyResults *eval.PolicyResults) map[string][]string {
+	violated := make(map[string][]string)
+	for _, policyResult := range policyResults.PolicyResults {
+		policyID := policyResult.ID
+		if policyID == "" {
+			policyID = policyResult.PolicyFile
+		}
+		for _, sa := range policyResult.Violations.ServiceAccounts {
+			key := identityKey(audit.Identity{Type: "serviceAccount", Name: sa.Name, Namespace: sa.Namespace})
+			violated[key] = append(violated[key], policyID)
+		}
+		for _, user := range policyResult.Violations.Users {
+			key := identityKey(audit.Identity{Type: "user", Name: user})
+			violated[key] = append(violated[key], policyID)
+		}
+	}
+	return violated
+}
+
+// Writes the proposed roles of each identity in @result, and the bindings granting them, as manifests under @outDir
+func writeManifests(result *UsageResult, outDir string) error {
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		log.Errorf("writeManifests: failed to create %v with %v\n", outDir, err)
+		return err
+	}
+	for i := range result.Identities {
+		identityUsage := &result.Identities[i]
+		if len(identityUsage.ProposedRoles) == 0 {
+			continue
+		}
+		var manifests []string
+		for _, role := range identityUsage.ProposedRoles {
+			manifests = append(manifests, roleManifest(role), bindingManifest(role, *identityUsage))
+		}
+		fileName := fmt.Sprintf("%02d-%v-%v.yaml", i+1, identityUsage.Type, fileNameSafe(identityName(audit.Identity{Type: identityUsage.Type, Name: identityUsage.Name, Namespace: identityUsage.Namespace})))
+		path := filepath.Join(outDir, fileName)
+		if err := os.WriteFile(path, []byte(manifestHeader+"---\n"+strings.Join(manifests, "---\n")), 0644); err != nil {
+			log.Errorf("writeManifests: failed to write %v with %v\n", path, err)
+			return err
+		}
+		identityUsage.File = path
+	}
+	return nil
+}
+
+// Returns a manifest of @role
+func roleManifest(role collect.RoleEntry) string {
+	kind := "ClusterRole"
+	metadata := map[string]interface{}{"name": role.Name}
+	if role.Namespace != "" {
+		kind = "Role"
+		metadata["namespace"] = role.Namespace
+	}
+	return marshalManifest(map[string]interface{}{
+		"apiVersion": rbac.SchemeGroupVersion.String(),
+		"kind":       kind,
+		"metadata":   metadata,
+		"rules":      role.Rules,
+	})
+}
+
+// Returns a manifest of a binding granting @role to the identity of @identityUsage
+func bindingManifest(role collect.RoleEntry, identityUsage IdentityUsage) string {
+	kind, roleKind := "ClusterRoleBinding", "ClusterRole"
+	metadata := map[string]interface{}{"name": role.Name}
+	if role.Namespace != "" {
+		kind, roleKind = "RoleBinding", "Role"
+		metadata["namespace"] = role.Namespace
+	}
+	subject := map[string]interface{}{"kind": "User", "apiGroup": rbac.GroupName, "name": identityUsage.Name}
+	if identityUsage.Type == "serviceAccount" {
+		subject = map[string]interface{}{"kind": "ServiceAccount", "name": identityUsage.Name, "namespace": identityUsage.Namespace}
+	}
+	return marshalManifest(map[string]interface{}{
+		"apiVersion": rbac.SchemeGroupVersion.String(),
+		"kind":       kind,
+		"metadata":   metadata,
+		"roleRef": map[string]interface{}{
+			"apiGroup": rbac.GroupName,
+			"kind":     roleKind,
+			"name":     role.Name,
+		},
+		"subjects": []map[string]interface{}{subject},
+	})
+}
+
+// Marshals @manifest into YAML
+func marshalManifest(manifest map[string]interface{}) string {
+	manifestBytes, err := yaml.Marshal(manifest)
+	if err != nil {
+		log.Errorf("marshalManifest: failed to marshal manifest with %v\n", err)
+		return ""
+	}
+	return string(manifestBytes)
+}
+
+// Returns @name with characters that are unsafe in file names replaced
+func fileNameSafe(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '-'
+	}, name)
+}
@@ -0,0 +1,102 @@
+package usage
+
+import (
+	"time"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/eval"
+	rbac "k8s.io/api/rbac/v1"
+)
+
+// UsageConfig holds the options for Analyze()
+type UsageConfig struct {
+	AuditLogs  []string
+	PolicyPath string // policies to re-evaluate against the proposed roles, empty to skip
+	EvalConfig eval.EvalConfig
+	OutDir     string
+}
+
+// Result of Analyze()
+type UsageResult struct {
+	Metadata   collect.ClusterMetadata `json:"metadata"`
+	Identities []IdentityUsage         `json:"identities"`
+	Summary    Summary                 `json:"summary"`
+}
+
+// The permissions an identity exercised in the audit logs, compared against the permissions granted to it
+type IdentityUsage struct {
+	Type                string              `json:"type"` // serviceAccount or user
+	Name                string              `json:"name"`
+	Namespace           string              `json:"namespace,omitempty"`
+	Requests            int                 `json:"requests"`
+	FirstSeen           *time.Time          `json:"firstSeen,omitempty"`
+	LastSeen            *time.Time          `json:"lastSeen,omitempty"`
+	Used                []UsedPermission    `json:"used,omitempty"`
+	UnusedGrants        []UnusedGrant       `json:"unusedGrants,omitempty"`
+	ProposedRoles       []collect.RoleEntry `json:"proposedRoles,omitempty"`
+	ResolvedViolations  []string            `json:"resolvedViolations,omitempty"`  // policies the identity no longer violates under the proposed roles
+	RemainingViolations []string            `json:"remainingViolations,omitempty"` // policies the identity still violates under the proposed roles
+	File                string              `json:"file,omitempty"`                // file holding the proposed roles' manifests
+}
+
+// Requests an identity made with certain verbs against a resource or a non-resource URL
+type UsedPermission struct {
+	Namespace      string   `json:"namespace,omitempty"` // empty for cluster scoped requests
+	APIGroup       string   `json:"apiGroup"`
+	Resource       string   `json:"resource,omitempty"`
+	NonResourceURL string   `json:"nonResourceURL,omitempty"`
+	Verbs          []string `json:"verbs"`
+	Requests       int      `json:"requests"`
+	GrantedBy      string   `json:"grantedBy"` // one of the GrantedBy constants
+}
+
+const (
+	GrantedByIdentity = "identity" // a role bound to the identity itself
+	GrantedByGroup    = "group"    // a role bound to a group the identity is a member of
+	GrantedByOther    = "other"    // no role in the RBAC data, e.g. the NodeAuthorizer or a webhook
+)
+
+// A rule granted to an identity that it didn't fully exercise
+type UnusedGrant struct {
+	Role               string          `json:"role"`
+	EffectiveNamespace string          `json:"effectiveNamespace,omitempty"`
+	Rule               rbac.PolicyRule `json:"rule"`
+	Unused             bool            `json:"unused"` // no request exercised the rule
+	UnusedVerbs        []string        `json:"unusedVerbs,omitempty"`
+	UnusedResources    []string        `json:"unusedResources,omitempty"`
+}
+
+// Summary of Analyze()
+type Summary struct {
+	Requests            int `json:"requests"`            // authorized requests in the audit logs
+	Identities          int `json:"identities"`          // analyzed identities
+	Inactive            int `json:"inactive"`            // analyzed identities that made no requests
+	UnusedGrants        int `json:"unusedGrants"`        // rules that weren't fully exercised
+	ResolvedViolations  int `json:"resolvedViolations"`  // violations that disappear under the proposed roles
+	RemainingViolations int `json:"remainingViolations"` // violations that remain under the proposed roles
+}
+
+// A kind of request an identity made, aggregated across requests
+type usageKey struct {
+	Namespace      string
+	APIGroup       string
+	Resource       string
+	NonResourceURL string
+	Verb           string
+}
+
+// Requests an identity made of a certain kind
+type usageEntry struct {
+	Requests int
+	Names    map[string]struct{} // names of the requested objects, for matching rules with resourceNames
+	Unnamed  bool                // whether some requests didn't target a named object
+}
+
+// Requests made by an identity
+type identityActivity struct {
+	Requests  int
+	FirstSeen time.Time
+	LastSeen  time.Time
+	Groups    map[string]struct{}
+	Usage     map[usageKey]*usageEntry
+}
@@ -0,0 +1,387 @@
+package usage
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PaloAltoNetworks/rbac-police/pkg/audit"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/collect"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/expand"
+	"github.com/PaloAltoNetworks/rbac-police/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	rbac "k8s.io/api/rbac/v1"
+)
+
+// Aggregates the permissions each identity exercised in the audit logs of @usageConfig, and compares them
+// against the permissions granted to it in @collectResult. Proposes a minimal set of roles per identity,
+// and if @usageConfig.PolicyPath is set, flags which violations would disappear under them.
+func Analyze(collectResult collect.CollectResult, usageConfig UsageConfig) *UsageResult {
+	result := UsageResult{Metadata: collectResult.Metadata}
+
+	activities := make(map[string]*identityActivity)
+	skippedNodes := 0
+	err := audit.ReadEvents(usageConfig.AuditLogs, func(event audit.Event) {
+		identity := audit.EventIdentity(event)
+		if identity.Type == "node" {
+			skippedNodes += 1 // nodes are authorized by the NodeAuthorizer, not by roles they can be stripped of
+			return
+		}
+		result.Summary.Requests += 1
+		recordEvent(activities, identity, event)
+	})
+	if err != nil {
+		return nil // error printed in ReadEvents
+	}
+	if skippedNodes > 0 {
+		log.Debugf("Analyze: ignored %d requests by nodes\n", skippedNodes)
+	}
+
+	expandResult := expand.Expand(collectResult)
+	if expandResult == nil {
+		return nil // error printed in Expand
+	}
+	groupRoles := make(map[string][]expand.ExpandedRole)
+	for _, group := range expandResult.Groups {
+		groupRoles[group.Name] = group.Roles
+	}
+
+	// Analyze identities that are granted roles
+	for _, sa := range expandResult.ServiceAccounts {
+		if len(sa.Roles) == 0 {
+			continue
+		}
+		identity := audit.Identity{Type: "serviceAccount", Name: sa.Name, Namespace: sa.Namespace}
+		activity := activities[identityKey(identity)]
+		groups := []string{"system:authenticated", "system:serviceaccounts", "system:serviceaccounts:" + sa.Namespace}
+		result.Identities = append(result.Identities, analyzeIdentity(identity, activity, sa.Roles, rolesOfGroups(groupRoles, groups, activity)))
+	}
+	for _, user := range expandResult.Users {
+		// Roles users inherit from a group membership source are granted to their groups, not to them
+		var directRoles, inheritedRoles []expand.ExpandedRole
+		for _, role := range user.Roles {
+			if role.Group == "" {
+				directRoles = append(directRoles, role)
+			} else {
+				inheritedRoles = append(inheritedRoles, role)
+			}
+		}
+		if len(directRoles) == 0 {
+			continue
+		}
+		identity := audit.Identity{Type: "user", Name: user.Name}
+		activity := activities[identityKey(identity)]
+		groups := []string{"system:authenticated"}
+		result.Identities = append(result.Identities, analyzeIdentity(identity, activity, directRoles, append(inheritedRoles, rolesOfGroups(groupRoles, groups, activity)...)))
+	}
+
+	for _, identityUsage := range result.Identities {
+		result.Summary.Identities += 1
+		if identityUsage.Requests == 0 {
+			result.Summary.Inactive += 1
+		}
+		result.Summary.UnusedGrants += len(identityUsage.UnusedGrants)
+	}
+
+	if usageConfig.PolicyPath != "" {
+		if !compareViolations(&result, collectResult, usageConfig) {
+			return nil // error printed in compareViolations
+		}
+	}
+	if usageConfig.OutDir != "" {
+		if err := writeManifests(&result, usageConfig.OutDir); err != nil {
+			return nil // error printed in writeManifests
+		}
+	}
+	return &result
+}
+
+// Records @event in the activity of @identity
+func recordEvent(activities map[string]*identityActivity, identity audit.Identity, event audit.Event) {
+	key := identityKey(identity)
+	activity, ok := activities[key]
+	if !ok {
+		activity = &identityActivity{Groups: make(map[string]struct{}), Usage: make(map[usageKey]*usageEntry)}
+		activities[key] = activity
+	}
+	activity.Requests += 1
+	eventTime := event.Time()
+	if !eventTime.IsZero() {
+		if activity.FirstSeen.IsZero() || eventTime.Before(activity.FirstSeen) {
+			activity.FirstSeen = eventTime
+		}
+		if eventTime.After(activity.LastSeen) {
+			activity.LastSeen = eventTime
+		}
+	}
+	groups := event.User.Groups
+	if event.ImpersonatedUser != nil && event.ImpersonatedUser.Username != "" {
+		groups = event.ImpersonatedUser.Groups
+	}
+	for _, group := range groups {
+		activity.Groups[group] = struct{}{}
+	}
+
+	uKey := eventUsageKey(event)
+	entry, ok := activity.Usage[uKey]
+	if !ok {
+		entry = &usageEntry{Names: make(map[string]struct{})}
+		activity.Usage[uKey] = entry
+	}
+	entry.Requests += 1
+	if event.ObjectRef != nil && event.ObjectRef.Name != "" {
+		entry.Names[event.ObjectRef.Name] = struct{}{}
+	} else {
+		entry.Unnamed = true
+	}
+}
+
+// Returns the kind of request @event denotes
+func eventUsageKey(event audit.Event) usageKey {
+	uKey := usageKey{Verb: event.Verb}
+	if resource := event.Resource(); resource != "" {
+		uKey.Resource = resource
+		uKey.APIGroup = event.ObjectRef.APIGroup
+		uKey.Namespace = event.ObjectRef.Namespace
+	} else {
+		uKey.NonResourceURL = event.NonResourcePath()
+	}
+	return uKey
+}
+
+// Returns the roles granted to @groups and to the groups @activity was seen with
+func rolesOfGroups(groupRoles map[string][]expand.ExpandedRole, groups []string, activity *identityActivity) []expand.ExpandedRole {
+	var roles []expand.ExpandedRole
+	seen := make(map[string]struct{})
+	addGroup := func(group string) {
+		if _, ok := seen[group]; ok {
+			return
+		}
+		seen[group] = struct{}{}
+		roles = append(roles, groupRoles[group]...)
+	}
+	for _, group := range groups {
+		addGroup(group)
+	}
+	if activity != nil {
+		for group := range activity.Groups {
+			addGroup(group)
+		}
+	}
+	return roles
+}
+
+// Compares the permissions @identity exercised in @activity against the roles granted to it directly, @identityRoles,
+// and those granted to its groups, @groupRoles. Proposes minimal roles covering the permissions granted directly.
+func analyzeIdentity(identity audit.Identity, activity *identityActivity, identityRoles []expand.ExpandedRole, groupRoles []expand.ExpandedRole) IdentityUsage {
+	identityUsage := IdentityUsage{Type: identity.Type, Name: identity.Name, Namespace: identity.Namespace}
+	if activity == nil {
+		activity = &identityActivity{Usage: make(map[usageKey]*usageEntry)}
+	} else {
+		identityUsage.Requests = activity.Requests
+		if !activity.FirstSeen.IsZero() {
+			identityUsage.FirstSeen, identityUsage.LastSeen = timePtr(activity.FirstSeen), timePtr(activity.LastSeen)
+		}
+	}
+
+	// Attribute each kind of request to the roles that granted it
+	grantedBy := make(map[usageKey]string)
+	for uKey, entry := range activity.Usage {
+		if rolesCover(identityRoles, uKey, entry) {
+			grantedBy[uKey] = GrantedByIdentity
+		} else if rolesCover(groupRoles, uKey, entry) {
+			grantedBy[uKey] = GrantedByGroup
+		} else {
+			grantedBy[uKey] = GrantedByOther
+		}
+	}
+	identityUsage.Used = usedPermissions(activity.Usage, grantedBy)
+	identityUsage.UnusedGrants = unusedGrants(identityRoles, activity.Usage)
+	identityUsage.ProposedRoles = proposeRoles(identity, activity.Usage, grantedBy)
+	return identityUsage
+}
+
+// Aggregates @usage into used permissions, merging the verbs of requests against the same target
+func usedPermissions(usage map[usageKey]*usageEntry, grantedBy map[usageKey]string) []UsedPermission {
+	type target struct {
+		key       usageKey // with an empty verb
+		grantedBy string
+	}
+	targets := make(map[target]*UsedPermission)
+	for uKey, entry := range usage {
+		targetKey := uKey
+		targetKey.Verb = ""
+		t := target{targetKey, grantedBy[uKey]}
+		permission, ok := targets[t]
+		if !ok {
+			permission = &UsedPermission{
+				Namespace:      uKey.Namespace,
+				APIGroup:       uKey.APIGroup,
+				Resource:       uKey.Resource,
+				NonResourceURL: uKey.NonResourceURL,
+				GrantedBy:      t.grantedBy,
+			}
+			targets[t] = permission
+		}
+		permission.Verbs = append(permission.Verbs, uKey.Verb)
+		permission.Requests += entry.Requests
+	}
+
+	var permissions []UsedPermission
+	for _, permission := range targets {
+		sort.Strings(permission.Verbs)
+		permissions = append(permissions, *permission)
+	}
+	sort.Slice(permissions, func(i, j int) bool {
+		a, b := permissions[i], permissions[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.APIGroup != b.APIGroup {
+			return a.APIGroup < b.APIGroup
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		if a.NonResourceURL != b.NonResourceURL {
+			return a.NonResourceURL < b.NonResourceURL
+		}
+		return a.GrantedBy < b.GrantedBy
+	})
+	return permissions
+}
+
+// Returns the rules of @roles that weren't fully exercised by @usage
+func unusedGrants(roles []expand.ExpandedRole, usage map[usageKey]*usageEntry) []UnusedGrant {
+	var grants []UnusedGrant
+	for _, role := range roles {
+		for _, rule := range role.Rules {
+			grant := UnusedGrant{Role: role.Name, EffectiveNamespace: role.EffectiveNamespace, Rule: rule, Unused: true}
+			usedVerbs, usedResources := make(map[string]struct{}), make(map[string]struct{})
+			for uKey, entry := range usage {
+				if !ruleCovers(rule, role.EffectiveNamespace, uKey, entry) {
+					continue
+				}
+				grant.Unused = false
+				usedVerbs[uKey.Verb] = struct{}{}
+				for _, resource := range append(rule.Resources, rule.NonResourceURLs...) {
+					if resourceMatches(resource, uKey.Resource) || nonResourceURLMatches(resource, uKey.NonResourceURL) {
+						usedResources[resource] = struct{}{}
+					}
+				}
+			}
+			if !grant.Unused {
+				for _, verb := range rule.Verbs {
+					if _, ok := usedVerbs[verb]; !ok && verb != rbac.VerbAll {
+						grant.UnusedVerbs = append(grant.UnusedVerbs, verb)
+					}
+				}
+				for _, resource := range append(rule.Resources, rule.NonResourceURLs...) {
+					if _, ok := usedResources[resource]; !ok && resource != rbac.ResourceAll {
+						grant.UnusedResources = append(grant.UnusedResources, resource)
+					}
+				}
+				if len(grant.UnusedVerbs) == 0 && len(grant.UnusedResources) == 0 {
+					continue // fully exercised
+				}
+			}
+			grants = append(grants, grant)
+		}
+	}
+	return grants
+}
+
+// Returns whether a rule of @roles covers the requests of @uKey
+func rolesCover(roles []expand.ExpandedRole, uKey usageKey, entry *usageEntry) bool {
+	for _, role := range roles {
+		for _, rule := range role.Rules {
+			if ruleCovers(rule, role.EffectiveNamespace, uKey, entry) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns whether @rule, granted in @effectiveNamespace, covers the requests of @uKey
+func ruleCovers(rule rbac.PolicyRule, effectiveNamespace string, uKey usageKey, entry *usageEntry) bool {
+	if !matchesAny(rule.Verbs, uKey.Verb, func(verb string, value string) bool { return verb == value }) {
+		return false
+	}
+	if uKey.NonResourceURL != "" {
+		// NonResourceURLs are only granted by clusterRoleBindings
+		return effectiveNamespace == "" && matchesAny(rule.NonResourceURLs, uKey.NonResourceURL, nonResourceURLMatches)
+	}
+	if effectiveNamespace != "" && uKey.Namespace != effectiveNamespace {
+		return false
+	}
+	if !matchesAny(rule.APIGroups, uKey.APIGroup, func(group string, value string) bool { return group == value }) {
+		return false
+	}
+	if !matchesAny(rule.Resources, uKey.Resource, resourceMatches) {
+		return false
+	}
+	if len(rule.ResourceNames) > 0 {
+		if entry.Unnamed {
+			return false
+		}
+		for name := range entry.Names {
+			if !utils.Contains(rule.ResourceNames, name) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Returns whether one of @ruleValues matches @value, either via @matches or by being a wildcard
+func matchesAny(ruleValues []string, value string, matches func(ruleValue string, value string) bool) bool {
+	for _, ruleValue := range ruleValues {
+		if ruleValue == "*" || matches(ruleValue, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns whether @ruleResource matches @resource, which may include a subresource
+func resourceMatches(ruleResource string, resource string) bool {
+	if resource == "" {
+		return false
+	}
+	if ruleResource == resource || ruleResource == rbac.ResourceAll {
+		return true
+	}
+	// Support '*/subresource'
+	parts := strings.SplitN(resource, "/", 2)
+	return len(parts) == 2 && ruleResource == "*/"+parts[1]
+}
+
+// Returns whether @ruleURL matches @url, supporting a trailing '*'
+func nonResourceURLMatches(ruleURL string, url string) bool {
+	if url == "" {
+		return false
+	}
+	if ruleURL == url || ruleURL == rbac.NonResourceAll {
+		return true
+	}
+	return strings.HasSuffix(ruleURL, "*") && strings.HasPrefix(url, strings.TrimSuffix(ruleURL, "*"))
+}
+
+// Returns a unique key for @identity
+func identityKey(identity audit.Identity) string {
+	return identity.Type + "/" + identityName(identity)
+}
+
+// Returns the full name of @identity
+func identityName(identity audit.Identity) string {
+	if identity.Type == "serviceAccount" {
+		return utils.FullName(identity.Namespace, identity.Name)
+	}
+	return identity.Name
+}
+
+// Returns a pointer to a copy of @t
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
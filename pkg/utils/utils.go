@@ -20,3 +20,13 @@ func ReadFile(path string) ([]byte, error) {
 func FullName(namespace string, name string) string {
 	return namespace + ":" + name
 }
+
+// Returns whether @arr contains @value
+func Contains(arr []string, value string) bool {
+	for _, item := range arr {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
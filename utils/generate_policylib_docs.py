@@ -20,7 +20,7 @@ def main():
     for root, dirs, files in os.walk(POLICY_DIR, topdown=True):
         dirs[:] = [d for d in dirs if d not in EXCLUDED_DIRS]
         for file_name in files:
-            if file_name.endswith(".rego"):
+            if file_name.endswith(".rego") and not file_name.endswith("_test.rego"):
                 policy_paths.append(os.path.join(root, file_name))
     
     # Generate documentation for each policy
@@ -35,8 +35,11 @@ Returns the description, severity and violation types of the
 policy at @policy_path, in the following markdown format:
 
 ### [<policy_name>](../lib/<policy_name>.rego)
+- ID: `<id>`
 - Description: `<description>`
 - Severity: `<severity>`
+- Category: `<category>`
+- MITRE ATT&CK: `<technique_ids>`
 - Violation types: `<violation_types>`
 """
 def generate_doc(policy_path):
@@ -45,7 +48,7 @@ def generate_doc(policy_path):
     doc = f"### [{policy_name}]({policy_path_from_docs_dir}.rego)\n"
 
     violation_types = []
-    description, severity = "", ""
+    description, severity, policy_id, category, mitre = "", "", "", "", []
     with open(policy_path, "r")  as policy_file:
         for line in policy_file.readlines():
             if "targets" in line:
@@ -67,12 +70,24 @@ def generate_doc(policy_path):
                     description = "".join(line.split("\"")[1:-1])
             elif defined_in_rego_line(line, "severity"):
                 severity = "".join(line.split("\"")[1:-1])
+            elif defined_in_rego_line(line, "id"):
+                policy_id = "".join(line.split("\"")[1:-1])
+            elif defined_in_rego_line(line, "category"):
+                category = "".join(line.split("\"")[1:-1])
+            elif defined_in_rego_line(line, "mitre"):
+                mitre = regex.findall(r'"([^"]+)"', line)
     
     if len(violation_types) == 0 and policy_name == "providerIAM":
         violation_types.append("serviceAccounts")
 
+    if policy_id:
+        doc += f"- ID: `{policy_id}`\n"
     doc += f"- Description: `{description}`\n"
     doc += f"- Severity: `{severity}`\n"
+    if category:
+        doc += f"- Category: `{category}`\n"
+    if mitre:
+        doc += f"- MITRE ATT&CK: `{', '.join(mitre)}`\n"
     doc += f"- Violation types: `{', '.join(violation_types)}`\n"
     return doc
 
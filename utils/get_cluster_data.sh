@@ -22,6 +22,7 @@ kubectl get rolebindings -A -o json > "$dir/rolebindings.json"
 kubectl get clusterroles -o json > "$dir/clusterroles.json"
 kubectl get clusterrolebindings -o json > "$dir/clusterrolebindings.json"
 # Optional:
+kubectl get namespaces -o json > "$dir/namespaces.json"
 kubectl config view -o jsonpath='{.contexts[?(@.name == "'"${curr_context}"'")].context.cluster}' > "$dir/cluster_name"
 kubectl get --raw /version > "$dir/version.json"
 